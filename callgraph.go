@@ -0,0 +1,426 @@
+package symc
+
+// callgraph モジュール
+// 関数定義に含まれる関数呼び出しから呼び出しグラフを作り, DOT, Mermaid, PlantUML で書き出す
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ノードの種類
+const (
+	NodeFunction = "function" // 定義のある関数
+	NodeExternal = "external" // 定義のない関数. ライブラリ関数など
+	NodeVariable = "variable" // グローバル変数
+)
+
+// 辺の種類
+const (
+	EdgeCall  = "call"
+	EdgeRead  = "read"
+	EdgeWrite = "write"
+)
+
+// CallGraphOptions 呼び出しグラフの作成オプション
+type CallGraphOptions struct {
+	ClusterByFile bool   // ノードを定義のあるファイルごとにまとめる. 位置情報が必要
+	Root          string // 指定した場合はこの関数から呼び出せる関数のみを含める
+	Depth         int    // Root からの呼び出しの深さの上限. 0 の場合は制限しない
+	HideExternal  bool   // 定義のない関数(ライブラリ関数)の呼び出しを含めない
+	Globals       bool   // グローバル変数と, 関数からの参照・代入の辺を含める
+}
+
+// CallGraph 呼び出しグラフ
+type CallGraph struct {
+	Nodes         []*GraphNode // 出現順
+	Edges         []*GraphEdge // 出現順. 同じ辺は一つにまとめる
+	ClusterByFile bool
+}
+
+// GraphNode 関数もしくはグローバル変数
+type GraphNode struct {
+	Name string
+	Kind string // Node* のいずれか
+	File string // 定義のあるファイル. 不明な場合は空
+}
+
+// GraphEdge 呼び出し, もしくは変数の参照・代入
+type GraphEdge struct {
+	From string
+	To   string
+	Kind string // Edge* のいずれか
+}
+
+// グラフの作成中の状態
+type graphBuilder struct {
+	funcs   map[string]*GraphNode
+	globals map[string]*GraphNode
+	order   []*GraphNode
+	edges   []*GraphEdge
+	seen    map[GraphEdge]bool
+}
+
+// BuildCallGraph
+// モジュールから呼び出しグラフを作る
+func BuildCallGraph(ms []*Module, opts CallGraphOptions) *CallGraph {
+	b := &graphBuilder{funcs: map[string]*GraphNode{}, globals: map[string]*GraphNode{}, seen: map[GraphEdge]bool{}}
+	// 定義を先に集める
+	for _, m := range ms {
+		for _, s := range m.Statements {
+			switch v := s.(type) {
+			case *FunctionDef:
+				b.function(v)
+			case *VariableDef:
+				b.global(v.Name, v.Pos)
+			case *VariableDecl:
+				b.global(v.Name, v.Pos)
+			}
+		}
+	}
+	for _, m := range ms {
+		for _, s := range m.Statements {
+			if f, ok := s.(*FunctionDef); ok {
+				b.body(f.Name, f.Statements)
+			}
+		}
+	}
+
+	keep := b.reachable(opts)
+	g := &CallGraph{Nodes: []*GraphNode{}, Edges: []*GraphEdge{}, ClusterByFile: opts.ClusterByFile}
+	used := map[string]bool{}
+	for _, e := range b.edges {
+		if !keep[e.From] {
+			continue
+		}
+		to := b.node(e.To, e.Kind)
+		switch {
+		case e.Kind == EdgeCall && !keep[e.To]:
+			continue
+		case e.Kind == EdgeCall && to.Kind == NodeExternal && opts.HideExternal:
+			continue
+		case e.Kind != EdgeCall && !opts.Globals:
+			continue
+		}
+		g.Edges = append(g.Edges, e)
+		used[to.Kind+"\x00"+e.To] = true
+	}
+	for _, n := range b.order {
+		switch {
+		case n.Kind == NodeFunction && keep[n.Name]:
+		case n.Kind != NodeFunction && used[n.Kind+"\x00"+n.Name]:
+		default:
+			continue
+		}
+		g.Nodes = append(g.Nodes, n)
+	}
+	return g
+}
+
+// function
+func (b *graphBuilder) function(f *FunctionDef) {
+	if n, ok := b.funcs[f.Name]; ok {
+		n.Kind = NodeFunction
+		n.File = f.Pos.File
+		return
+	}
+	n := &GraphNode{Name: f.Name, Kind: NodeFunction, File: f.Pos.File}
+	b.funcs[f.Name] = n
+	b.order = append(b.order, n)
+}
+
+// global
+func (b *graphBuilder) global(name string, pos Position) {
+	if n, ok := b.globals[name]; ok {
+		if n.File == "" {
+			n.File = pos.File
+		}
+		return
+	}
+	n := &GraphNode{Name: name, Kind: NodeVariable, File: pos.File}
+	b.globals[name] = n
+	b.order = append(b.order, n)
+}
+
+// node
+// 辺の行き先のノードを返す. 定義のない関数は外部の関数として追加する
+func (b *graphBuilder) node(name, kind string) *GraphNode {
+	if kind != EdgeCall {
+		return b.globals[name]
+	}
+	n, ok := b.funcs[name]
+	if !ok {
+		n = &GraphNode{Name: name, Kind: NodeExternal}
+		b.funcs[name] = n
+		b.order = append(b.order, n)
+	}
+	return n
+}
+
+// body
+// 関数本体の呼び出しと変数の参照・代入を辺にする
+// ブロックスコープの宣言や仮引数を指す参照と代入は辺にしない
+func (b *graphBuilder) body(fn string, ss []Statement) {
+	for _, s := range ss {
+		switch v := s.(type) {
+		case *CallFunc:
+			b.edge(fn, v.Name, EdgeCall)
+			b.body(fn, v.Args)
+		case *RefVar:
+			if b.globals[v.Name] != nil && !v.Local {
+				b.edge(fn, v.Name, EdgeRead)
+			}
+		case *Assigne:
+			if b.globals[v.Name] != nil && !v.Local {
+				b.edge(fn, v.Name, EdgeWrite)
+			}
+		case *FunctionDef:
+			b.function(v)
+			b.body(v.Name, v.Statements)
+		}
+	}
+}
+
+// edge
+func (b *graphBuilder) edge(from, to, kind string) {
+	e := GraphEdge{From: from, To: to, Kind: kind}
+	if b.seen[e] {
+		return
+	}
+	b.seen[e] = true
+	if kind == EdgeCall {
+		b.node(to, kind)
+	}
+	b.edges = append(b.edges, &e)
+}
+
+// reachable
+// グラフに含める関数を返す. Root の指定がない場合は定義のある全ての関数
+func (b *graphBuilder) reachable(opts CallGraphOptions) map[string]bool {
+	keep := map[string]bool{}
+	if opts.Root == "" {
+		for name := range b.funcs {
+			keep[name] = true
+		}
+		return keep
+	}
+	keep[opts.Root] = true
+	frontier := []string{opts.Root}
+	for depth := 1; len(frontier) > 0 && (opts.Depth <= 0 || depth <= opts.Depth); depth++ {
+		next := []string{}
+		for _, e := range b.edges {
+			if e.Kind != EdgeCall || keep[e.To] {
+				continue
+			}
+			for _, f := range frontier {
+				if e.From == f {
+					keep[e.To] = true
+					next = append(next, e.To)
+					break
+				}
+			}
+		}
+		frontier = next
+	}
+	return keep
+}
+
+// clusters
+// ノードをファイルごとにまとめる. ファイルの不明なノードは空文字列にまとめる
+func (g *CallGraph) clusters() ([]string, map[string][]*GraphNode) {
+	files := []string{}
+	groups := map[string][]*GraphNode{}
+	for _, n := range g.Nodes {
+		f := ""
+		if g.ClusterByFile {
+			f = n.File
+		}
+		if _, ok := groups[f]; !ok {
+			files = append(files, f)
+		}
+		groups[f] = append(groups[f], n)
+	}
+	return files, groups
+}
+
+// nodeIDs
+// Mermaid と PlantUML で用いるノードの識別子
+func (g *CallGraph) nodeIDs() map[string]string {
+	ids := map[string]string{}
+	for i, n := range g.Nodes {
+		ids[n.Kind+"\x00"+n.Name] = fmt.Sprintf("n%d", i)
+	}
+	return ids
+}
+
+// targetKey
+// 辺の行き先のノードを nodeIDs の鍵で返す
+func (g *CallGraph) targetKey(e *GraphEdge) string {
+	if e.Kind != EdgeCall {
+		return NodeVariable + "\x00" + e.To
+	}
+	for _, n := range g.Nodes {
+		if n.Name == e.To && n.Kind != NodeVariable {
+			return n.Kind + "\x00" + n.Name
+		}
+	}
+	return NodeFunction + "\x00" + e.To
+}
+
+// quoteDOT
+func quoteDOT(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// WriteDOT
+// Graphviz の DOT 形式で書き出す
+func WriteDOT(w io.Writer, g *CallGraph) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph callgraph {\n")
+	bw.WriteString("    node [shape=box];\n")
+	ids := map[string]string{}
+	for _, n := range g.Nodes {
+		id := n.Name
+		if n.Kind == NodeVariable {
+			// 関数と同名の変数と区別する
+			id = "var:" + n.Name
+		}
+		ids[n.Kind+"\x00"+n.Name] = quoteDOT(id)
+	}
+	node := func(indent string, n *GraphNode) {
+		attrs := ""
+		switch n.Kind {
+		case NodeExternal:
+			attrs = " [style=dashed]"
+		case NodeVariable:
+			attrs = fmt.Sprintf(" [label=%s, shape=ellipse]", quoteDOT(n.Name))
+		}
+		fmt.Fprintf(bw, "%s%s%s;\n", indent, ids[n.Kind+"\x00"+n.Name], attrs)
+	}
+	files, groups := g.clusters()
+	for i, f := range files {
+		if f == "" {
+			for _, n := range groups[f] {
+				node("    ", n)
+			}
+			continue
+		}
+		fmt.Fprintf(bw, "    subgraph cluster_%d {\n", i)
+		fmt.Fprintf(bw, "        label=%s;\n", quoteDOT(f))
+		for _, n := range groups[f] {
+			node("        ", n)
+		}
+		bw.WriteString("    }\n")
+	}
+	for _, e := range g.Edges {
+		attrs := ""
+		switch e.Kind {
+		case EdgeRead:
+			attrs = ` [label="read", style=dashed]`
+		case EdgeWrite:
+			attrs = ` [label="write"]`
+		}
+		fmt.Fprintf(bw, "    %s -> %s%s;\n", ids[NodeFunction+"\x00"+e.From], ids[g.targetKey(e)], attrs)
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// quoteMermaid
+func quoteMermaid(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+// WriteMermaid
+// Mermaid のフローチャートとして書き出す
+func WriteMermaid(w io.Writer, g *CallGraph) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("flowchart LR\n")
+	ids := g.nodeIDs()
+	node := func(indent string, n *GraphNode) {
+		id := ids[n.Kind+"\x00"+n.Name]
+		switch n.Kind {
+		case NodeExternal:
+			fmt.Fprintf(bw, "%s%s([%s])\n", indent, id, quoteMermaid(n.Name))
+		case NodeVariable:
+			fmt.Fprintf(bw, "%s%s[(%s)]\n", indent, id, quoteMermaid(n.Name))
+		default:
+			fmt.Fprintf(bw, "%s%s[%s]\n", indent, id, quoteMermaid(n.Name))
+		}
+	}
+	files, groups := g.clusters()
+	for i, f := range files {
+		if f == "" {
+			for _, n := range groups[f] {
+				node("    ", n)
+			}
+			continue
+		}
+		fmt.Fprintf(bw, "    subgraph c%d[%s]\n", i, quoteMermaid(f))
+		for _, n := range groups[f] {
+			node("        ", n)
+		}
+		bw.WriteString("    end\n")
+	}
+	for _, e := range g.Edges {
+		from, to := ids[NodeFunction+"\x00"+e.From], ids[g.targetKey(e)]
+		switch e.Kind {
+		case EdgeRead:
+			fmt.Fprintf(bw, "    %s -. read .-> %s\n", from, to)
+		case EdgeWrite:
+			fmt.Fprintf(bw, "    %s -- write --> %s\n", from, to)
+		default:
+			fmt.Fprintf(bw, "    %s --> %s\n", from, to)
+		}
+	}
+	return bw.Flush()
+}
+
+// WritePlantUML
+// PlantUML の図として書き出す
+func WritePlantUML(w io.Writer, g *CallGraph) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("@startuml\n")
+	ids := g.nodeIDs()
+	node := func(indent string, n *GraphNode) {
+		id := ids[n.Kind+"\x00"+n.Name]
+		name := strings.ReplaceAll(n.Name, `"`, `\"`)
+		switch n.Kind {
+		case NodeExternal:
+			fmt.Fprintf(bw, "%srectangle \"%s\" as %s #line.dashed\n", indent, name, id)
+		case NodeVariable:
+			fmt.Fprintf(bw, "%sdatabase \"%s\" as %s\n", indent, name, id)
+		default:
+			fmt.Fprintf(bw, "%srectangle \"%s\" as %s\n", indent, name, id)
+		}
+	}
+	files, groups := g.clusters()
+	for _, f := range files {
+		if f == "" {
+			for _, n := range groups[f] {
+				node("", n)
+			}
+			continue
+		}
+		fmt.Fprintf(bw, "package \"%s\" {\n", strings.ReplaceAll(f, `"`, `\"`))
+		for _, n := range groups[f] {
+			node("    ", n)
+		}
+		bw.WriteString("}\n")
+	}
+	for _, e := range g.Edges {
+		from, to := ids[NodeFunction+"\x00"+e.From], ids[g.targetKey(e)]
+		switch e.Kind {
+		case EdgeRead:
+			fmt.Fprintf(bw, "%s ..> %s : read\n", from, to)
+		case EdgeWrite:
+			fmt.Fprintf(bw, "%s --> %s : write\n", from, to)
+		default:
+			fmt.Fprintf(bw, "%s --> %s\n", from, to)
+		}
+	}
+	bw.WriteString("@enduml\n")
+	return bw.Flush()
+}
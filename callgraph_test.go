@@ -0,0 +1,214 @@
+package symc
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const callGraphSrc = `# 1 "a.c"
+int count;
+int helper(int n) { count = n; return count; }
+int main(void) { helper(1); printf("x"); return 0; }
+# 1 "b.c"
+void other(void) { int count; count = 1; main(); }
+`
+
+func TestBuildCallGraph(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		opts    CallGraphOptions
+		nodes   []string
+		edges   []GraphEdge
+	}{
+		{
+			"all functions",
+			CallGraphOptions{},
+			[]string{"helper", "main", "other", "printf"},
+			[]GraphEdge{
+				{"main", "helper", EdgeCall},
+				{"main", "printf", EdgeCall},
+				{"other", "main", EdgeCall},
+			},
+		},
+		{
+			"hide external calls",
+			CallGraphOptions{HideExternal: true},
+			[]string{"helper", "main", "other"},
+			[]GraphEdge{
+				{"main", "helper", EdgeCall},
+				{"other", "main", EdgeCall},
+			},
+		},
+		{
+			"root and depth",
+			CallGraphOptions{Root: "other", Depth: 1},
+			[]string{"main", "other"},
+			[]GraphEdge{
+				{"other", "main", EdgeCall},
+			},
+		},
+		{
+			"root without depth limit",
+			CallGraphOptions{Root: "other"},
+			[]string{"helper", "main", "other", "printf"},
+			[]GraphEdge{
+				{"main", "helper", EdgeCall},
+				{"main", "printf", EdgeCall},
+				{"other", "main", EdgeCall},
+			},
+		},
+		{
+			"globals with read and write edges, local shadows global",
+			CallGraphOptions{Globals: true, HideExternal: true},
+			[]string{"count", "helper", "main", "other"},
+			[]GraphEdge{
+				{"helper", "count", EdgeWrite},
+				{"helper", "count", EdgeRead},
+				{"main", "helper", EdgeCall},
+				{"other", "main", EdgeCall},
+			},
+		},
+	}
+
+	m := ParseModule(callGraphSrc)
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		g := BuildCallGraph([]*Module{m}, tt.opts)
+		nodes := []string{}
+		for _, n := range g.Nodes {
+			nodes = append(nodes, n.Name)
+		}
+		if !reflect.DeepEqual(nodes, tt.nodes) {
+			t.Errorf("nodes: expect %v, actual %v", tt.nodes, nodes)
+		}
+		edges := []GraphEdge{}
+		for _, e := range g.Edges {
+			edges = append(edges, *e)
+		}
+		if !reflect.DeepEqual(edges, tt.edges) {
+			t.Errorf("edges: expect %v, actual %v", tt.edges, edges)
+		}
+	}
+}
+
+func TestBuildCallGraphNested(t *testing.T) {
+	m := ParseModule("int x; void f(void) { int g(int x) { return h(x); } g(1); x = 2; }")
+	g := BuildCallGraph([]*Module{m}, CallGraphOptions{Globals: true})
+	edges := []GraphEdge{}
+	for _, e := range g.Edges {
+		edges = append(edges, *e)
+	}
+	expect := []GraphEdge{
+		{"g", "h", EdgeCall},
+		{"f", "g", EdgeCall},
+		{"f", "x", EdgeWrite},
+	}
+	if !reflect.DeepEqual(edges, expect) {
+		t.Errorf("expect %v, actual %v", expect, edges)
+	}
+}
+
+func TestBuildCallGraphBlockScope(t *testing.T) {
+	m := ParseModule("int x; void f(void) { { int x; x = 1; } x = 2; if (x) { int x = 3; x++; } }")
+	g := BuildCallGraph([]*Module{m}, CallGraphOptions{Globals: true})
+	edges := []GraphEdge{}
+	for _, e := range g.Edges {
+		edges = append(edges, *e)
+	}
+	expect := []GraphEdge{
+		{"f", "x", EdgeWrite},
+		{"f", "x", EdgeRead},
+	}
+	if !reflect.DeepEqual(edges, expect) {
+		t.Errorf("expect %v, actual %v", expect, edges)
+	}
+}
+
+func TestWriteCallGraph(t *testing.T) {
+	m, _ := ParseWithOptions(callGraphSrc, ParseOptions{Positions: true})
+	g := BuildCallGraph([]*Module{m}, CallGraphOptions{ClusterByFile: true, Globals: true})
+
+	testTbl := []struct {
+		comment string
+		write   func(*bytes.Buffer, *CallGraph) error
+		expect  string
+	}{
+		{
+			"dot",
+			func(b *bytes.Buffer, g *CallGraph) error { return WriteDOT(b, g) },
+			`digraph callgraph {
+    node [shape=box];
+    subgraph cluster_0 {
+        label="a.c";
+        "var:count" [label="count", shape=ellipse];
+        "helper";
+        "main";
+    }
+    subgraph cluster_1 {
+        label="b.c";
+        "other";
+    }
+    "printf" [style=dashed];
+    "helper" -> "var:count" [label="write"];
+    "helper" -> "var:count" [label="read", style=dashed];
+    "main" -> "helper";
+    "main" -> "printf";
+    "other" -> "main";
+}
+`,
+		},
+		{
+			"mermaid",
+			func(b *bytes.Buffer, g *CallGraph) error { return WriteMermaid(b, g) },
+			`flowchart LR
+    subgraph c0["a.c"]
+        n0[("count")]
+        n1["helper"]
+        n2["main"]
+    end
+    subgraph c1["b.c"]
+        n3["other"]
+    end
+    n4(["printf"])
+    n1 -- write --> n0
+    n1 -. read .-> n0
+    n2 --> n1
+    n2 --> n4
+    n3 --> n2
+`,
+		},
+		{
+			"plantuml",
+			func(b *bytes.Buffer, g *CallGraph) error { return WritePlantUML(b, g) },
+			`@startuml
+package "a.c" {
+    database "count" as n0
+    rectangle "helper" as n1
+    rectangle "main" as n2
+}
+package "b.c" {
+    rectangle "other" as n3
+}
+rectangle "printf" as n4 #line.dashed
+n1 --> n0 : write
+n1 ..> n0 : read
+n2 --> n1
+n2 --> n4
+n3 --> n2
+@enduml
+`,
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		var b bytes.Buffer
+		if err := tt.write(&b, g); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if b.String() != tt.expect {
+			t.Errorf("expect\n%s\nactual\n%s", tt.expect, b.String())
+		}
+	}
+}
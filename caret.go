@@ -0,0 +1,40 @@
+package symc
+
+// caret モジュール
+// コンパイラ形式の診断表示のためにソースの該当行を切り出す
+
+import (
+	"strings"
+)
+
+// Snippet
+// 位置を含むソースの行と, その列を指すキャレットの行を返す
+// 位置は入力上のバイト位置 (Position.Offset) で特定する
+func Snippet(src string, pos Position) string {
+	off := pos.Offset
+	if off < 0 {
+		off = 0
+	} else if off > len(src) {
+		off = len(src)
+	}
+	start := strings.LastIndexByte(src[:off], '\n') + 1
+	end := strings.IndexByte(src[off:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += off
+	}
+	line := strings.TrimRight(src[start:end], "\r")
+
+	// タブはそのまま残して表示上の桁を合わせる
+	var b strings.Builder
+	for _, c := range src[start:off] {
+		if c == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	b.WriteRune('^')
+	return line + "\n" + b.String()
+}
@@ -0,0 +1,84 @@
+package symc
+
+import (
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		pos     Position
+		expect  string
+	}{
+		{
+			"snippet 1",
+			`int a;
+int f(void) { x = 1 }
+int b;
+`,
+			Position{Offset: 27, Line: 2, Column: 21},
+			`int f(void) { x = 1 }
+                    ^`,
+		},
+		{
+			"snippet tab",
+			"int f(void) {\n\tx = 1\n}\n",
+			Position{Offset: 21, Line: 3, Column: 1},
+			"}\n^",
+		},
+		{
+			"snippet tab indent",
+			"int f(void) {\n\tx = 1 y;\n}\n",
+			Position{Offset: 21, Line: 2, Column: 8},
+			"\tx = 1 y;\n\t      ^",
+		},
+		{
+			"snippet end of file",
+			"int a",
+			Position{Offset: 5, Line: 1, Column: 6},
+			"int a\n     ^",
+		},
+		{
+			"snippet crlf",
+			"int a\r\nint b;\r\n",
+			Position{Offset: 7, Line: 2, Column: 1},
+			"int b;\n^",
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		got := Snippet(tt.src, tt.pos)
+		if got != tt.expect {
+			t.Errorf("got=%q, expect=%q", got, tt.expect)
+		}
+	}
+}
+
+func TestParseErrorMessage(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		err     *ParseError
+		expect  string
+	}{
+		{
+			"message 1",
+			&ParseError{Expected: "';'", Found: "}"},
+			"expected ';' before '}' token",
+		},
+		{
+			"message end of input",
+			&ParseError{Expected: "'}'"},
+			"expected '}' at end of input",
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		got := tt.err.Message()
+		if got != tt.expect {
+			t.Errorf("got=%v, expect=%v", got, tt.expect)
+		}
+	}
+}
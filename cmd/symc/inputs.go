@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kita127/symc"
+)
+
+// ディレクトリを指定した場合に解析するファイルの拡張子
+var sourceExts = map[string]bool{".c": true, ".h": true, ".i": true}
+
+// input 解析した入力ひとつ
+type input struct {
+	name     string // 診断に用いるファイル名
+	analysis *symc.Analysis
+}
+
+// inputs 入力全体の解析結果
+type inputs struct {
+	ins   []*input
+	errs  []error           // 読み込みもしくは前処理のエラー
+	texts map[string]string // 原ファイルそのものである標準入力のテキスト
+	dirs  map[string]string // 翻訳単位の行マーカーの相対パスの基準ディレクトリ
+}
+
+// expandArgs
+// 引数のファイル, ディレクトリ, glob をパスの一覧にする. 引数がない場合と "-" は標準入力を表す "-"
+// 存在しないパスや一致しない glob, 繰り返した "-" はエラーとする
+func expandArgs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	paths := []string{}
+	seen := map[string]bool{}
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for _, arg := range args {
+		if arg == "-" {
+			// 標準入力は一度しか読めない
+			if seen["-"] {
+				return nil, fmt.Errorf("standard input (-) given more than once")
+			}
+			add("-")
+			continue
+		}
+		ms := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			// シェルが展開しなかった glob
+			var err error
+			if ms, err = filepath.Glob(arg); err != nil {
+				return nil, fmt.Errorf("bad pattern %q: %v", arg, err)
+			}
+			if len(ms) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+		}
+		for _, path := range ms {
+			fi, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !fi.IsDir() {
+				add(path)
+				continue
+			}
+			files := []string{}
+			err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() && sourceExts[filepath.Ext(p)] {
+					files = append(files, p)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(files)
+			for _, f := range files {
+				add(f)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// loadInputs
+// コンパイルデータベースもしくは引数の入力を解析する
+// 引数の誤りはエラーとし, 入力ごとの読み込みや前処理の失敗は結果に含める
+func loadInputs(project string, args []string, stdinName string, stdin io.Reader, opts symc.ParseOptions) (*inputs, error) {
+	if project != "" {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("file arguments cannot be used with -project")
+		}
+		return projectInputs(project, opts)
+	}
+	paths, err := expandArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return fileInputs(paths, stdinName, stdin, opts), nil
+}
+
+// fileInputs
+// ファイルを並行に解析する. "-" は標準入力を stdinName として解析する
+func fileInputs(paths []string, stdinName string, stdin io.Reader, opts symc.ParseOptions) *inputs {
+	files := []string{}
+	for _, p := range paths {
+		if p != "-" {
+			files = append(files, p)
+		}
+	}
+	prog, errs := symc.ParseFiles(context.Background(), files, symc.ParseFilesOptions{Parse: opts})
+
+	s := &inputs{ins: []*input{}, errs: readErrors(errs), texts: map[string]string{}}
+	j := 0
+	for _, p := range paths {
+		if p != "-" {
+			if j < len(prog.Files) && prog.Files[j] == p {
+				s.ins = append(s.ins, &input{name: p, analysis: prog.Analyses[j]})
+				j++
+			}
+			continue
+		}
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			s.errs = append(s.errs, err)
+			continue
+		}
+		o := opts
+		o.FileName = stdinName
+		a, _ := symc.Analyze(context.Background(), string(b), o)
+		s.ins = append(s.ins, &input{name: stdinName, analysis: a})
+		if !hasLinemarkers(string(b)) {
+			s.texts[stdinName] = string(b)
+		}
+	}
+	return s
+}
+
+// projectInputs
+// コンパイルデータベースの各翻訳単位を前処理して解析する
+func projectInputs(path string, opts symc.ParseOptions) (*inputs, error) {
+	cmds, err := symc.LoadCompileCommands(path)
+	if err != nil {
+		return nil, err
+	}
+	prog, errs := symc.AnalyzeProject(context.Background(), cmds, symc.ProjectOptions{Parse: opts})
+
+	s := &inputs{ins: []*input{}, errs: readErrors(errs), dirs: map[string]string{}}
+	units := map[string]string{}
+	for _, c := range cmds {
+		units[c.Path()] = c.Directory
+	}
+	for i, f := range prog.Files {
+		s.ins = append(s.ins, &input{name: f, analysis: prog.Analyses[i]})
+		// 行マーカーのパスは翻訳単位のディレクトリを基準とする
+		for _, r := range symc.Flatten(prog.Modules[i]) {
+			if _, ok := s.dirs[r.File]; !ok && r.File != "" {
+				s.dirs[r.File] = units[f]
+			}
+		}
+	}
+	return s, nil
+}
+
+// readErrors
+// 解析エラーを除いた, 読み込みもしくは前処理のエラーを返す. 解析エラーは各入力の結果から出力する
+func readErrors(errs []error) []error {
+	es := []error{}
+	for _, err := range errs {
+		if e, ok := err.(*symc.UnitError); ok {
+			switch e.Err.(type) {
+			case symc.ErrorList, *symc.LimitError:
+				continue
+			case *os.PathError:
+				// パスはエラーに含まれている
+				err = e.Err
+			}
+		}
+		es = append(es, err)
+	}
+	return es
+}
+
+// hasLinemarkers
+// テキストが行マーカーを含むか. 含む場合は前処理の結果で, 原ファイルそのものではない
+func hasLinemarkers(src string) bool {
+	for _, l := range strings.Split(src, "\n") {
+		l = strings.TrimLeft(l, " \t")
+		if !strings.HasPrefix(l, "#") {
+			continue
+		}
+		l = strings.TrimLeft(l[1:], " \t")
+		l = strings.TrimPrefix(l, "line")
+		l = strings.TrimLeft(l, " \t")
+		if l != "" && l[0] >= '0' && l[0] <= '9' {
+			return true
+		}
+	}
+	return false
+}
+
+// read
+// 位置情報のファイルの原ファイルを読み込む
+// 原ファイルそのものである標準入力はそのテキストを, 翻訳単位の相対パスはそのディレクトリを基準に読み込む
+func (s *inputs) read(file string) (string, error) {
+	if src, ok := s.texts[file]; ok {
+		return src, nil
+	}
+	path := file
+	if dir, ok := s.dirs[file]; ok && !filepath.IsAbs(file) {
+		path = filepath.Join(dir, file)
+	}
+	b, err := ioutil.ReadFile(path)
+	return string(b), err
+}
+
+// modules
+// 解析した入力のモジュールを入力の順に返す
+func (s *inputs) modules() []*symc.Module {
+	ms := []*symc.Module{}
+	for _, in := range s.ins {
+		ms = append(ms, in.analysis.Module)
+	}
+	return ms
+}
+
+// report
+// 読み込みエラーと解析エラーを出力し, 終了コードを返す
+func (s *inputs) report(stderr io.Writer, quiet, color bool) int {
+	status := exitOK
+	for _, err := range s.errs {
+		if !quiet {
+			fmt.Fprintf(stderr, "symc: %v\n", err)
+		}
+		status = exitError
+	}
+	for _, in := range s.ins {
+		if in.analysis.Err == nil {
+			continue
+		}
+		if !quiet {
+			printErrors(stderr, in.analysis.Source(), in.analysis.Err, color)
+		}
+		status = exitError
+	}
+	return status
+}
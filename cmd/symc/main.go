@@ -1,20 +1,256 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/kita127/symc"
 )
 
+// 終了コード
+const (
+	exitOK    = 0 // 全ての入力を解析できた
+	exitError = 1 // 解析エラーもしくは読み込みエラーがあった
+	exitUsage = 2 // 引数の誤り
+)
+
 func main() {
-	input, _ := ioutil.ReadAll(os.Stdin)
-	module := symc.ParseModule(string(input))
-	fmt.Println(module.PrettyString())
-	for _, s := range module.Statements {
-		if i, ok := s.(*symc.InvalidStatement); ok {
-			fmt.Printf("remain:%v\n", i.Remain[:10])
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run
+// 引数を解釈して実行し, 終了コードを返す
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		if _, ok := subcommands[args[0]]; ok {
+			return runQuery(args[0], args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	fs := flag.NewFlagSet("symc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: symc [flags] [file|dir|glob ...]\n       symc defs|refs|callers|callees|writers|readers [NAME] [flags] [file|dir|glob ...]\n\nWith no file, or when file is -, read standard input.\nwriters lists assignments, including ++ and --.\n\n")
+		fs.PrintDefaults()
+	}
+	metrics := fs.Bool("metrics", false, "print per-function complexity and size metrics")
+	strs := fs.Bool("strings", false, "print string literals with their enclosing function and call")
+	coverage := fs.Bool("coverage", false, "print how many tokens were parsed and skipped, by rule and location")
+	name := fs.String("file", "<stdin>", "file name used in diagnostics for standard input when it has no linemarkers")
+	color := fs.Bool("color", false, "colorize diagnostics")
+	strict := fs.Bool("strict", false, "report an error instead of guessing on ambiguous or ungrammatical declarations")
+	format := fs.String("format", "text", "output format: text, json, csv, tsv, ctags, etags, scip, lsif, dot, mermaid or plantuml")
+	project := fs.String("project", "", "analyze every translation unit in this compile_commands.json instead of file arguments")
+	output := fs.String("o", "", "write output to this file instead of standard output")
+	quiet := fs.Bool("quiet", false, "do not print diagnostics; report failure only through the exit code")
+	root := fs.String("root", "", "call graph: only include functions reachable from this function")
+	depth := fs.Int("depth", 0, "call graph: maximum call depth from -root (0 means unlimited)")
+	cluster := fs.Bool("cluster", false, "call graph: group functions by source file")
+	hideExternal := fs.Bool("hide-external", false, "call graph: hide calls to functions without a definition, such as libc")
+	globals := fs.Bool("globals", false, "call graph: include global variables with read and write edges")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	switch *format {
+	case "text", "json", "csv", "tsv", "ctags", "etags", "scip", "lsif", "dot", "mermaid", "plantuml":
+	default:
+		fmt.Fprintf(stderr, "symc: unknown format %q\n", *format)
+		return exitUsage
+	}
+	// 表を出力するモードは同時に指定できず, 出力形式も text のみ
+	modes := 0
+	for _, m := range []bool{*metrics, *strs, *coverage} {
+		if m {
+			modes++
+		}
+	}
+	if modes > 1 {
+		fmt.Fprintf(stderr, "symc: -metrics, -strings and -coverage cannot be combined\n")
+		return exitUsage
+	}
+	if modes > 0 && *format != "text" {
+		fmt.Fprintf(stderr, "symc: -format %s cannot be used with -metrics, -strings or -coverage\n", *format)
+		return exitUsage
+	}
+
+	ins, err := loadInputs(*project, fs.Args(), *name, stdin, symc.ParseOptions{Strict: *strict, Positions: *format != "text"})
+	if err != nil {
+		fmt.Fprintf(stderr, "symc: %v\n", err)
+		return exitUsage
+	}
+
+	out, err := openSink(*output, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "symc: %v\n", err)
+		return exitError
+	}
+
+	if *metrics || *strs || *coverage {
+		for _, in := range ins.ins {
+			if len(ins.ins) > 1 {
+				fmt.Fprintf(out, "%s:\n", in.name)
+			}
+			switch {
+			case *metrics:
+				printMetrics(out, in.analysis.Metrics())
+			case *strs:
+				printStrings(out, in.analysis.StringLiterals())
+			default:
+				printCoverage(out, in.analysis.Coverage())
+			}
+		}
+		return out.finish(stderr, nil, ins.report(stderr, *quiet, *color))
+	}
+
+	ms := ins.modules()
+
+	switch *format {
+	case "json":
+		// 入力ごとにひとつの JSON の値を続けて書き出す
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		for _, m := range ms {
+			if err = enc.Encode(m); err != nil {
+				break
+			}
+		}
+	case "csv", "tsv":
+		rs := []*symc.Record{}
+		for _, m := range ms {
+			rs = append(rs, symc.Flatten(m)...)
+		}
+		if *format == "csv" {
+			err = symc.WriteCSV(out, rs)
+		} else {
+			err = symc.WriteTSV(out, rs)
+		}
+	case "ctags", "etags":
+		tags := symc.Tags(ms...)
+		// 行マーカーが指すファイルは読み込めた場合のみ検索用のテキストを付与する
+		symc.FillTagText(tags, ins.read)
+		if *format == "ctags" {
+			err = symc.WriteCtags(out, tags)
+		} else {
+			err = symc.WriteEtags(out, tags)
+		}
+	case "scip", "lsif":
+		root, _ := os.Getwd()
+		idx := symc.BuildIndex(ms, symc.IndexOptions{ProjectRoot: root, Read: ins.read})
+		if *format == "scip" {
+			err = symc.WriteSCIP(out, idx)
+		} else {
+			err = symc.WriteLSIF(out, idx)
+		}
+	case "dot", "mermaid", "plantuml":
+		g := symc.BuildCallGraph(ms, symc.CallGraphOptions{
+			ClusterByFile: *cluster,
+			Root:          *root,
+			Depth:         *depth,
+			HideExternal:  *hideExternal,
+			Globals:       *globals,
+		})
+		switch *format {
+		case "dot":
+			err = symc.WriteDOT(out, g)
+		case "mermaid":
+			err = symc.WriteMermaid(out, g)
+		default:
+			err = symc.WritePlantUML(out, g)
+		}
+	default:
+		for _, in := range ins.ins {
+			if len(ins.ins) > 1 {
+				fmt.Fprintf(out, "%s:\n", in.name)
+			}
+			fmt.Fprintln(out, in.analysis.Module.PrettyString())
+		}
+	}
+
+	return out.finish(stderr, err, ins.report(stderr, *quiet, *color))
+}
+
+// エスケープシーケンスによる色付け
+const (
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[1;31m"
+	colorGreen = "\x1b[1;32m"
+	colorReset = "\x1b[0m"
+)
+
+// printErrors
+// gcc と同じ形式で位置, メッセージ, ソースの該当行とキャレットを出力する
+func printErrors(w io.Writer, src string, err error, color bool) {
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+	report := func(pos symc.Position, msg string) {
+		fmt.Fprintf(w, "%s %s %s\n", paint(colorBold, pos.String()+":"), paint(colorRed, "error:"), msg)
+		snippet := symc.Snippet(src, pos)
+		i := strings.LastIndexByte(snippet, '\n')
+		fmt.Fprintf(w, "%s\n%s\n", snippet[:i], paint(colorGreen, snippet[i+1:]))
+	}
+
+	switch e := err.(type) {
+	case symc.ErrorList:
+		for _, v := range e {
+			report(v.Pos, v.Message())
+		}
+	case *symc.LimitError:
+		report(e.Pos, e.Message())
+	default:
+		fmt.Fprintf(w, "%s %v\n", paint(colorRed, "error:"), err)
+	}
+}
+
+func printMetrics(out io.Writer, ms []*symc.Metrics) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FUNCTION\tCCN\tNEST\tSTMTS\tPARAMS\tRETURNS\tFANOUT\tGLOBALS")
+	for _, m := range ms {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			m.Name, m.Complexity, m.MaxNesting, m.Statements, m.Params, m.Returns, m.FanOut, m.Globals)
+	}
+	w.Flush()
+}
+
+func printCoverage(out io.Writer, c *symc.Coverage) {
+	fmt.Fprintf(out, "tokens: %d, parsed: %d, skipped: %d (%.1f%% parsed)\n\n", c.Tokens, c.Parsed, c.Skipped, c.Ratio()*100)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tTOKENS")
+	for _, r := range c.SortedRules() {
+		fmt.Fprintf(w, "%s\t%d\n", r, c.Rules[r])
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tEND\tRULE\tTOKENS")
+	for _, r := range c.Regions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", r.Start, r.End, r.Rule, r.Tokens)
+	}
+	w.Flush()
+}
+
+func printStrings(out io.Writer, ss []*symc.StringLit) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FUNCTION\tCALL\tARG\tVALUE")
+	for _, s := range ss {
+		arg := ""
+		if s.Arg >= 0 {
+			arg = fmt.Sprintf("%d", s.Arg)
 		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%q\n", s.Func, s.Call, arg, s.Value)
 	}
+	w.Flush()
 }
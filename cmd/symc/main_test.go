@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kita127/symc"
+)
+
+// runTest run の呼び出しひとつ. DIR は入力のディレクトリに置き換える
+type runTest struct {
+	comment string
+	args    []string
+	stdin   string
+	output  string // -o で書き出すファイル. 空の場合は標準出力を比較する
+	code    int
+	stdout  string
+	stderr  string // 標準エラー出力の先頭
+}
+
+// writeInputs
+// 一時ディレクトリに入力のファイルを作り, そのディレクトリを返す
+func writeInputs(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.c":          "int a;\nint f(void) { return a; }\n",
+		"b.c":          "int b;\nvoid g(void) { f(); b = 1; }\n",
+		"bad.c":        "int x;\n}\n",
+		"sub/c.h":      "int c;\n",
+		"sub/note.txt": "not a source file\n",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// testRun
+func testRun(t *testing.T, dir string, testTbl []runTest) {
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		args := []string{}
+		for _, a := range tt.args {
+			args = append(args, strings.ReplaceAll(a, "DIR", dir))
+		}
+		var stdout, stderr bytes.Buffer
+		code := run(args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("got code=%d, expect=%d, stderr=%s", code, tt.code, stderr.String())
+		}
+		actual := stdout.String()
+		if tt.output != "" {
+			if actual != "" {
+				t.Errorf("got stdout=%q", actual)
+			}
+			b, err := ioutil.ReadFile(strings.ReplaceAll(tt.output, "DIR", dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual = string(b)
+		}
+		if expect := strings.ReplaceAll(tt.stdout, "DIR", dir); actual != expect {
+			t.Errorf("got stdout=%q, expect=%q", actual, expect)
+		}
+		if expect := strings.ReplaceAll(tt.stderr, "DIR", dir); !strings.HasPrefix(stderr.String(), expect) || expect == "" && stderr.Len() > 0 {
+			t.Errorf("got stderr=%q, expect=%q", stderr.String(), expect)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	testRun(t, writeInputs(t), []runTest{
+		{
+			"standard input",
+			[]string{},
+			"int s;\n",
+			"",
+			exitOK,
+			"DEFINITION s\n\n",
+			"",
+		},
+		{
+			"file",
+			[]string{"DIR/a.c"},
+			"",
+			"",
+			exitOK,
+			"DEFINITION a\nFUNC f() {\n    a\n}\n\n\n",
+			"",
+		},
+		{
+			"glob",
+			[]string{"DIR/[ab].c"},
+			"",
+			"",
+			exitOK,
+			"DIR/a.c:\nDEFINITION a\nFUNC f() {\n    a\n}\n\n\nDIR/b.c:\nDEFINITION b\nFUNC g() {\n    f()\n    ASSIGNE b\n}\n\n\n",
+			"",
+		},
+		{
+			"directory",
+			[]string{"DIR/sub"},
+			"",
+			"",
+			exitOK,
+			"DEFINITION c\n\n",
+			"",
+		},
+		{
+			"output file",
+			[]string{"-o", "DIR/out.txt", "-format", "csv", "DIR/a.c"},
+			"",
+			"DIR/out.txt",
+			exitOK,
+			"file,function,symbol,kind,line,column\nDIR/a.c,,a,definition,1,5\nDIR/a.c,,f,definition,2,5\nDIR/a.c,f,a,read,2,22\n",
+			"",
+		},
+		{
+			"json without html escaping",
+			[]string{"-format", "json", "-quiet"},
+			"int x \"a<b\";\n",
+			"",
+			exitError,
+			"{\n  \"version\": 1,\n  \"statements\": [\n    {\n      \"kind\": \"InvalidStatement\",\n      \"contents\": \"<stdin>:1:7: expected ';' before '\\\"a<b\\\"' token\",\n      \"token\": \"\\\"a<b\\\"\",\n      \"start\": {\n        \"file\": \"<stdin>\",\n        \"offset\": 0,\n        \"line\": 1,\n        \"column\": 1\n      },\n      \"end\": {\n        \"file\": \"<stdin>\",\n        \"offset\": 12,\n        \"line\": 1,\n        \"column\": 13\n      }\n    }\n  ]\n}\n",
+			"",
+		},
+		{
+			"unwritable output file",
+			[]string{"-o", "DIR/none/out.txt", "DIR/a.c"},
+			"",
+			"",
+			exitError,
+			"",
+			"symc: open DIR/none/out.txt: no such file or directory\n",
+		},
+		{
+			"parse error",
+			[]string{"DIR/bad.c"},
+			"",
+			"",
+			exitError,
+			"DEFINITION x\nInvalidStatement : DIR/bad.c:2:1: expected type name and identifier before '}' token\n\n",
+			"DIR/bad.c:2:1: error: expected type name and identifier before '}' token\n}\n^\n",
+		},
+		{
+			"parse error without diagnostics",
+			[]string{"-quiet", "DIR/bad.c"},
+			"",
+			"",
+			exitError,
+			"DEFINITION x\nInvalidStatement : DIR/bad.c:2:1: expected type name and identifier before '}' token\n\n",
+			"",
+		},
+		{
+			"parse error in metrics mode",
+			[]string{"-metrics", "DIR/bad.c"},
+			"",
+			"",
+			exitError,
+			"FUNCTION  CCN  NEST  STMTS  PARAMS  RETURNS  FANOUT  GLOBALS\n",
+			"DIR/bad.c:2:1: error:",
+		},
+		{
+			"strict metrics",
+			[]string{"-metrics", "-strict"},
+			"int f(void) { T * p; }\n",
+			"",
+			exitError,
+			"FUNCTION  CCN  NEST  STMTS  PARAMS  RETURNS  FANOUT  GLOBALS\n",
+			"<stdin>:1:15: error: expected unambiguous declaration before 'T' token\n",
+		},
+		{
+			"file and directory",
+			[]string{"DIR/a.c", "DIR/sub"},
+			"",
+			"",
+			exitOK,
+			"DIR/a.c:\nDEFINITION a\nFUNC f() {\n    a\n}\n\n\nDIR/sub/c.h:\nDEFINITION c\n\n",
+			"",
+		},
+		{
+			"missing file",
+			[]string{"DIR/none.c"},
+			"",
+			"",
+			exitUsage,
+			"",
+			"symc: stat DIR/none.c: no such file or directory\n",
+		},
+		{
+			"standard input twice",
+			[]string{"-", "DIR/a.c", "-"},
+			"int s;\n",
+			"",
+			exitUsage,
+			"",
+			"symc: standard input (-) given more than once\n",
+		},
+		{
+			"glob without match",
+			[]string{"DIR/*.x"},
+			"",
+			"",
+			exitUsage,
+			"",
+			"symc: no files match \"DIR/*.x\"\n",
+		},
+		{
+			"unknown flag",
+			[]string{"-none"},
+			"",
+			"",
+			exitUsage,
+			"",
+			"flag provided but not defined: -none\n",
+		},
+		{
+			"unknown format",
+			[]string{"-format", "xml"},
+			"",
+			"",
+			exitUsage,
+			"",
+			"symc: unknown format \"xml\"\n",
+		},
+		{
+			"metrics with another format",
+			[]string{"-metrics", "-format", "json", "DIR/a.c"},
+			"",
+			"",
+			exitUsage,
+			"",
+			"symc: -format json cannot be used with -metrics, -strings or -coverage\n",
+		},
+		{
+			"metrics with strings",
+			[]string{"-metrics", "-strings", "DIR/a.c"},
+			"",
+			"",
+			exitUsage,
+			"",
+			"symc: -metrics, -strings and -coverage cannot be combined\n",
+		},
+	})
+}
+
+func TestRunWriteError(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full")
+	}
+	testRun(t, writeInputs(t), []runTest{
+		{
+			"text",
+			[]string{"-o", "/dev/full", "DIR/a.c"},
+			"",
+			"",
+			exitError,
+			"",
+			"symc: write /dev/full: no space left on device\n",
+		},
+		{
+			"csv",
+			[]string{"-o", "/dev/full", "-format", "csv", "DIR/a.c"},
+			"",
+			"",
+			exitError,
+			"",
+			"symc: write /dev/full: no space left on device\n",
+		},
+		{
+			"metrics",
+			[]string{"-o", "/dev/full", "-metrics", "DIR/a.c"},
+			"",
+			"",
+			exitError,
+			"",
+			"symc: write /dev/full: no space left on device\n",
+		},
+		{
+			"query in json",
+			[]string{"defs", "-o", "/dev/full", "-format", "json", "DIR/a.c"},
+			"",
+			"",
+			exitError,
+			"",
+			"symc: write /dev/full: no space left on device\n",
+		},
+	})
+}
+
+func TestRunFormats(t *testing.T) {
+	dir := writeInputs(t)
+
+	// SCIP はライブラリで同じ入力から作った索引と比較する
+	a := filepath.Join(dir, "a.c")
+	prog, _ := symc.ParseFiles(context.Background(), []string{a}, symc.ParseFilesOptions{Parse: symc.ParseOptions{Positions: true}})
+	root, _ := os.Getwd()
+	read := func(file string) (string, error) {
+		b, err := ioutil.ReadFile(file)
+		return string(b), err
+	}
+	var scip bytes.Buffer
+	symc.WriteSCIP(&scip, symc.BuildIndex(prog.Modules, symc.IndexOptions{ProjectRoot: root, Read: read}))
+
+	testRun(t, dir, []runTest{
+		{
+			"json",
+			[]string{"-format", "json", "DIR/a.c"},
+			"",
+			"",
+			exitOK,
+			"{\n  \"version\": 1,\n  \"statements\": [\n    {\n      \"kind\": \"VariableDef\",\n      \"name\": \"a\",\n      \"pos\": {\n        \"file\": \"DIR/a.c\",\n        \"offset\": 4,\n        \"line\": 1,\n        \"column\": 5\n      }\n    },\n    {\n      \"kind\": \"FunctionDef\",\n      \"name\": \"f\",\n      \"pos\": {\n        \"file\": \"DIR/a.c\",\n        \"offset\": 11,\n        \"line\": 2,\n        \"column\": 5\n      },\n      \"params\": [],\n      \"statements\": [\n        {\n          \"kind\": \"RefVar\",\n          \"name\": \"a\",\n          \"pos\": {\n            \"file\": \"DIR/a.c\",\n            \"offset\": 28,\n            \"line\": 2,\n            \"column\": 22\n          }\n        }\n      ],\n      \"labels\": [],\n      \"gotos\": [],\n      \"oldStyle\": false\n    }\n  ]\n}\n",
+			"",
+		},
+		{
+			"ctags",
+			[]string{"-format", "ctags", "DIR/[ab].c"},
+			"",
+			"",
+			exitOK,
+			"!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/\n" +
+				"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n" +
+				"!_TAG_PROGRAM_NAME\tsymc\t//\n" +
+				"a\tDIR/a.c\t/^int a;$/;\"\tkind:v\tline:1\n" +
+				"b\tDIR/b.c\t/^int b;$/;\"\tkind:v\tline:1\n" +
+				"f\tDIR/a.c\t/^int f(void) { return a; }$/;\"\tkind:f\tline:2\n" +
+				"g\tDIR/b.c\t/^void g(void) { f(); b = 1; }$/;\"\tkind:f\tline:2\n",
+			"",
+		},
+		{
+			"scip",
+			[]string{"-format", "scip", "-o", "DIR/index.scip", "DIR/a.c"},
+			"",
+			"DIR/index.scip",
+			exitOK,
+			scip.String(),
+			"",
+		},
+		{
+			"dot",
+			[]string{"-format", "dot", "DIR/[ab].c"},
+			"",
+			"",
+			exitOK,
+			"digraph callgraph {\n    node [shape=box];\n    \"f\";\n    \"g\";\n    \"g\" -> \"f\";\n}\n",
+			"",
+		},
+	})
+}
+
+func TestRunProject(t *testing.T) {
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("no cc")
+	}
+	dir := writeInputs(t)
+	db := fmt.Sprintf(`[
+  {"directory": %q, "command": "cc -c a.c", "file": "a.c"},
+  {"directory": %q, "arguments": ["cc", "-c", "b.c"], "file": "b.c"}
+]`, dir, dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "compile_commands.json"), []byte(db), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testRun(t, dir, []runTest{
+		{
+			"text",
+			[]string{"-project", "DIR/compile_commands.json"},
+			"",
+			"",
+			exitOK,
+			"DIR/a.c:\nDEFINITION a\nFUNC f() {\n    a\n}\n\n\nDIR/b.c:\nDEFINITION b\nFUNC g() {\n    f()\n    ASSIGNE b\n}\n\n\n",
+			"",
+		},
+		{
+			"csv with linemarker paths",
+			[]string{"-project", "DIR/compile_commands.json", "-format", "csv"},
+			"",
+			"",
+			exitOK,
+			"file,function,symbol,kind,line,column\na.c,,a,definition,1,5\na.c,,f,definition,2,5\na.c,f,a,read,2,22\nb.c,,b,definition,1,5\nb.c,,g,definition,2,6\nb.c,g,f,call,2,16\nb.c,g,b,write,2,21\n",
+			"",
+		},
+		{
+			"ctags reads the files relative to the unit directory",
+			[]string{"-project", "DIR/compile_commands.json", "-format", "ctags"},
+			"",
+			"",
+			exitOK,
+			"!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/\n" +
+				"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n" +
+				"!_TAG_PROGRAM_NAME\tsymc\t//\n" +
+				"a\ta.c\t/^int a;$/;\"\tkind:v\tline:1\n" +
+				"b\tb.c\t/^int b;$/;\"\tkind:v\tline:1\n" +
+				"f\ta.c\t/^int f(void) { return a; }$/;\"\tkind:f\tline:2\n" +
+				"g\tb.c\t/^void g(void) { f(); b = 1; }$/;\"\tkind:f\tline:2\n",
+			"",
+		},
+		{
+			"query",
+			[]string{"callers", "f", "-project", "DIR/compile_commands.json"},
+			"",
+			"",
+			exitOK,
+			"b.c:2:16: call f in g\n",
+			"",
+		},
+		{
+			"missing database",
+			[]string{"-project", "DIR/none.json"},
+			"",
+			"",
+			exitUsage,
+			"",
+			"symc: open DIR/none.json: no such file or directory\n",
+		},
+		{
+			"file arguments with project",
+			[]string{"-project", "DIR/compile_commands.json", "DIR/a.c"},
+			"",
+			"",
+			exitUsage,
+			"",
+			"symc: file arguments cannot be used with -project\n",
+		},
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// sink 出力先. 最初の書き込みエラーを記録する
+type sink struct {
+	w   io.Writer
+	f   *os.File // -o で作成したファイル
+	err error
+}
+
+// openSink
+// path が空の場合は stdout に, そうでない場合は作成したファイルに書き出す出力先を返す
+func openSink(path string, stdout io.Writer) (*sink, error) {
+	if path == "" {
+		return &sink{w: stdout}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &sink{w: f, f: f}, nil
+}
+
+func (s *sink) Write(b []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err := s.w.Write(b)
+	if err != nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// finish
+// 出力ファイルを閉じて終了コードを返す
+// 出力に失敗した場合は最初のエラーを出力して exitError とする. err は書き出した関数が返したエラー
+func (s *sink) finish(stderr io.Writer, err error, status int) int {
+	if err == nil {
+		err = s.err
+	}
+	if s.f != nil {
+		if cerr := s.f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "symc: %v\n", err)
+		return exitError
+	}
+	return status
+}
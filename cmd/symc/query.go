@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/kita127/symc"
+)
+
+// サブコマンドと名前の引数を取るか
+var subcommands = map[string]bool{
+	"defs":    false,
+	"refs":    true,
+	"callers": true,
+	"callees": true,
+	"writers": true,
+	"readers": true,
+}
+
+// jsonRecord 検索結果の JSON 表現. 列は RecordColumns と同じ
+type jsonRecord struct {
+	File     string `json:"file"`
+	Function string `json:"function"`
+	Symbol   string `json:"symbol"`
+	Kind     string `json:"kind"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// runQuery
+// 検索のサブコマンドを実行し, 終了コードを返す
+func runQuery(cmd string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("symc "+cmd, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	usage := "usage: symc " + cmd + " [flags] [file|dir|glob ...]"
+	if subcommands[cmd] {
+		usage = "usage: symc " + cmd + " NAME [flags] [file|dir|glob ...]"
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "%s\n\n", usage)
+		fs.PrintDefaults()
+	}
+	name := fs.String("file", "<stdin>", "file name used in diagnostics for standard input when it has no linemarkers")
+	format := fs.String("format", "text", "output format: text or json")
+	project := fs.String("project", "", "analyze every translation unit in this compile_commands.json instead of file arguments")
+	output := fs.String("o", "", "write output to this file instead of standard output")
+	quiet := fs.Bool("quiet", false, "do not print diagnostics; report failure only through the exit code")
+	color := fs.Bool("color", false, "colorize diagnostics")
+	strict := fs.Bool("strict", false, "report an error instead of guessing on ambiguous or ungrammatical declarations")
+	depth := 1
+	if cmd == "callees" {
+		fs.IntVar(&depth, "depth", 1, "follow calls this many levels deep (0 means unlimited)")
+	}
+
+	// フラグは名前やファイルの後にも置けるようにする
+	positional := []string{}
+	rest := args
+	for {
+		if err := fs.Parse(rest); err != nil {
+			if err == flag.ErrHelp {
+				return exitOK
+			}
+			return exitUsage
+		}
+		rest = fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		rest = rest[1:]
+	}
+
+	switch *format {
+	case "text", "json":
+	default:
+		fmt.Fprintf(stderr, "symc: unknown format %q\n", *format)
+		return exitUsage
+	}
+	target := ""
+	if subcommands[cmd] {
+		if len(positional) == 0 {
+			fs.Usage()
+			return exitUsage
+		}
+		target, positional = positional[0], positional[1:]
+	}
+
+	ins, err := loadInputs(*project, positional, *name, stdin, symc.ParseOptions{Strict: *strict, Positions: true})
+	if err != nil {
+		fmt.Fprintf(stderr, "symc: %v\n", err)
+		return exitUsage
+	}
+	out, err := openSink(*output, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "symc: %v\n", err)
+		return exitError
+	}
+
+	q := symc.NewQuery(ins.modules()...)
+
+	var rs []*symc.Record
+	switch cmd {
+	case "defs":
+		rs = q.Defs()
+	case "refs":
+		rs = q.Refs(target)
+	case "callers":
+		rs = q.Callers(target)
+	case "callees":
+		rs = q.Callees(target, depth)
+	case "writers":
+		rs = q.Writers(target)
+	case "readers":
+		rs = q.Readers(target)
+	}
+
+	if *format == "json" {
+		js := []*jsonRecord{}
+		for _, r := range rs {
+			js = append(js, &jsonRecord{File: r.File, Function: r.Function, Symbol: r.Symbol, Kind: string(r.Kind), Line: r.Pos.Line, Column: r.Pos.Column})
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(js)
+	} else {
+		for _, r := range rs {
+			fmt.Fprintln(out, formatRecord(r))
+		}
+	}
+
+	return out.finish(stderr, err, ins.report(stderr, *quiet, *color))
+}
+
+// formatRecord
+// "位置: 種類 名前 in 関数" の形式で一行にする
+func formatRecord(r *symc.Record) string {
+	s := r.File
+	if r.Pos.IsValid() {
+		s = r.Pos.String()
+	}
+	s += ": " + string(r.Kind) + " " + r.Symbol
+	if r.Function != "" {
+		s += " in " + r.Function
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRunQuery(t *testing.T) {
+	testRun(t, writeInputs(t), []runTest{
+		{
+			"defs",
+			[]string{"defs", "DIR/a.c"},
+			"",
+			"",
+			exitOK,
+			"DIR/a.c:1:5: definition a\nDIR/a.c:2:5: definition f\n",
+			"",
+		},
+		{
+			"refs across files in a directory",
+			[]string{"refs", "f", "DIR"},
+			"",
+			"",
+			exitError,
+			"DIR/b.c:2:16: call f in g\n",
+			"DIR/bad.c:2:1: error:",
+		},
+		{
+			"flags after positional arguments",
+			[]string{"callers", "f", "DIR/a.c", "DIR/b.c", "-format", "json"},
+			"",
+			"",
+			exitOK,
+			"[\n  {\n    \"file\": \"DIR/b.c\",\n    \"function\": \"g\",\n    \"symbol\": \"f\",\n    \"kind\": \"call\",\n    \"line\": 2,\n    \"column\": 16\n  }\n]\n",
+			"",
+		},
+		{
+			"callees to depth 2 from a glob",
+			[]string{"callees", "g", "-depth", "2", "DIR/[ab].c"},
+			"",
+			"",
+			exitOK,
+			"DIR/b.c:2:16: call f in g\n",
+			"",
+		},
+		{
+			"writers to an output file",
+			[]string{"writers", "b", "-o", "DIR/out.txt", "DIR/b.c"},
+			"",
+			"DIR/out.txt",
+			exitOK,
+			"DIR/b.c:2:21: write b in g\n",
+			"",
+		},
+		{
+			"readers from standard input",
+			[]string{"readers", "a", "-file", "s.c"},
+			"int a;\nint f(void) { return a; }\n",
+			"",
+			exitOK,
+			"s.c:2:22: read a in f\n",
+			"",
+		},
+		{
+			"parse error",
+			[]string{"writers", "x", "DIR/bad.c", "-quiet"},
+			"",
+			"",
+			exitError,
+			"",
+			"",
+		},
+		{
+			"unwritable output file",
+			[]string{"defs", "-o", "DIR/none/out.txt", "DIR/a.c"},
+			"",
+			"",
+			exitError,
+			"",
+			"symc: open DIR/none/out.txt: no such file or directory\n",
+		},
+		{
+			"without name",
+			[]string{"refs"},
+			"",
+			"",
+			exitUsage,
+			"",
+			"usage: symc refs NAME [flags] [file|dir|glob ...]\n",
+		},
+		{
+			"unknown format",
+			[]string{"defs", "DIR/a.c", "-format", "csv"},
+			"",
+			"",
+			exitUsage,
+			"",
+			"symc: unknown format \"csv\"\n",
+		},
+	})
+}
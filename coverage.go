@@ -0,0 +1,160 @@
+package symc
+
+// coverage モジュール
+// 実際に解析したトークンと, 解析せずに読み飛ばしたトークンを集計する
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Coverage モジュールひとつ分の解析の網羅状況
+type Coverage struct {
+	Tokens  int              // トークン数. コメントと終端は含まない
+	Parsed  int              // 解析したトークン数
+	Skipped int              // 読み飛ばしたトークン数
+	Rules   map[string]int   // 読み飛ばした規則ごとのトークン数
+	Regions []*SkippedRegion // 読み飛ばした範囲. 出現順
+}
+
+// SkippedRegion 同じ規則で連続して読み飛ばした範囲
+type SkippedRegion struct {
+	Rule   string
+	Start  Position
+	End    Position
+	Tokens int
+}
+
+func (v *SkippedRegion) String() string {
+	return fmt.Sprintf("%s-%s: %s (%d tokens)", v.Start, v.End, v.Rule, v.Tokens)
+}
+
+// Ratio
+// 解析したトークンの割合を返す. トークンがない場合は 1
+func (c *Coverage) Ratio() float64 {
+	if c.Tokens == 0 {
+		return 1
+	}
+	return float64(c.Parsed) / float64(c.Tokens)
+}
+
+// SortedRules
+// 読み飛ばした規則の名前を読み飛ばしたトークン数の多い順に返す
+func (c *Coverage) SortedRules() []string {
+	rs := []string{}
+	for r := range c.Rules {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		if c.Rules[rs[i]] != c.Rules[rs[j]] {
+			return c.Rules[rs[i]] > c.Rules[rs[j]]
+		}
+		return rs[i] < rs[j]
+	})
+	return rs
+}
+
+func (c *Coverage) String() string {
+	return fmt.Sprintf("Coverage : Tokens=%d, Parsed=%d, Skipped=%d, Ratio=%.1f%%", c.Tokens, c.Parsed, c.Skipped, c.Ratio()*100)
+}
+
+// skip
+// トークン範囲 [from, to) を規則 rule で読み飛ばしたものとして記録する
+func (p *Parser) skip(rule string, from, to int) {
+	if p.skipped == nil {
+		p.skipped = make([]string, len(p.tokens))
+	}
+	if from < 0 {
+		from = 0
+	}
+	// 終端のトークンは数えない
+	if to > len(p.tokens)-1 {
+		to = len(p.tokens) - 1
+	}
+	for i := from; i < to; i++ {
+		p.undo = append(p.undo, undoEntry{kind: undoSkipped, index: i, rule: p.skipped[i]})
+		p.skipped[i] = rule
+	}
+}
+
+// 試行の破棄で書き込む前の値に戻す記録の種類
+const (
+	undoSkipped = iota
+	undoString
+	undoGlobal
+)
+
+// undoEntry 解析の試行がトークンごとの記録に書き込む前の値
+type undoEntry struct {
+	kind  int
+	index int        // トークンの位置
+	rule  string     // 読み飛ばした規則
+	str   *StringLit // 文字列リテラル. なかった場合は nil
+	ref   string     // グローバル変数として参照した識別子. なかった場合は空
+}
+
+// attempt 解析の試行を始めた時点の記録の数
+type attempt struct {
+	metrics int
+	undo    int
+}
+
+// begin
+// 解析の試行を始める. 失敗した場合は返り値を discard に渡して記録を破棄する
+func (p *Parser) begin() attempt {
+	return attempt{metrics: len(p.metrics), undo: len(p.undo)}
+}
+
+// discard
+// 失敗した解析の試行が残した記録を, 試行が書き込んだものだけ新しい順に戻して破棄する
+func (p *Parser) discard(a attempt) {
+	p.metrics = p.metrics[:a.metrics]
+	if p.halted() {
+		// 打ち切った宣言の記録は使わない
+		return
+	}
+	for i := len(p.undo) - 1; i >= a.undo; i-- {
+		u := p.undo[i]
+		switch u.kind {
+		case undoSkipped:
+			p.skipped[u.index] = u.rule
+		case undoString:
+			if u.str == nil {
+				delete(p.strLits, u.index)
+			} else {
+				p.strLits[u.index] = u.str
+			}
+		case undoGlobal:
+			if u.ref == "" {
+				delete(p.globalRefs, u.index)
+			} else {
+				p.globalRefs[u.index] = u.ref
+			}
+		}
+	}
+	p.undo = p.undo[:a.undo]
+}
+
+// Coverage
+// 解析の網羅状況を返す
+func (p *Parser) Coverage() *Coverage {
+	c := &Coverage{Tokens: len(p.tokens) - 1, Rules: map[string]int{}, Regions: []*SkippedRegion{}}
+	var r *SkippedRegion
+	for i := 0; i < len(p.skipped); i++ {
+		rule := p.skipped[i]
+		if rule == "" {
+			r = nil
+			continue
+		}
+		c.Skipped++
+		c.Rules[rule]++
+		if r == nil || r.Rule != rule {
+			r = &SkippedRegion{Rule: rule, Start: p.position(p.spans[i].start)}
+			c.Regions = append(c.Regions, r)
+		}
+		r.End = p.position(p.spans[i].end)
+		r.Tokens++
+	}
+	c.Parsed = c.Tokens - c.Skipped
+	return c
+}
@@ -0,0 +1,107 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		expect  *Coverage
+	}{
+		{
+			"coverage all parsed",
+			`int a;
+int f(int x) { a = x; }
+`,
+			&Coverage{
+				Tokens:  15,
+				Parsed:  15,
+				Skipped: 0,
+				Rules:   map[string]int{},
+				Regions: []*SkippedRegion{},
+			},
+		},
+		{
+			"coverage skipped",
+			`typedef struct { int a; } T;
+int x __attribute__((aligned(4)));
+int y __asm("y_sym");
+extern int g(int) __attribute__((noreturn));
+__attribute__((unused)) static int h(void) { return sizeof(T); }
+int f(int v) {
+    switch (v) { case ONE: return 1; }
+    return 0;
+}
+int = = ;
+`,
+			&Coverage{
+				Tokens:  88,
+				Parsed:  45,
+				Skipped: 43,
+				Rules: map[string]int{
+					"skipStructureLike": 9,
+					"parseAttribute":    21,
+					"parseAsm":          4,
+					"parseSizeof":       4,
+					"parseValue":        1,
+					"synchronize":       4,
+				},
+				Regions: []*SkippedRegion{
+					{Rule: "skipStructureLike", Start: Position{Offset: 0, Line: 1, Column: 1}, End: Position{Offset: 28, Line: 1, Column: 29}, Tokens: 9},
+					{Rule: "parseAttribute", Start: Position{Offset: 35, Line: 2, Column: 7}, End: Position{Offset: 62, Line: 2, Column: 34}, Tokens: 9},
+					{Rule: "parseAsm", Start: Position{Offset: 70, Line: 3, Column: 7}, End: Position{Offset: 84, Line: 3, Column: 21}, Tokens: 4},
+					{Rule: "parseAttribute", Start: Position{Offset: 104, Line: 4, Column: 19}, End: Position{Offset: 129, Line: 4, Column: 44}, Tokens: 6},
+					{Rule: "parseAttribute", Start: Position{Offset: 131, Line: 5, Column: 1}, End: Position{Offset: 154, Line: 5, Column: 24}, Tokens: 6},
+					{Rule: "parseSizeof", Start: Position{Offset: 183, Line: 5, Column: 53}, End: Position{Offset: 192, Line: 5, Column: 62}, Tokens: 4},
+					{Rule: "parseValue", Start: Position{Offset: 233, Line: 7, Column: 23}, End: Position{Offset: 236, Line: 7, Column: 26}, Tokens: 1},
+					{Rule: "synchronize", Start: Position{Offset: 266, Line: 10, Column: 1}, End: Position{Offset: 275, Line: 10, Column: 10}, Tokens: 4},
+				},
+			},
+		},
+		{
+			"coverage initializer list and array size",
+			`char *tbl[] = {"a", b, c(d)};
+int (*fp[N])(int);
+`,
+			&Coverage{
+				Tokens:  29,
+				Parsed:  20,
+				Skipped: 9,
+				Rules: map[string]int{
+					"parseArrValue":       8,
+					"parseVariableDefSub": 1,
+				},
+				Regions: []*SkippedRegion{
+					{Rule: "parseArrValue", Start: Position{Offset: 15, Line: 1, Column: 16}, End: Position{Offset: 27, Line: 1, Column: 28}, Tokens: 8},
+					{Rule: "parseVariableDefSub", Start: Position{Offset: 39, Line: 2, Column: 10}, End: Position{Offset: 40, Line: 2, Column: 11}, Tokens: 1},
+				},
+			},
+		},
+		{
+			"coverage abandoned attempt",
+			"T (x [;\nint y;\n",
+			&Coverage{
+				Tokens:  8,
+				Parsed:  3,
+				Skipped: 5,
+				Rules: map[string]int{
+					"synchronize": 5,
+				},
+				Regions: []*SkippedRegion{
+					{Rule: "synchronize", Start: Position{Offset: 0, Line: 1, Column: 1}, End: Position{Offset: 7, Line: 1, Column: 8}, Tokens: 5},
+				},
+			},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		got := ParseCoverage(tt.src)
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("got=%v %v %v, expect=%v %v %v", got, got.Rules, got.Regions, tt.expect, tt.expect.Rules, tt.expect.Regions)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package symc
+
+import (
+	"fmt"
+)
+
+// Diagnostic 解析できずに読み飛ばしたソースの範囲
+type Diagnostic struct {
+	Start   Position
+	End     Position
+	Message string
+}
+
+func (v *Diagnostic) String() string {
+	return fmt.Sprintf("%s-%s: %s", v.Start, v.End, v.Message)
+}
@@ -0,0 +1,157 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecovery(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		expect  []Statement
+		diags   []*Diagnostic
+	}{
+		{
+			"recovery 1",
+			`int a;
+_Static_assert(sizeof(int) == 4, "int");
+int b;
+int f(void) { a[ = 2; }
+int g(void) { return b; }
+`,
+			[]Statement{
+				&VariableDef{Name: "a"},
+				&VariableDef{Name: "b"},
+				&FunctionDef{Name: "g",
+					Params: []*VariableDef{},
+					Statements: []Statement{
+						&RefVar{Name: "b"},
+					},
+				},
+			},
+			[]*Diagnostic{
+				{
+					Start:   Position{Offset: 7, Line: 2, Column: 1},
+					End:     Position{Offset: 47, Line: 2, Column: 41},
+					Message: "skipped unparsable declaration starting at '_Static_assert'",
+				},
+				{
+					Start:   Position{Offset: 55, Line: 4, Column: 1},
+					End:     Position{Offset: 78, Line: 4, Column: 24},
+					Message: "skipped unparsable declaration starting at 'int'",
+				},
+			},
+		},
+		{
+			"recovery 2",
+			`typedef struct { int a;
+int g;
+int f(void) { return g; }
+`,
+			[]Statement{},
+			[]*Diagnostic{
+				{
+					Start:   Position{Offset: 0, Line: 1, Column: 1},
+					End:     Position{Offset: 56, Line: 3, Column: 26},
+					Message: "skipped unparsable declaration starting at 'typedef'",
+				},
+			},
+		},
+		{
+			"recovery 3",
+			`struct s { int a;
+int g;
+int f(void) { return g; }
+`,
+			[]Statement{},
+			[]*Diagnostic{
+				{
+					Start:   Position{Offset: 0, Line: 1, Column: 1},
+					End:     Position{Offset: 50, Line: 3, Column: 26},
+					Message: "skipped unparsable declaration starting at 'struct'",
+				},
+			},
+		},
+		{
+			"recovery 4",
+			`typedef int T
+int g;
+int f(void) { return g; }
+`,
+			[]Statement{
+				&FunctionDef{Name: "f",
+					Params: []*VariableDef{},
+					Statements: []Statement{
+						&RefVar{Name: "g"},
+					},
+				},
+			},
+			[]*Diagnostic{
+				{
+					Start:   Position{Offset: 0, Line: 1, Column: 1},
+					End:     Position{Offset: 20, Line: 2, Column: 7},
+					Message: "skipped unparsable declaration starting at 'typedef'",
+				},
+			},
+		},
+		{
+			"recovery 5",
+			`struct s { int a; }
+void k(void) { int x; x = 1; }
+`,
+			[]Statement{
+				&FunctionDef{Name: "k",
+					Params: []*VariableDef{},
+					Statements: []Statement{
+						&VariableDef{Name: "x"},
+						&Assigne{Name: "x", Local: true},
+					},
+				},
+			},
+			[]*Diagnostic{
+				{
+					Start:   Position{Offset: 0, Line: 1, Column: 1},
+					End:     Position{Offset: 19, Line: 1, Column: 20},
+					Message: "skipped unparsable declaration starting at 'struct'",
+				},
+			},
+		},
+		{
+			"recovery 6",
+			`struct s { int a; } pts[10];
+struct s { int a; } v = {0}, *w;
+struct s *f(void) { return 0; }
+int g;
+`,
+			[]Statement{
+				&FunctionDef{Name: "f",
+					Params:     []*VariableDef{},
+					Statements: []Statement{},
+				},
+				&VariableDef{Name: "g"},
+			},
+			nil,
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		m, diags := ParseModuleDiagnostics(tt.src)
+		got := []Statement{}
+		for _, s := range m.Statements {
+			if _, ok := s.(*InvalidStatement); !ok {
+				got = append(got, s)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("\ngot=   %v\nexpect=%v\n", got, tt.expect)
+		}
+		if !reflect.DeepEqual(diags, tt.diags) {
+			t.Errorf("\ngot=   %v\nexpect=%v\n", diags, tt.diags)
+		}
+		if len(m.Statements)-len(got) != len(tt.diags) {
+			t.Errorf("got invalid statements=%d, expect=%d", len(m.Statements)-len(got), len(tt.diags))
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package symc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError 構文解析エラー
+type ParseError struct {
+	Pos      Position
+	Expected string   // 期待した構文要素
+	Found    string   // 実際に現れたトークン. ソースの終端の場合は空
+	Rules    []string // 外側から順に並べた解析中の文法規則
+	index    int      // トークンの位置
+}
+
+func (e *ParseError) Error() string {
+	found := "end of file"
+	if e.Found != "" {
+		found = fmt.Sprintf("'%s'", e.Found)
+	}
+	return fmt.Sprintf("%s: expected %s, found %s", e.Pos, e.Expected, found)
+}
+
+// Message
+// 位置を除いたコンパイラ形式のメッセージを返す
+func (e *ParseError) Message() string {
+	if e.Found == "" {
+		return fmt.Sprintf("expected %s at end of input", e.Expected)
+	}
+	return fmt.Sprintf("expected %s before '%s' token", e.Expected, e.Found)
+}
+
+// ErrorList 複数の構文解析エラー
+type ErrorList []*ParseError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	msgs := []string{}
+	for _, e := range l {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
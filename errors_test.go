@@ -0,0 +1,170 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		expect  []*ParseError
+	}{
+		{
+			"parse error 1",
+			`int a;
+int f(void) { x = 1 }
+int b;
+`,
+			[]*ParseError{
+				{
+					Pos:      Position{Offset: 27, Line: 2, Column: 21},
+					Expected: "';'",
+					Found:    "}",
+					Rules:    []string{"parseStatement", "parseFunctionDef", "parseBlockStatement", "parseInnerStatement", "parseExpressionStatement"},
+				},
+			},
+		},
+		{
+			"parse error 2",
+			`int f(void) { if (x) { y; }
+`,
+			[]*ParseError{
+				{
+					Pos:      Position{Offset: 28, Line: 2, Column: 1},
+					Expected: "'}'",
+					Found:    "",
+					Rules:    []string{"parseStatement", "parseFunctionDef", "parseBlockStatement"},
+				},
+			},
+		},
+		{
+			"parse error 3",
+			`int a;
+`,
+			[]*ParseError{},
+		},
+		{
+			"parse error 4",
+			`typedef struct { int a;
+int g;
+int f(void) { return g; }
+`,
+			[]*ParseError{
+				{
+					Pos:      Position{Offset: 57, Line: 4, Column: 1},
+					Expected: "'}'",
+					Found:    "",
+					Rules:    []string{"parseStatement", "skipStructureLike"},
+				},
+			},
+		},
+		{
+			"parse error 5",
+			`struct s { int a;
+int g;
+int f(void) { return g; }
+`,
+			[]*ParseError{
+				{
+					Pos:      Position{Offset: 51, Line: 4, Column: 1},
+					Expected: "'}'",
+					Found:    "",
+					Rules:    []string{"parseStatement", "skipStructureLike"},
+				},
+			},
+		},
+		{
+			"parse error 6",
+			`typedef int T
+int g;
+int f(void) { return g; }
+`,
+			[]*ParseError{
+				{
+					Pos:      Position{Offset: 14, Line: 2, Column: 1},
+					Expected: "';'",
+					Found:    "int",
+					Rules:    []string{"parseStatement", "skipStructureLike"},
+				},
+			},
+		},
+		{
+			"parse error 7",
+			`struct s { int a; }
+void k(void) { int x; x = 1; }
+`,
+			[]*ParseError{
+				{
+					Pos:      Position{Offset: 20, Line: 2, Column: 1},
+					Expected: "';'",
+					Found:    "void",
+					Rules:    []string{"parseStatement", "skipStructureLike"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		_, err := Parse(tt.src)
+		got := []*ParseError{}
+		if err != nil {
+			el, ok := err.(ErrorList)
+			if !ok {
+				t.Fatalf("got error type=%T", err)
+			}
+			got = el
+		}
+		if len(got) != len(tt.expect) {
+			t.Fatalf("got len=%v, expect len=%v", len(got), len(tt.expect))
+		}
+		for i, e := range tt.expect {
+			g := got[i]
+			if g.Pos != e.Pos || g.Expected != e.Expected || g.Found != e.Found || !reflect.DeepEqual(g.Rules, e.Rules) {
+				t.Errorf("\ngot=   %v %v\nexpect=%v %v\n", g, g.Rules, e, e.Rules)
+			}
+		}
+	}
+}
+
+func TestInvalidStatementContents(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		opts    ParseOptions
+		expect  string
+	}{
+		{
+			"missing semicolon",
+			"int f(void) { x = 1 }\nint a;",
+			ParseOptions{},
+			"InvalidStatement : 1:21: expected ';' before '}' token",
+		},
+		{
+			"end of input",
+			"int f(void) { if (x) { y; }\n",
+			ParseOptions{FileName: "hoge.c"},
+			"InvalidStatement : hoge.c:2:1: expected '}' at end of input",
+		},
+		{
+			"ambiguous declaration in strict mode",
+			"int f(void) { T * p; }",
+			ParseOptions{Strict: true},
+			"InvalidStatement : 1:15: expected unambiguous declaration before 'T' token",
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		m, _ := ParseWithOptions(tt.src, tt.opts)
+		if len(m.Statements) == 0 {
+			t.Fatalf("got no statements")
+		}
+		actual := m.Statements[0].PrettyString()
+		if actual != tt.expect {
+			t.Errorf("got=%v, expect=%v", actual, tt.expect)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package symc
+
+// files モジュール
+// 複数のファイルを並行に解析する
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+)
+
+// ParseFilesOptions 複数ファイルの解析オプション
+type ParseFilesOptions struct {
+	Parse   ParseOptions // FileName はファイルごとにパスを設定する
+	Workers int          // 同時に解析するファイルの数. 0 の場合は CPU 数
+	// ファイルひとつの処理を終えるごとに呼び出す. done は終えたファイルの数
+	// 呼び出しは直列化されるが, 呼び出し元のゴルーチンは不定
+	Progress func(done, total int, path string)
+}
+
+// ParseFiles
+// ファイルを読み込み, 最大 Workers 個並行に字句解析と構文解析を行う
+// 結果とエラーはゴルーチンの実行順によらず paths の順に並べる
+// 読み込めたファイルは解析エラーがあっても結果に含め, 失敗したファイルごとに *UnitError を返す
+// ctx が終了した場合は解析を打ち切ったファイルと未処理のファイルを除いた結果を返し, ctx のエラーは最後にひとつだけ返す
+func ParseFiles(ctx context.Context, paths []string, opts ParseFilesOptions) (*Program, []error) {
+	analyses := make([]*Analysis, len(paths))
+	errs := make([]error, len(paths))
+	var mu sync.Mutex
+	done := 0
+	runPool(len(paths), opts.Workers, func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		path := paths[i]
+		if b, err := ioutil.ReadFile(path); err != nil {
+			errs[i] = &UnitError{File: path, Err: err}
+		} else {
+			po := opts.Parse
+			po.FileName = path
+			analyses[i], err = Analyze(ctx, string(b), po)
+			if err != nil && err == ctx.Err() {
+				// 途中で打ち切った結果は含めない
+				analyses[i] = nil
+				return
+			}
+			if err != nil {
+				errs[i] = &UnitError{File: path, Err: err}
+			}
+		}
+		if opts.Progress != nil {
+			mu.Lock()
+			done++
+			opts.Progress(done, len(paths), path)
+			mu.Unlock()
+		}
+	})
+
+	prog := &Program{Files: []string{}, Modules: []*Module{}, Analyses: []*Analysis{}}
+	failures := []error{}
+	for i, path := range paths {
+		if a := analyses[i]; a != nil {
+			prog.Files = append(prog.Files, path)
+			prog.Modules = append(prog.Modules, a.Module)
+			prog.Analyses = append(prog.Analyses, a)
+		}
+		if errs[i] != nil {
+			failures = append(failures, errs[i])
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		failures = append(failures, err)
+	}
+	return prog, failures
+}
@@ -0,0 +1,127 @@
+package symc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{}
+	for i := 0; i < 20; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("f%02d.c", i))
+		src := fmt.Sprintf("int v%d;\nint f%d(void) { return v%d; }\n", i, i, i)
+		if i == 7 {
+			src = "int v7;\n}\n"
+		}
+		if err := ioutil.WriteFile(p, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	missing := filepath.Join(dir, "missing.c")
+	paths = append(paths[:3], append([]string{missing}, paths[3:]...)...)
+
+	for _, workers := range []int{1, 4, 0} {
+		t.Logf("workers %d", workers)
+		calls := []int{}
+		prog, errs := ParseFiles(context.Background(), paths, ParseFilesOptions{
+			Parse:   ParseOptions{Positions: true},
+			Workers: workers,
+			Progress: func(done, total int, path string) {
+				if total != len(paths) {
+					t.Errorf("unexpected total %d", total)
+				}
+				calls = append(calls, done)
+			},
+		})
+
+		expectFiles := append(append([]string{}, paths[:3]...), paths[4:]...)
+		if !reflect.DeepEqual(prog.Files, expectFiles) {
+			t.Errorf("expect %v, actual %v", expectFiles, prog.Files)
+		}
+		for i, m := range prog.Modules {
+			if v := m.Statements[0].(*VariableDef); v.Pos.File != prog.Files[i] {
+				t.Errorf("module %d: unexpected file %s", i, v.Pos.File)
+			}
+			if prog.Analyses[i].Module != m {
+				t.Errorf("module %d: unexpected analysis", i)
+			}
+		}
+		if len(errs) != 2 {
+			t.Fatalf("expect 2 errors, actual %v", errs)
+		}
+		if e := errs[0].(*UnitError); e.File != missing {
+			t.Errorf("unexpected error %v", e)
+		}
+		if e := errs[1].(*UnitError); e.File != paths[8] {
+			t.Errorf("unexpected error %v", e)
+		}
+		for i, d := range calls {
+			if d != i+1 {
+				t.Errorf("unexpected progress %v", calls)
+				break
+			}
+		}
+		if len(calls) != len(paths) {
+			t.Errorf("expect %d progress calls, actual %d", len(paths), len(calls))
+		}
+	}
+}
+
+func TestParseFilesCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	prog, errs := ParseFiles(ctx, []string{"a.c", "b.c"}, ParseFilesOptions{})
+	if len(prog.Modules) != 0 || !reflect.DeepEqual(errs, []error{context.Canceled}) {
+		t.Errorf("unexpected result %v, %v", prog, errs)
+	}
+}
+
+// cancelAfter Err を n 回呼び出した後に終了するコンテキスト
+type cancelAfter struct {
+	context.Context
+	mu sync.Mutex
+	n  int
+}
+
+func (c *cancelAfter) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestParseFilesCanceledDuringParse(t *testing.T) {
+	dir := t.TempDir()
+	short := filepath.Join(dir, "short.c")
+	long := filepath.Join(dir, "long.c")
+	src := ""
+	for i := 0; i < 1000; i++ {
+		src += fmt.Sprintf("int f%d(void) { return g(%d); }\n", i, i)
+	}
+	if err := ioutil.WriteFile(short, []byte("int a;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(long, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// short.c は最後まで解析し, long.c は解析の途中で打ち切る
+	ctx := &cancelAfter{Context: context.Background(), n: 4}
+	prog, errs := ParseFiles(ctx, []string{short, long}, ParseFilesOptions{Workers: 1})
+	if !reflect.DeepEqual(prog.Files, []string{short}) || len(prog.Modules) != 1 {
+		t.Errorf("unexpected files %v", prog.Files)
+	}
+	if !reflect.DeepEqual(errs, []error{context.Canceled}) {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
@@ -0,0 +1,142 @@
+package symc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// 解析器が任意の入力と解析オプションに対してパニックや無限ループを起こさないことを確認する
+// go test -fuzz=FuzzParseModule
+
+var fuzzSeeds = []string{
+	``,
+	`int a;`,
+	`int f(int a, char *b) { if (a && b) { return g(a, "x\n"); } else { a++; } }`,
+	`typedef struct { int x; } T; extern T t; void (*signal(int, void (*)(int)))(int);`,
+	`int f(a, b) int a; char *b; { goto out; out: return a; }`,
+	`int f(int x) { int y = ({ int _t = x; _t * 2; }); struct p q = (struct p){ .x = 1 }; }`,
+	`__attribute__((__availability__(swift, unavailable, message="Use mkstemp(3) instead.")))`,
+	`FILE *fopen(const char * restrict, const char * restrict) __asm("_" "fopen" );`,
+	"# 1 \"a.c\"\nint a = 0x1f + 07 + 0.5 + 'c' + '\\033';",
+	`int f(void) { switch (x) { case 1: break; default: break; } do { x--; } while (x); }`,
+	`'`,
+	`"abc\`,
+	`0`,
+	`__attribute__((`,
+	`int f(void) { x = 1 } int q;`,
+	`@ $ ` + "`",
+}
+
+// fuzzOptions
+// ファジングの値の各ビットから解析オプションを作る
+func fuzzOptions(flags byte) ParseOptions {
+	opts := ParseOptions{
+		Strict:            flags&0x01 != 0,
+		Positions:         flags&0x02 != 0,
+		SkipSystemHeaders: flags&0x04 != 0,
+	}
+	if flags&0x08 != 0 {
+		opts.Dialect = DialectISO
+	}
+	if flags&0x10 != 0 {
+		opts.FileName = "fuzz.c"
+	}
+	if flags&0x20 != 0 {
+		opts.MaxSteps = 200
+	}
+	return opts
+}
+
+func FuzzParseModule(f *testing.F) {
+	for i, s := range fuzzSeeds {
+		f.Add(s, byte(0))
+		f.Add(s, byte(i*0x0b))
+	}
+	f.Add("# 1 \"/usr/include/stdio.h\" 1 3 4\nint printf(const char *, ...);\n# 2 \"a.c\" 2\nstruct s v;", byte(0x17))
+	f.Fuzz(func(t *testing.T, src string, flags byte) {
+		l := NewLexer(src)
+		p := NewParserWithOptions(l, fuzzOptions(flags))
+		m := p.Parse()
+		if m == nil {
+			t.Fatalf("module is nil")
+		}
+		for _, d := range p.Diagnostics() {
+			if d.Start.Offset < 0 || d.End.Offset > len(src) || d.Start.Offset > d.End.Offset {
+				t.Fatalf("invalid diagnostic span %v", d)
+			}
+		}
+		_ = m.String()
+		_ = m.PrettyString()
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("marshal err=%v", err)
+		}
+		if _, err := UnmarshalModule(b); err != nil {
+			t.Fatalf("unmarshal err=%v", err)
+		}
+	})
+}
+
+// JSON の復元が任意の入力に対してパニックを起こさないことを確認する
+// go test -fuzz=FuzzUnmarshalModule
+func FuzzUnmarshalModule(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		m, _ := ParseWithOptions(s, ParseOptions{FileName: "fuzz.c", Positions: true})
+		b, err := json.Marshal(m)
+		if err != nil {
+			f.Fatalf("marshal err=%v", err)
+		}
+		f.Add(b)
+	}
+	for _, s := range []string{
+		``,
+		`null`,
+		`{"version":1}`,
+		`{"version":1,"statements":[null]}`,
+		`{"version":1,"statements":[{"kind":"FunctionDef","name":"f","params":[null],"labels":[null],"gotos":[null]}]}`,
+		`{"version":1,"statements":[{"kind":"CallFunc","name":"f","args":[{"kind":"InvalidStatement","start":{},"end":null}]}]}`,
+	} {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := UnmarshalModule(data)
+		if err != nil {
+			return
+		}
+		if m == nil {
+			t.Fatalf("module is nil")
+		}
+		_ = m.String()
+		_ = m.PrettyString()
+		if _, err := json.Marshal(m); err != nil {
+			t.Fatalf("marshal err=%v", err)
+		}
+	})
+}
+
+func FuzzLexer(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		l := NewLexer(src)
+		ts := l.lexicalize()
+		if len(ts) == 0 || !ts[len(ts)-1].isToken(eof) {
+			t.Fatalf("token list must end with eof")
+		}
+		if len(ts) != len(l.spans) {
+			t.Fatalf("got spans len=%v, expect len=%v", len(l.spans), len(ts))
+		}
+		prev := 0
+		for i, tk := range ts {
+			if tk == nil {
+				t.Fatalf("token %d is nil", i)
+			}
+			sp := l.spans[i]
+			if sp.start < prev || sp.end < sp.start || sp.end > len(src) {
+				t.Fatalf("invalid span %v for token %v", sp, tk)
+			}
+			prev = sp.start
+		}
+	})
+}
@@ -1,3 +1,3 @@
 module github.com/kita127/symc
 
-go 1.15
+go 1.18
@@ -0,0 +1,379 @@
+package symc
+
+// incremental モジュール
+// 編集箇所に重なるトップレベルの宣言のみを解析し直す
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Edit テキストの編集
+type Edit struct {
+	Offset   int    // 編集を開始したバイト位置
+	Removed  int    // 削除したバイト数
+	Inserted string // 挿入した文字列
+}
+
+// トップレベルの宣言ひとつ分の解析結果
+type topDecl struct {
+	start int // 宣言のソース上の範囲 [start, end)
+	end   int
+	reach int // 解析中に参照した最も先のトークンの終端
+	stmts []Statement
+	errs  []*ParseError
+	names scope // ファイルスコープに宣言した識別子
+}
+
+// Tree 差分解析のために宣言ごとの範囲と共に保持した解析結果
+type Tree struct {
+	Module   *Module
+	Errors   []*ParseError
+	src      string
+	opts     ParseOptions
+	markers  []lineMarker
+	decls    []*topDecl
+	halt     error
+	reparsed int // 直前の解析で解析した宣言の数
+}
+
+// ParseTree
+// 差分解析が可能な形でソースを解析する
+func ParseTree(src string, opts ParseOptions) (*Tree, error) {
+	l := NewLexer(src)
+	p := NewParserWithOptions(l, opts)
+	m := p.Parse()
+	t := &Tree{
+		Module:   m,
+		Errors:   p.Errors(),
+		src:      src,
+		opts:     opts,
+		markers:  p.markers,
+		decls:    p.decls,
+		halt:     p.halt,
+		reparsed: len(p.decls),
+	}
+	return t, t.err()
+}
+
+// Source
+// 解析したソースを返す
+func (t *Tree) Source() string {
+	return t.src
+}
+
+// err
+func (t *Tree) err() error {
+	if t.halt != nil {
+		return t.halt
+	}
+	if len(t.Errors) > 0 {
+		return ErrorList(t.Errors)
+	}
+	return nil
+}
+
+// Reparse
+// 編集を適用したソースを解析する
+// 編集箇所に重ならない宣言は前回の結果を再利用し, 重なる宣言のみを字句解析・構文解析し直す
+func (t *Tree) Reparse(e Edit) (*Tree, error) {
+	if e.Offset < 0 || e.Removed < 0 || e.Offset+e.Removed > len(t.src) {
+		return nil, fmt.Errorf("edit out of range: offset %d, removed %d, source length %d", e.Offset, e.Removed, len(t.src))
+	}
+	src := t.src[:e.Offset] + e.Inserted + t.src[e.Offset+e.Removed:]
+	if t.halt != nil {
+		// 前回打ち切った場合は全体を解析し直す
+		return ParseTree(src, t.opts)
+	}
+	delta := len(e.Inserted) - e.Removed
+	editEnd := e.Offset + e.Removed
+
+	// 編集箇所に重なる宣言の範囲 [i, j) を求める
+	// 先読みやエラー回復で参照したトークンが編集箇所に掛かる宣言も解析し直す
+	i := 0
+	for i < len(t.decls) && t.decls[i].reach < e.Offset {
+		i++
+	}
+	j := i
+	for j < len(t.decls) && t.decls[j].start <= editEnd {
+		j++
+	}
+	if i > 0 && !endsDecl(t.src[:t.decls[i-1].end]) {
+		// 直前の宣言が途中で終わっている場合は宣言の区切りが変わりうるため全体を解析し直す
+		return ParseTree(src, t.opts)
+	}
+
+	lines := newLineTable(src)
+	var p *Parser
+	for {
+		rs := 0
+		if i > 0 {
+			rs = t.decls[i-1].end
+		}
+		re := len(t.src)
+		if j < len(t.decls) {
+			re = t.decls[j].start
+		}
+
+		var prefix, region, suffix []lineMarker
+		for _, m := range t.markers {
+			if m.offset <= rs {
+				prefix = append(prefix, m)
+			} else if m.offset <= re {
+				region = append(region, m)
+			} else {
+				m.offset += delta
+				suffix = append(suffix, m)
+			}
+		}
+
+		var touch bool
+		p, touch = parseRegion(src, rs, re+delta, lines, t.opts, prefix, suffix, mergeNames(t.decls[:i]))
+		if p.halted() || !sameMarkers(region, p.markers[len(prefix):len(p.markers)-len(suffix)]) {
+			// 後続の宣言の位置付けが変わりうる場合は全体を解析し直す
+			return ParseTree(src, t.opts)
+		}
+		if j < len(t.decls) && mentionsAny(src[re+delta:], changedNames(mergeNames(t.decls[i:j]), mergeNames(p.decls))) {
+			// 識別子の宣言が変わると後続の宣言の解釈が変わりうるため全体を解析し直す
+			return ParseTree(src, t.opts)
+		}
+		reach := rs
+		for _, d := range p.decls {
+			if d.reach > reach {
+				reach = d.reach
+			}
+		}
+		if j < len(t.decls) && (touch || len(p.decls) == 0 || reach >= re+delta) {
+			// 末尾のトークンや宣言が後続の宣言まで続いている可能性があるため範囲を広げる
+			j++
+			continue
+		}
+		break
+	}
+
+	n := &Tree{src: src, opts: t.opts, markers: p.markers, reparsed: len(p.decls)}
+	n.decls = append(n.decls, t.decls[:i]...)
+	n.decls = append(n.decls, p.decls...)
+	shift := delta != 0 || strings.Count(t.src[e.Offset:editEnd], "\n") != strings.Count(e.Inserted, "\n")
+	for _, d := range t.decls[j:] {
+		if shift {
+			d = shiftDecl(d, delta, p.position)
+		}
+		n.decls = append(n.decls, d)
+	}
+
+	ss := []Statement{}
+	for _, d := range n.decls {
+		ss = append(ss, d.stmts...)
+		n.Errors = append(n.Errors, d.errs...)
+	}
+	n.Module = &Module{ss}
+	return n, n.err()
+}
+
+// parseRegion
+// src の [start, end) の範囲のみを解析する
+// 位置情報は src 全体の行と前後の行マーカーから求める
+// names は先行する宣言で宣言したファイルスコープの識別子
+// 末尾のトークンが範囲の終端に接している(後続と連結しうる)かを合わせて返す
+func parseRegion(src string, start, end int, lines lineTable, opts ParseOptions, prefix, suffix []lineMarker, names scope) (*Parser, bool) {
+	l := NewLexer(src[start:end])
+	l.maxTokens = opts.MaxTokens
+	tks := l.lexicalize()
+	spans := make([]span, len(l.spans))
+	for i, v := range l.spans {
+		spans[i] = span{v.start + start, v.end + start}
+	}
+
+	p := &Parser{lexer: l, tokens: tks, spans: spans, lines: lines, opts: opts}
+	p.scopes = []scope{names}
+	p.markers = append(p.markers, prefix...)
+	p.markers = append(p.markers, lineMarkers(tks, spans)...)
+	p.markers = append(p.markers, suffix...)
+	if l.truncated {
+		p.abort(&LimitError{Pos: p.position(spans[len(spans)-1].start), Limit: "token", Max: opts.MaxTokens})
+	}
+	touch := len(spans) > 1 && spans[len(spans)-2].end >= end
+	p.Parse()
+	return p, touch
+}
+
+// endsDecl
+// ソースが宣言の終端となる ; もしくは } で終わっているか
+func endsDecl(src string) bool {
+	return strings.HasSuffix(src, ";") || strings.HasSuffix(src, "}")
+}
+
+// mergeNames
+// 宣言の並びでファイルスコープに宣言した識別子をまとめる
+func mergeNames(ds []*topDecl) scope {
+	names := scope{}
+	for _, d := range ds {
+		for k, v := range d.names {
+			names[k] = v
+		}
+	}
+	return names
+}
+
+// changedNames
+// 宣言の有無か型名か否かが変わった識別子を返す
+func changedNames(a, b scope) []string {
+	names := []string{}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			names = append(names, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	return names
+}
+
+// mentionsAny
+// text が names のいずれかを識別子として含むか
+func mentionsAny(text string, names []string) bool {
+	for _, name := range names {
+		for i := 0; ; {
+			k := strings.Index(text[i:], name)
+			if k < 0 {
+				break
+			}
+			k += i
+			end := k + len(name)
+			if (k == 0 || !isIdentByte(text[k-1])) && (end == len(text) || !isIdentByte(text[end])) {
+				return true
+			}
+			i = k + 1
+		}
+	}
+	return false
+}
+
+// isIdentByte
+func isIdentByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+// sameMarkers
+// 行マーカーの並びが同じ位置付けを表すか
+func sameMarkers(a, b []lineMarker) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].line != b[i].line || a[i].file != b[i].file || a[i].system != b[i].system {
+			return false
+		}
+	}
+	return true
+}
+
+// shiftDecl
+// 編集位置より後ろの宣言の位置情報をずらした複製を返す
+func shiftDecl(d *topDecl, delta int, position func(int) Position) *topDecl {
+	f := func(pos Position) Position {
+		if !pos.IsValid() {
+			return pos
+		}
+		return position(pos.Offset + delta)
+	}
+	n := &topDecl{start: d.start + delta, end: d.end + delta, reach: d.reach + delta, names: d.names}
+	n.stmts = shiftStatements(d.stmts, f)
+	for _, e := range d.errs {
+		c := *e
+		c.Pos = f(c.Pos)
+		n.errs = append(n.errs, &c)
+	}
+	// 解析できなかった宣言の内容はずらしたエラーで作り直す
+	for _, s := range n.stmts {
+		if v, ok := s.(*InvalidStatement); ok && len(n.errs) > 0 {
+			v.Contents = errorContents(n.errs[len(n.errs)-1])
+		}
+	}
+	return n
+}
+
+// shiftStatements
+// 文に含まれる位置情報を f で置き換えた複製を返す
+func shiftStatements(ss []Statement, f func(Position) Position) []Statement {
+	if ss == nil {
+		return nil
+	}
+	ts := make([]Statement, 0, len(ss))
+	for _, s := range ss {
+		ts = append(ts, shiftStatement(s, f))
+	}
+	return ts
+}
+
+// shiftStatement
+func shiftStatement(s Statement, f func(Position) Position) Statement {
+	switch v := s.(type) {
+	case *InvalidStatement:
+		c := *v
+		c.Start = f(c.Start)
+		c.End = f(c.End)
+		return &c
+	case *VariableDef:
+		c := *v
+		c.Pos = f(c.Pos)
+		return &c
+	case *VariableDecl:
+		c := *v
+		c.Pos = f(c.Pos)
+		return &c
+	case *PrototypeDecl:
+		c := *v
+		c.Pos = f(c.Pos)
+		return &c
+	case *RefVar:
+		c := *v
+		c.Pos = f(c.Pos)
+		return &c
+	case *Assigne:
+		c := *v
+		c.Pos = f(c.Pos)
+		return &c
+	case *Typedef:
+		c := *v
+		c.Pos = f(c.Pos)
+		return &c
+	case *CallFunc:
+		c := *v
+		c.Pos = f(c.Pos)
+		c.Args = shiftStatements(v.Args, f)
+		return &c
+	case *FunctionDef:
+		c := *v
+		c.Pos = f(c.Pos)
+		c.Statements = shiftStatements(v.Statements, f)
+		if v.Params != nil {
+			c.Params = make([]*VariableDef, 0, len(v.Params))
+			for _, x := range v.Params {
+				c.Params = append(c.Params, shiftStatement(x, f).(*VariableDef))
+			}
+		}
+		if v.Labels != nil {
+			c.Labels = make([]*Label, 0, len(v.Labels))
+			for _, x := range v.Labels {
+				l := *x
+				l.Pos = f(l.Pos)
+				c.Labels = append(c.Labels, &l)
+			}
+		}
+		if v.Gotos != nil {
+			c.Gotos = make([]*Goto, 0, len(v.Gotos))
+			for _, x := range v.Gotos {
+				g := *x
+				g.Pos = f(g.Pos)
+				c.Gotos = append(c.Gotos, &g)
+			}
+		}
+		return &c
+	}
+	return s
+}
@@ -0,0 +1,209 @@
+package symc
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const incrementalSrc = `# 1 "hoge.c"
+int a;
+int b = 1;
+
+int f(int x) {
+    a = x;
+    return g(a);
+}
+
+int c;
+void h(void);
+int k(void) { err: goto err; }
+`
+
+func TestReparse(t *testing.T) {
+	at := func(s string) int {
+		return strings.Index(incrementalSrc, s)
+	}
+	testTbl := []struct {
+		comment  string
+		edit     Edit
+		reparsed int  // 解析し直した宣言の数
+		reused   bool // 先頭の宣言を再利用したか
+	}{
+		{
+			"rename in function body",
+			Edit{Offset: at("a = x"), Removed: 1, Inserted: "bb"},
+			1,
+			true,
+		},
+		{
+			"insert declaration between",
+			Edit{Offset: at("int c;") - 1, Removed: 0, Inserted: "int d;\n"},
+			1,
+			true,
+		},
+		{
+			"insert newline at top",
+			Edit{Offset: at("int a;"), Removed: 0, Inserted: "\n\n"},
+			1,
+			false,
+		},
+		{
+			// 後続の関数が参照する a の宣言が変わるため全体を解析し直す
+			"break declaration",
+			Edit{Offset: at("int a;") + 5, Removed: 1, Inserted: ""},
+			5,
+			false,
+		},
+		{
+			"break unreferenced declaration",
+			Edit{Offset: at("int c;") + 5, Removed: 1, Inserted: ""},
+			1,
+			true,
+		},
+		{
+			"delete closing brace",
+			Edit{Offset: at("}\n\nint c;"), Removed: 1, Inserted: ""},
+			1,
+			true,
+		},
+		{
+			"append at end",
+			Edit{Offset: len(incrementalSrc), Removed: 0, Inserted: "int e;\n"},
+			1,
+			true,
+		},
+		{
+			"edit linemarker",
+			Edit{Offset: 2, Removed: 1, Inserted: "10"},
+			6,
+			false,
+		},
+	}
+
+	opts := ParseOptions{FileName: "hoge.i", Positions: true}
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		prev, _ := ParseTree(incrementalSrc, opts)
+		got, gotErr := prev.Reparse(tt.edit)
+		src := incrementalSrc[:tt.edit.Offset] + tt.edit.Inserted + incrementalSrc[tt.edit.Offset+tt.edit.Removed:]
+		expect, expectErr := ParseTree(src, opts)
+		if got.Source() != src {
+			t.Fatalf("got src=%q, expect src=%q", got.Source(), src)
+		}
+		if !reflect.DeepEqual(normalizeInvalid(got.Module.Statements), normalizeInvalid(expect.Module.Statements)) {
+			t.Errorf("got=%v, expect=%v", got.Module, expect.Module)
+		}
+		if errString(gotErr) != errString(expectErr) {
+			t.Errorf("got err=%v, expect err=%v", gotErr, expectErr)
+		}
+		if got.reparsed != tt.reparsed {
+			t.Errorf("got reparsed=%v, expect reparsed=%v", got.reparsed, tt.reparsed)
+		}
+		// 編集箇所より前の宣言はそのまま再利用する
+		reused := got.Module.Statements[0] == prev.Module.Statements[0]
+		if reused != tt.reused {
+			t.Errorf("got reused=%v, expect reused=%v", reused, tt.reused)
+		}
+	}
+}
+
+func TestReparseRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	pieces := []string{"int ", "x", ";", "{", "}", "(", ")", "\n", " = ", "1", "g(a)", "void ", ",", "# 3 \"a.h\"\n", "typedef int ", " * "}
+	opts := ParseOptions{Positions: true}
+	tree, _ := ParseTree(incrementalSrc, opts)
+	for n := 0; n < 500; n++ {
+		src := tree.Source()
+		off := r.Intn(len(src) + 1)
+		removed := r.Intn(4)
+		if off+removed > len(src) {
+			removed = len(src) - off
+		}
+		e := Edit{Offset: off, Removed: removed, Inserted: pieces[r.Intn(len(pieces))]}
+		next, gotErr := tree.Reparse(e)
+		expect, expectErr := ParseTree(next.Source(), opts)
+		if !reflect.DeepEqual(normalizeInvalid(next.Module.Statements), normalizeInvalid(expect.Module.Statements)) {
+			t.Fatalf("edit=%+v src=%q\ngot=%v\nexpect=%v", e, next.Source(), next.Module, expect.Module)
+		}
+		if errString(gotErr) != errString(expectErr) {
+			t.Fatalf("edit=%+v src=%q\ngot err=%v\nexpect err=%v", e, next.Source(), gotErr, expectErr)
+		}
+		tree = next
+	}
+}
+
+func TestReparseRandomStructure(t *testing.T) {
+	// 構造体や typedef の宣言は読み飛ばしに失敗すると宣言の途中で終わる
+	base := "struct s { int a; }\nvoid k(void) { int x; x = 1; }\ntypedef struct { int b; } T;\nstruct s v[2] = {0};\nint f(int (*p)(void)) { return p(); }\n"
+	pieces := []string{"struct s ", "{", "}", ";", "(", ")", "[10]", " = ", "int ", "typedef ", "T ", "i", "\n", "enum { A } "}
+	opts := ParseOptions{Positions: true}
+	for seed := int64(1); seed <= 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		tree, _ := ParseTree(base, opts)
+		for n := 0; n < 100; n++ {
+			src := tree.Source()
+			off := r.Intn(len(src) + 1)
+			removed := r.Intn(4)
+			if off+removed > len(src) {
+				removed = len(src) - off
+			}
+			e := Edit{Offset: off, Removed: removed, Inserted: pieces[r.Intn(len(pieces))]}
+			next, gotErr := tree.Reparse(e)
+			expect, expectErr := ParseTree(next.Source(), opts)
+			if !reflect.DeepEqual(normalizeInvalid(next.Module.Statements), normalizeInvalid(expect.Module.Statements)) {
+				t.Fatalf("seed=%d prev=%q edit=%+v\ngot=%v\nexpect=%v", seed, src, e, next.Module, expect.Module)
+			}
+			if errString(gotErr) != errString(expectErr) {
+				t.Fatalf("seed=%d prev=%q edit=%+v\ngot err=%v\nexpect err=%v", seed, src, e, gotErr, expectErr)
+			}
+			tree = next
+		}
+	}
+}
+
+func TestReparseInvalidContents(t *testing.T) {
+	src := "int a;\nint f(void) { x = 1 }\n"
+	tree, _ := ParseTree(src, ParseOptions{FileName: "hoge.c"})
+	got, gotErr := tree.Reparse(Edit{Offset: 0, Removed: 0, Inserted: "\n\n"})
+	expect, expectErr := ParseTree(got.Source(), ParseOptions{FileName: "hoge.c"})
+	if got.reparsed != 1 {
+		t.Errorf("got reparsed=%v, expect reparsed=1", got.reparsed)
+	}
+	if !reflect.DeepEqual(normalizeInvalid(got.Module.Statements), normalizeInvalid(expect.Module.Statements)) {
+		t.Errorf("got=%v, expect=%v", got.Module, expect.Module)
+	}
+	if errString(gotErr) != errString(expectErr) {
+		t.Errorf("got err=%v, expect err=%v", gotErr, expectErr)
+	}
+	if v := got.Module.Statements[1].(*InvalidStatement); v.Contents != "hoge.c:4:21: expected ';' before '}' token" {
+		t.Errorf("got contents=%v", v.Contents)
+	}
+}
+
+func TestReparseOutOfRange(t *testing.T) {
+	tree, _ := ParseTree("int a;\n", ParseOptions{})
+	if _, err := tree.Reparse(Edit{Offset: 5, Removed: 10}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+// InvalidStatement は内容と読み飛ばした範囲のみを比較する
+func normalizeInvalid(ss []Statement) []Statement {
+	ts := []Statement{}
+	for _, s := range ss {
+		if v, ok := s.(*InvalidStatement); ok {
+			s = &InvalidStatement{Contents: v.Contents, Start: v.Start, End: v.End}
+		}
+		ts = append(ts, s)
+	}
+	return ts
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
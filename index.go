@@ -0,0 +1,326 @@
+package symc
+
+// index モジュール
+// コードブラウザ向けに定義と参照を文書ごとにまとめた索引を作る
+// SCIP と LSIF の出力はこの索引から行う
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 出現の役割. SCIP の SymbolRole と同じ値を用いる
+const (
+	RoleReference         = 0
+	RoleDefinition        = 1
+	RoleWriteAccess       = 4
+	RoleReadAccess        = 8
+	RoleForwardDefinition = 64 // プロトタイプ宣言と extern 宣言
+)
+
+// Index 索引
+type Index struct {
+	ProjectRoot string // 文書のパスの基準となるディレクトリ
+	Documents   []*IndexDocument
+}
+
+// IndexDocument ファイルひとつ分の索引
+type IndexDocument struct {
+	Path        string // ProjectRoot からの相対パス. 基準の外の場合はそのまま
+	Occurrences []*Occurrence
+	Symbols     []*SymbolInfo // この文書で定義したシンボル
+	lines       []string      // 列を UTF-16 単位に換算するためのソースの行. 読み込めない場合は nil
+}
+
+// Occurrence シンボルの出現ひとつ
+// 行と列は 0 始まり. 列はバイト単位
+type Occurrence struct {
+	Symbol    string
+	Line      int
+	Column    int
+	EndColumn int
+	Roles     int // Role* の論理和
+}
+
+// SymbolInfo シンボルの情報
+type SymbolInfo struct {
+	Symbol      string
+	DisplayName string
+	Signature   string // ホバーに表示する宣言
+}
+
+// IndexOptions 索引の作成オプション
+type IndexOptions struct {
+	ProjectRoot string
+	// 宣言のテキストを得るためにファイルを読み込む. nil の場合や読み込めない場合は名前から組み立てる
+	Read func(file string) (string, error)
+}
+
+// 索引の作成中の状態
+type indexer struct {
+	opts    IndexOptions
+	globals map[string]string // 名前からファイルスコープのシンボルへの対応
+	docs    map[string]*IndexDocument
+	order   []string
+	locals  map[string]int // 文書ごとのローカルシンボルの数
+	infos   map[string]*symbolDef
+	sources map[string][]string
+}
+
+// シンボルの情報を登録する文書と定義位置
+type symbolDef struct {
+	info    *SymbolInfo
+	pos     Position
+	forward bool // 前方宣言のみか
+	params  []string
+}
+
+// BuildIndex
+// 位置情報付きで解析したモジュールから索引を作る. 位置情報のないシンボルは含めない
+func BuildIndex(ms []*Module, opts IndexOptions) *Index {
+	x := &indexer{
+		opts:    opts,
+		globals: map[string]string{},
+		docs:    map[string]*IndexDocument{},
+		locals:  map[string]int{},
+		infos:   map[string]*symbolDef{},
+		sources: map[string][]string{},
+	}
+	// 参照より後に定義されるシンボルもあるため先にファイルスコープの名前を集める
+	for _, m := range ms {
+		for _, s := range m.Statements {
+			switch v := s.(type) {
+			case *FunctionDef:
+				x.globals[v.Name] = functionSymbol(v.Name)
+			case *PrototypeDecl:
+				x.globals[v.Name] = functionSymbol(v.Name)
+			case *VariableDef:
+				x.globals[v.Name] = variableSymbol(v.Name)
+			case *VariableDecl:
+				x.globals[v.Name] = variableSymbol(v.Name)
+			}
+		}
+	}
+	for _, m := range ms {
+		x.statements(m.Statements, nil)
+	}
+
+	idx := &Index{ProjectRoot: opts.ProjectRoot}
+	for _, p := range x.order {
+		idx.Documents = append(idx.Documents, x.docs[p])
+	}
+	syms := []string{}
+	for s := range x.infos {
+		syms = append(syms, s)
+	}
+	sort.Strings(syms)
+	for _, s := range syms {
+		d := x.infos[s]
+		d.info.Signature = x.signature(d)
+		doc := x.document(d.pos)
+		doc.Symbols = append(doc.Symbols, d.info)
+	}
+	return idx
+}
+
+// functionSymbol
+func functionSymbol(name string) string {
+	return "symc . . . " + name + "()."
+}
+
+// variableSymbol
+func variableSymbol(name string) string {
+	return "symc . . . " + name + "."
+}
+
+// localScope 関数内の名前からローカルシンボルへの対応
+type localScope struct {
+	names map[string]string
+	outer *localScope
+}
+
+// lookup
+func (s *localScope) lookup(name string) (string, bool) {
+	for ; s != nil; s = s.outer {
+		if v, ok := s.names[name]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// statements
+func (x *indexer) statements(ss []Statement, scope *localScope) {
+	for _, s := range ss {
+		switch v := s.(type) {
+		case *VariableDef:
+			if scope == nil {
+				x.define(variableSymbol(v.Name), v.Name, v.Pos, RoleDefinition, nil)
+			} else if v.Pos.IsValid() {
+				sym := x.newLocal(v.Pos)
+				scope.names[v.Name] = sym
+				x.define(sym, v.Name, v.Pos, RoleDefinition, nil)
+			}
+		case *VariableDecl:
+			x.define(variableSymbol(v.Name), v.Name, v.Pos, RoleForwardDefinition, nil)
+		case *PrototypeDecl:
+			x.define(functionSymbol(v.Name), v.Name, v.Pos, RoleForwardDefinition, nil)
+		case *FunctionDef:
+			params := []string{}
+			for _, p := range v.Params {
+				params = append(params, p.Name)
+			}
+			sym := functionSymbol(v.Name)
+			if scope != nil {
+				// 入れ子関数は外側の関数のローカルシンボルとする
+				if !v.Pos.IsValid() {
+					continue
+				}
+				sym = x.newLocal(v.Pos)
+				scope.names[v.Name] = sym
+			}
+			x.define(sym, v.Name, v.Pos, RoleDefinition, params)
+			inner := &localScope{names: map[string]string{}, outer: scope}
+			for _, p := range v.Params {
+				if p.Pos.IsValid() {
+					ps := x.newLocal(p.Pos)
+					inner.names[p.Name] = ps
+					x.define(ps, p.Name, p.Pos, RoleDefinition, nil)
+				}
+			}
+			x.statements(v.Statements, inner)
+		case *RefVar:
+			x.reference(v.Name, v.Pos, RoleReadAccess, false, varScope(v.Local, scope))
+		case *Assigne:
+			x.reference(v.Name, v.Pos, RoleWriteAccess, false, varScope(v.Local, scope))
+		case *CallFunc:
+			x.reference(v.Name, v.Pos, RoleReference, true, scope)
+			x.statements(v.Args, scope)
+		}
+	}
+}
+
+// varScope
+// ブロックスコープの宣言も仮引数も指さない参照と代入はローカルシンボルから探さない
+func varScope(local bool, scope *localScope) *localScope {
+	if !local {
+		return nil
+	}
+	return scope
+}
+
+// newLocal
+// 文書内で一意なローカルシンボルを作る
+func (x *indexer) newLocal(pos Position) string {
+	d := x.document(pos)
+	n := x.locals[d.Path]
+	x.locals[d.Path]++
+	return "local " + strconv.Itoa(n)
+}
+
+// define
+// 定義の出現を記録し, シンボルの情報を登録する
+// 定義がある場合は前方宣言より定義の位置を優先する
+func (x *indexer) define(sym, name string, pos Position, role int, params []string) {
+	if !pos.IsValid() {
+		return
+	}
+	x.occur(sym, name, pos, role)
+	forward := role == RoleForwardDefinition
+	if d, ok := x.infos[sym]; ok && (forward || !d.forward) {
+		return
+	}
+	x.infos[sym] = &symbolDef{info: &SymbolInfo{Symbol: sym, DisplayName: name}, pos: pos, forward: forward, params: params}
+}
+
+// reference
+func (x *indexer) reference(name string, pos Position, role int, call bool, scope *localScope) {
+	if !pos.IsValid() {
+		return
+	}
+	sym, ok := scope.lookup(name)
+	if !ok {
+		sym, ok = x.globals[name]
+	}
+	if !ok {
+		// 宣言の見つからない名前は呼び出しなら関数とみなす
+		if call {
+			sym = functionSymbol(name)
+		} else {
+			sym = variableSymbol(name)
+		}
+	}
+	x.occur(sym, name, pos, role)
+}
+
+// occur
+func (x *indexer) occur(sym, name string, pos Position, role int) {
+	d := x.document(pos)
+	d.Occurrences = append(d.Occurrences, &Occurrence{
+		Symbol:    sym,
+		Line:      pos.Line - 1,
+		Column:    pos.Column - 1,
+		EndColumn: pos.Column - 1 + len(name),
+		Roles:     role,
+	})
+}
+
+// document
+// 位置のファイルに対応する文書を返す. なければ作成する
+func (x *indexer) document(pos Position) *IndexDocument {
+	p := x.relativePath(pos.File)
+	d, ok := x.docs[p]
+	if !ok {
+		d = &IndexDocument{Path: p, Occurrences: []*Occurrence{}, Symbols: []*SymbolInfo{}, lines: x.source(pos.File)}
+		x.docs[p] = d
+		x.order = append(x.order, p)
+	}
+	return d
+}
+
+// relativePath
+func (x *indexer) relativePath(file string) string {
+	if x.opts.ProjectRoot == "" || !filepath.IsAbs(file) {
+		return filepath.ToSlash(file)
+	}
+	rel, err := filepath.Rel(x.opts.ProjectRoot, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// source
+// ファイルを読み込んで行に分ける. 読み込めない場合は nil
+func (x *indexer) source(file string) []string {
+	if x.opts.Read == nil {
+		return nil
+	}
+	ls, ok := x.sources[file]
+	if !ok {
+		if src, err := x.opts.Read(file); err == nil {
+			ls = strings.Split(src, "\n")
+		}
+		x.sources[file] = ls
+	}
+	return ls
+}
+
+// signature
+// ホバーに表示する宣言を返す
+// ソースを読み込める場合は定義のある行, 読み込めない場合は名前と仮引数から組み立てる
+func (x *indexer) signature(d *symbolDef) string {
+	if ls := x.source(d.pos.File); d.pos.Line <= len(ls) {
+		l := strings.TrimSpace(ls[d.pos.Line-1])
+		l = strings.TrimSpace(strings.TrimSuffix(l, "{"))
+		if l != "" {
+			return l
+		}
+	}
+	if d.params != nil {
+		return d.info.DisplayName + "(" + strings.Join(d.params, ", ") + ")"
+	}
+	return d.info.DisplayName
+}
@@ -0,0 +1,111 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+const indexSrc = `# 1 "a.h"
+extern int count;
+int add(int n);
+# 1 "a.c"
+int count;
+int add(int n) {
+    int t = n;
+    count = count + t;
+    return log(t);
+}
+`
+
+func buildTestIndex(t *testing.T, read func(string) (string, error)) *Index {
+	m, err := ParseWithOptions(indexSrc, ParseOptions{Positions: true})
+	if err != nil {
+		t.Fatalf("got err=%v", err)
+	}
+	return BuildIndex([]*Module{m}, IndexOptions{ProjectRoot: "/src", Read: read})
+}
+
+func TestBuildIndex(t *testing.T) {
+	idx := buildTestIndex(t, nil)
+	count := "symc . . . count."
+	add := "symc . . . add()."
+	expect := []*IndexDocument{
+		{
+			Path: "a.h",
+			Occurrences: []*Occurrence{
+				{Symbol: count, Line: 0, Column: 11, EndColumn: 16, Roles: RoleForwardDefinition},
+				{Symbol: add, Line: 1, Column: 4, EndColumn: 7, Roles: RoleForwardDefinition},
+			},
+			Symbols: []*SymbolInfo{},
+		},
+		{
+			Path: "a.c",
+			Occurrences: []*Occurrence{
+				{Symbol: count, Line: 0, Column: 4, EndColumn: 9, Roles: RoleDefinition},
+				{Symbol: add, Line: 1, Column: 4, EndColumn: 7, Roles: RoleDefinition},
+				{Symbol: "local 0", Line: 1, Column: 12, EndColumn: 13, Roles: RoleDefinition},
+				{Symbol: "local 1", Line: 2, Column: 8, EndColumn: 9, Roles: RoleDefinition},
+				{Symbol: "local 0", Line: 2, Column: 12, EndColumn: 13, Roles: RoleReadAccess},
+				{Symbol: count, Line: 3, Column: 4, EndColumn: 9, Roles: RoleWriteAccess},
+				{Symbol: count, Line: 3, Column: 12, EndColumn: 17, Roles: RoleReadAccess},
+				{Symbol: "local 1", Line: 3, Column: 20, EndColumn: 21, Roles: RoleReadAccess},
+				{Symbol: "symc . . . log().", Line: 4, Column: 11, EndColumn: 14, Roles: RoleReference},
+				{Symbol: "local 1", Line: 4, Column: 15, EndColumn: 16, Roles: RoleReadAccess},
+			},
+			Symbols: []*SymbolInfo{
+				{Symbol: "local 0", DisplayName: "n", Signature: "n"},
+				{Symbol: "local 1", DisplayName: "t", Signature: "t"},
+				{Symbol: add, DisplayName: "add", Signature: "add(n)"},
+				{Symbol: count, DisplayName: "count", Signature: "count"},
+			},
+		},
+	}
+	if idx.ProjectRoot != "/src" {
+		t.Errorf("got root=%v", idx.ProjectRoot)
+	}
+	if !reflect.DeepEqual(idx.Documents, expect) {
+		for _, d := range idx.Documents {
+			t.Logf("%s %v", d.Path, d.Symbols)
+			for _, o := range d.Occurrences {
+				t.Logf("%+v", o)
+			}
+		}
+		t.Errorf("documents mismatch")
+	}
+}
+
+func TestBuildIndexBlockScope(t *testing.T) {
+	m, _ := ParseWithOptions("int g;\nvoid f(void) {\n    { int g; g = 1; }\n    g = 2;\n}\n", ParseOptions{FileName: "a.c", Positions: true})
+	idx := BuildIndex([]*Module{m}, IndexOptions{})
+	g := "symc . . . g."
+	expect := []*Occurrence{
+		{Symbol: g, Line: 0, Column: 4, EndColumn: 5, Roles: RoleDefinition},
+		{Symbol: "symc . . . f().", Line: 1, Column: 5, EndColumn: 6, Roles: RoleDefinition},
+		{Symbol: "local 0", Line: 2, Column: 10, EndColumn: 11, Roles: RoleDefinition},
+		{Symbol: "local 0", Line: 2, Column: 13, EndColumn: 14, Roles: RoleWriteAccess},
+		{Symbol: g, Line: 3, Column: 4, EndColumn: 5, Roles: RoleWriteAccess},
+	}
+	if !reflect.DeepEqual(idx.Documents[0].Occurrences, expect) {
+		for _, o := range idx.Documents[0].Occurrences {
+			t.Logf("%+v", o)
+		}
+		t.Errorf("occurrences mismatch")
+	}
+}
+
+func TestBuildIndexSignature(t *testing.T) {
+	idx := buildTestIndex(t, func(file string) (string, error) {
+		return "int count;\nint add(int n) {\n", nil
+	})
+	expect := map[string]string{
+		"local 0":           "int add(int n)",
+		"local 1":           "t", // 読み込んだソースにない行は名前から組み立てる
+		"symc . . . add().": "int add(int n)",
+		"symc . . . count.": "int count;",
+	}
+	for _, s := range idx.Documents[1].Symbols {
+		if s.Signature != expect[s.Symbol] {
+			t.Errorf("%s: got=%q, expect=%q", s.Symbol, s.Signature, expect[s.Symbol])
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package symc
+
+// json モジュール
+// Module と各 Statement を JSON に変換する
+// 文の種類は "kind" に型名を持たせて区別する
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// SchemaVersion JSON のスキーマのバージョン
+// フィールドの削除や意味の変更など互換性のない変更をした場合に上げる
+const SchemaVersion = 1
+
+// 文の種類
+const (
+	kindInvalidStatement = "InvalidStatement"
+	kindVariableDef      = "VariableDef"
+	kindVariableDecl     = "VariableDecl"
+	kindPrototypeDecl    = "PrototypeDecl"
+	kindFunctionDef      = "FunctionDef"
+	kindRefVar           = "RefVar"
+	kindAssigne          = "Assigne"
+	kindCallFunc         = "CallFunc"
+	kindTypedef          = "Typedef"
+)
+
+type jsonModule struct {
+	Version    int           `json:"version"`
+	Statements []interface{} `json:"statements"`
+}
+
+type jsonPosition struct {
+	File   string `json:"file,omitempty"`
+	Offset int    `json:"offset"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// 識別子ひとつからなる文
+type jsonSymbol struct {
+	Kind  string        `json:"kind"`
+	Name  string        `json:"name"`
+	Pos   *jsonPosition `json:"pos,omitempty"`
+	Local bool          `json:"local,omitempty"`
+}
+
+type jsonInvalidStatement struct {
+	Kind     string        `json:"kind"`
+	Contents string        `json:"contents"`
+	Token    string        `json:"token,omitempty"`
+	Start    *jsonPosition `json:"start,omitempty"`
+	End      *jsonPosition `json:"end,omitempty"`
+}
+
+type jsonFunctionDef struct {
+	Kind       string        `json:"kind"`
+	Name       string        `json:"name"`
+	Pos        *jsonPosition `json:"pos,omitempty"`
+	Params     []interface{} `json:"params"`
+	Statements []interface{} `json:"statements"`
+	Labels     []*jsonLabel  `json:"labels"`
+	Gotos      []*jsonGoto   `json:"gotos"`
+	OldStyle   bool          `json:"oldStyle"`
+	Local      bool          `json:"local,omitempty"`
+}
+
+type jsonCallFunc struct {
+	Kind string        `json:"kind"`
+	Name string        `json:"name"`
+	Pos  *jsonPosition `json:"pos,omitempty"`
+	Args []interface{} `json:"args"`
+}
+
+type jsonLabel struct {
+	Name  string        `json:"name"`
+	Order int           `json:"order"`
+	Pos   *jsonPosition `json:"pos,omitempty"`
+}
+
+type jsonGoto struct {
+	Label string        `json:"label"`
+	Order int           `json:"order"`
+	Pos   *jsonPosition `json:"pos,omitempty"`
+}
+
+func (m *Module) MarshalJSON() ([]byte, error) {
+	xs, err := toJSONStatements(m.Statements)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(&jsonModule{Version: SchemaVersion, Statements: xs})
+}
+
+func (v *InvalidStatement) MarshalJSON() ([]byte, error) { return marshalStatement(v) }
+func (v *VariableDef) MarshalJSON() ([]byte, error)      { return marshalStatement(v) }
+func (v *VariableDecl) MarshalJSON() ([]byte, error)     { return marshalStatement(v) }
+func (v *PrototypeDecl) MarshalJSON() ([]byte, error)    { return marshalStatement(v) }
+func (v *FunctionDef) MarshalJSON() ([]byte, error)      { return marshalStatement(v) }
+func (v *RefVar) MarshalJSON() ([]byte, error)           { return marshalStatement(v) }
+func (v *Assigne) MarshalJSON() ([]byte, error)          { return marshalStatement(v) }
+func (v *CallFunc) MarshalJSON() ([]byte, error)         { return marshalStatement(v) }
+func (v *Typedef) MarshalJSON() ([]byte, error)          { return marshalStatement(v) }
+
+// marshalStatement
+func marshalStatement(s Statement) ([]byte, error) {
+	x, err := toJSON(s)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(x)
+}
+
+// marshal
+// HTML の文字をエスケープせずに JSON に変換する
+// エスケープするかは呼び出し側の json.Marshal や json.Encoder の設定に従う
+func marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// toJSONStatements
+func toJSONStatements(ss []Statement) ([]interface{}, error) {
+	xs := []interface{}{}
+	for _, s := range ss {
+		x, err := toJSON(s)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	return xs, nil
+}
+
+// toJSON
+// 文を JSON に変換するための値に変換する
+// nil や未知の種類の文はエラーとする
+func toJSON(s Statement) (interface{}, error) {
+	if s == nil || reflect.ValueOf(s).IsNil() {
+		return nil, fmt.Errorf("symc: null statement")
+	}
+	switch v := s.(type) {
+	case *InvalidStatement:
+		x := &jsonInvalidStatement{Kind: kindInvalidStatement, Contents: v.Contents, Start: toJSONPosition(v.Start), End: toJSONPosition(v.End)}
+		if v.Tk != nil {
+			x.Token = v.Tk.literal
+		}
+		return x, nil
+	case *VariableDef:
+		return &jsonSymbol{Kind: kindVariableDef, Name: v.Name, Pos: toJSONPosition(v.Pos)}, nil
+	case *VariableDecl:
+		return &jsonSymbol{Kind: kindVariableDecl, Name: v.Name, Pos: toJSONPosition(v.Pos)}, nil
+	case *PrototypeDecl:
+		return &jsonSymbol{Kind: kindPrototypeDecl, Name: v.Name, Pos: toJSONPosition(v.Pos)}, nil
+	case *RefVar:
+		return &jsonSymbol{Kind: kindRefVar, Name: v.Name, Pos: toJSONPosition(v.Pos), Local: v.Local}, nil
+	case *Assigne:
+		return &jsonSymbol{Kind: kindAssigne, Name: v.Name, Pos: toJSONPosition(v.Pos), Local: v.Local}, nil
+	case *Typedef:
+		return &jsonSymbol{Kind: kindTypedef, Name: v.Name, Pos: toJSONPosition(v.Pos)}, nil
+	case *CallFunc:
+		args, err := toJSONStatements(v.Args)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonCallFunc{Kind: kindCallFunc, Name: v.Name, Pos: toJSONPosition(v.Pos), Args: args}, nil
+	case *FunctionDef:
+		ss, err := toJSONStatements(v.Statements)
+		if err != nil {
+			return nil, err
+		}
+		x := &jsonFunctionDef{
+			Kind:       kindFunctionDef,
+			Name:       v.Name,
+			Pos:        toJSONPosition(v.Pos),
+			Params:     []interface{}{},
+			Statements: ss,
+			Labels:     []*jsonLabel{},
+			Gotos:      []*jsonGoto{},
+			OldStyle:   v.OldStyle,
+		}
+		for _, p := range v.Params {
+			param, err := toJSON(p)
+			if err != nil {
+				return nil, err
+			}
+			x.Params = append(x.Params, param)
+		}
+		for _, l := range v.Labels {
+			if l == nil {
+				return nil, fmt.Errorf("symc: null label in %s", v.Name)
+			}
+			x.Labels = append(x.Labels, &jsonLabel{Name: l.Name, Order: l.Order, Pos: toJSONPosition(l.Pos)})
+		}
+		for _, g := range v.Gotos {
+			if g == nil {
+				return nil, fmt.Errorf("symc: null goto in %s", v.Name)
+			}
+			x.Gotos = append(x.Gotos, &jsonGoto{Label: g.Label, Order: g.Order, Pos: toJSONPosition(g.Pos)})
+		}
+		return x, nil
+	}
+	return nil, fmt.Errorf("symc: unknown statement type %T", s)
+}
+
+// toJSONPosition
+// 位置情報がない場合は nil を返す
+func toJSONPosition(pos Position) *jsonPosition {
+	if !pos.IsValid() {
+		return nil
+	}
+	return &jsonPosition{File: pos.File, Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+}
+
+// 復元用. 全ての種類の文のフィールドを持つ
+type jsonNode struct {
+	Kind       string        `json:"kind"`
+	Name       string        `json:"name"`
+	Pos        *jsonPosition `json:"pos"`
+	Contents   string        `json:"contents"`
+	Token      string        `json:"token"`
+	Start      *jsonPosition `json:"start"`
+	End        *jsonPosition `json:"end"`
+	Params     []*jsonNode   `json:"params"`
+	Statements []*jsonNode   `json:"statements"`
+	Args       []*jsonNode   `json:"args"`
+	Labels     []*jsonLabel  `json:"labels"`
+	Gotos      []*jsonGoto   `json:"gotos"`
+	OldStyle   bool          `json:"oldStyle"`
+	Local      bool          `json:"local,omitempty"`
+}
+
+type jsonModuleNode struct {
+	Version    int         `json:"version"`
+	Statements []*jsonNode `json:"statements"`
+}
+
+// UnmarshalModule
+// JSON に変換した Module を復元する
+// InvalidStatement のトークン列は復元しない. Tk は字句のみを持つ
+func UnmarshalModule(data []byte) (*Module, error) {
+	var x jsonModuleNode
+	if err := json.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	if x.Version == 0 {
+		return nil, fmt.Errorf("symc: missing schema version")
+	}
+	if x.Version > SchemaVersion {
+		return nil, fmt.Errorf("symc: unsupported schema version %d (supported up to %d)", x.Version, SchemaVersion)
+	}
+	ss, err := fromJSONStatements(x.Statements)
+	if err != nil {
+		return nil, err
+	}
+	return &Module{ss}, nil
+}
+
+// fromJSONStatements
+func fromJSONStatements(xs []*jsonNode) ([]Statement, error) {
+	ss := []Statement{}
+	for _, x := range xs {
+		s, err := fromJSON(x)
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+	return ss, nil
+}
+
+// fromJSON
+// kind に応じた型の文を復元する
+func fromJSON(x *jsonNode) (Statement, error) {
+	if x == nil {
+		return nil, fmt.Errorf("symc: null statement")
+	}
+	pos := fromJSONPosition(x.Pos)
+	switch x.Kind {
+	case kindInvalidStatement:
+		v := &InvalidStatement{Contents: x.Contents, Start: fromJSONPosition(x.Start), End: fromJSONPosition(x.End)}
+		if x.Token != "" {
+			v.Tk = &Token{literal: x.Token}
+		}
+		return v, nil
+	case kindVariableDef:
+		return &VariableDef{Name: x.Name, Pos: pos}, nil
+	case kindVariableDecl:
+		return &VariableDecl{Name: x.Name, Pos: pos}, nil
+	case kindPrototypeDecl:
+		return &PrototypeDecl{Name: x.Name, Pos: pos}, nil
+	case kindRefVar:
+		return &RefVar{Name: x.Name, Pos: pos, Local: x.Local}, nil
+	case kindAssigne:
+		return &Assigne{Name: x.Name, Pos: pos, Local: x.Local}, nil
+	case kindTypedef:
+		return &Typedef{Name: x.Name, Pos: pos}, nil
+	case kindCallFunc:
+		as, err := fromJSONStatements(x.Args)
+		if err != nil {
+			return nil, err
+		}
+		return &CallFunc{Name: x.Name, Args: as, Pos: pos}, nil
+	case kindFunctionDef:
+		ss, err := fromJSONStatements(x.Statements)
+		if err != nil {
+			return nil, err
+		}
+		f := &FunctionDef{Name: x.Name, Params: []*VariableDef{}, Statements: ss, OldStyle: x.OldStyle, Pos: pos}
+		for _, p := range x.Params {
+			s, err := fromJSON(p)
+			if err != nil {
+				return nil, err
+			}
+			v, ok := s.(*VariableDef)
+			if !ok {
+				return nil, fmt.Errorf("symc: parameter of %s must be %s, got %s", x.Name, kindVariableDef, p.Kind)
+			}
+			f.Params = append(f.Params, v)
+		}
+		for _, l := range x.Labels {
+			if l == nil {
+				return nil, fmt.Errorf("symc: null label in %s", x.Name)
+			}
+			f.Labels = append(f.Labels, &Label{Name: l.Name, Order: l.Order, Pos: fromJSONPosition(l.Pos)})
+		}
+		for _, g := range x.Gotos {
+			if g == nil {
+				return nil, fmt.Errorf("symc: null goto in %s", x.Name)
+			}
+			f.Gotos = append(f.Gotos, &Goto{Label: g.Label, Order: g.Order, Pos: fromJSONPosition(g.Pos)})
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("symc: unknown statement kind %q", x.Kind)
+}
+
+// fromJSONPosition
+func fromJSONPosition(x *jsonPosition) Position {
+	if x == nil {
+		return Position{}
+	}
+	return Position{File: x.File, Offset: x.Offset, Line: x.Line, Column: x.Column}
+}
@@ -0,0 +1,203 @@
+package symc
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		opts    ParseOptions
+		expect  string
+	}{
+		{
+			"empty module",
+			"",
+			ParseOptions{},
+			`{"version":1,"statements":[]}`,
+		},
+		{
+			"declarations",
+			"int a; extern int b; void f(void); typedef int T;",
+			ParseOptions{},
+			`{"version":1,"statements":[` +
+				`{"kind":"VariableDef","name":"a"},` +
+				`{"kind":"VariableDecl","name":"b"},` +
+				`{"kind":"PrototypeDecl","name":"f"}]}`,
+		},
+		{
+			"function definition",
+			"int f(int x) { a = x; g(a, h()); err: goto err; }",
+			ParseOptions{},
+			`{"version":1,"statements":[` +
+				`{"kind":"FunctionDef","name":"f",` +
+				`"params":[{"kind":"VariableDef","name":"x"}],` +
+				`"statements":[{"kind":"Assigne","name":"a"},{"kind":"RefVar","name":"x","local":true},` +
+				`{"kind":"CallFunc","name":"g","args":[{"kind":"RefVar","name":"a"},{"kind":"CallFunc","name":"h","args":[]}]}],` +
+				`"labels":[{"name":"err","order":0}],` +
+				`"gotos":[{"label":"err","order":1}],` +
+				`"oldStyle":false}]}`,
+		},
+		{
+			"positions",
+			"int a;\nvoid f(void) { a++; }",
+			ParseOptions{FileName: "hoge.c", Positions: true},
+			`{"version":1,"statements":[` +
+				`{"kind":"VariableDef","name":"a","pos":{"file":"hoge.c","offset":4,"line":1,"column":5}},` +
+				`{"kind":"FunctionDef","name":"f","pos":{"file":"hoge.c","offset":12,"line":2,"column":6},` +
+				`"params":[],` +
+				`"statements":[{"kind":"Assigne","name":"a","pos":{"file":"hoge.c","offset":22,"line":2,"column":16}}],` +
+				`"labels":[],"gotos":[],"oldStyle":false}]}`,
+		},
+		{
+			"label and goto positions",
+			"int f(void) { err: goto err; }",
+			ParseOptions{FileName: "hoge.c", Positions: true},
+			`{"version":1,"statements":[` +
+				`{"kind":"FunctionDef","name":"f","pos":{"file":"hoge.c","offset":4,"line":1,"column":5},` +
+				`"params":[],"statements":[],` +
+				`"labels":[{"name":"err","order":0,"pos":{"file":"hoge.c","offset":14,"line":1,"column":15}}],` +
+				`"gotos":[{"label":"err","order":1,"pos":{"file":"hoge.c","offset":19,"line":1,"column":20}}],` +
+				`"oldStyle":false}]}`,
+		},
+		{
+			"invalid statement",
+			"int f(void) { x = 1 }\nint a;",
+			ParseOptions{},
+			`{"version":1,"statements":[` +
+				`{"kind":"InvalidStatement","contents":"1:21: expected ';' before '}' token","token":"(",` +
+				`"start":{"offset":0,"line":1,"column":1},"end":{"offset":21,"line":1,"column":22}},` +
+				`{"kind":"VariableDef","name":"a"}]}`,
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		m, _ := ParseWithOptions(tt.src, tt.opts)
+		actual, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("got err=%v", err)
+		}
+		if string(actual) != tt.expect {
+			t.Errorf("got=%s, expect=%s", actual, tt.expect)
+		}
+	}
+}
+
+func TestMarshalModuleEscapeHTML(t *testing.T) {
+	m, _ := ParseWithOptions(`int x "a<b";`, ParseOptions{})
+	testTbl := []struct {
+		comment string
+		escape  bool
+		expect  string
+	}{
+		{"escape html", true, `"token":"\"a\u003cb\""`},
+		{"do not escape html", false, `"token":"\"a<b\""`},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(tt.escape)
+		if err := enc.Encode(m); err != nil {
+			t.Fatalf("got err=%v", err)
+		}
+		if !strings.Contains(b.String(), tt.expect) {
+			t.Errorf("got=%s, expect=%s", b.String(), tt.expect)
+		}
+	}
+}
+
+func TestMarshalJSONErrors(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		m       *Module
+		expect  string
+	}{
+		{"nil statement", &Module{[]Statement{nil}}, "symc: null statement"},
+		{"typed nil statement", &Module{[]Statement{(*RefVar)(nil)}}, "symc: null statement"},
+		{"nil argument", &Module{[]Statement{&CallFunc{Name: "f", Args: []Statement{nil}}}}, "symc: null statement"},
+		{"nil parameter", &Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{nil}}}}, "symc: null statement"},
+		{"nil label", &Module{[]Statement{&FunctionDef{Name: "f", Labels: []*Label{nil}}}}, "symc: null label in f"},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		_, err := json.Marshal(tt.m)
+		if err == nil || !strings.Contains(err.Error(), tt.expect) {
+			t.Errorf("got err=%v, expect err=%v", err, tt.expect)
+		}
+	}
+}
+
+func TestUnmarshalModule(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		opts    ParseOptions
+	}{
+		{"empty module", "", ParseOptions{}},
+		{"declarations", "int a; extern int b; void f(void);", ParseOptions{}},
+		{"function definition", "int f(int x) { a = x; g(a, h()); err: goto err; }", ParseOptions{}},
+		{"old style and nested function", "int f(a) int a; { int g(void) { return a; } return g(); }", ParseOptions{}},
+		{"positions", "# 1 \"hoge.c\"\nint a;\nvoid f(void) { a++; b = c(); }", ParseOptions{Positions: true}},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		expect, _ := ParseWithOptions(tt.src, tt.opts)
+		b, err := json.Marshal(expect)
+		if err != nil {
+			t.Fatalf("got err=%v", err)
+		}
+		actual, err := UnmarshalModule(b)
+		if err != nil {
+			t.Fatalf("got err=%v", err)
+		}
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("got=%v, expect=%v", actual, expect)
+		}
+	}
+}
+
+func TestUnmarshalModuleInvalid(t *testing.T) {
+	m, err := UnmarshalModule([]byte(`{"version":1,"statements":[{"kind":"InvalidStatement","contents":"x","token":"(","start":{"offset":0,"line":1,"column":1}}]}`))
+	if err != nil {
+		t.Fatalf("got err=%v", err)
+	}
+	v, ok := m.Statements[0].(*InvalidStatement)
+	if !ok || v.Contents != "x" || v.Tk.literal != "(" || v.Start.Line != 1 || v.End.IsValid() {
+		t.Errorf("got=%#v", m.Statements[0])
+	}
+}
+
+func TestUnmarshalModuleErrors(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		expect  string
+	}{
+		{"malformed", `{"version":1,`, "unexpected end of JSON input"},
+		{"missing version", `{"statements":[]}`, "symc: missing schema version"},
+		{"newer version", `{"version":2,"statements":[]}`, "symc: unsupported schema version 2 (supported up to 1)"},
+		{"unknown kind", `{"version":1,"statements":[{"kind":"Macro","name":"M"}]}`, `symc: unknown statement kind "Macro"`},
+		{"nested unknown kind", `{"version":1,"statements":[{"kind":"CallFunc","name":"f","args":[{"kind":""}]}]}`, `symc: unknown statement kind ""`},
+		{"parameter kind", `{"version":1,"statements":[{"kind":"FunctionDef","name":"f","params":[{"kind":"RefVar","name":"x"}]}]}`, "symc: parameter of f must be VariableDef, got RefVar"},
+		{"null label", `{"version":1,"statements":[{"kind":"FunctionDef","name":"f","labels":[null]}]}`, "symc: null label in f"},
+		{"null goto", `{"version":1,"statements":[{"kind":"FunctionDef","name":"f","gotos":[null]}]}`, "symc: null goto in f"},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		_, err := UnmarshalModule([]byte(tt.src))
+		if errString(err) != tt.expect {
+			t.Errorf("got err=%v, expect err=%v", errString(err), tt.expect)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package symc
+
+// jump モジュール
+// ラベルと goto 文の情報を扱う
+
+import (
+	"fmt"
+)
+
+// Label ラベル定義
+type Label struct {
+	Name  string
+	Order int      // 関数定義内でのラベルと goto 文を合わせた出現順. 0 から数える
+	Pos   Position // ラベル名の位置. ParseOptions.Positions 指定時のみ
+}
+
+func (v *Label) String() string {
+	return fmt.Sprintf("Label : Name=%s, Order=%d, Pos=%s", v.Name, v.Order, v.Pos)
+}
+
+// Goto goto 文
+type Goto struct {
+	Label string
+	Order int      // 関数定義内でのラベルと goto 文を合わせた出現順. 0 から数える
+	Pos   Position // goto の位置. ParseOptions.Positions 指定時のみ
+}
+
+func (v *Goto) String() string {
+	return fmt.Sprintf("Goto : Label=%s, Order=%d, Pos=%s", v.Label, v.Order, v.Pos)
+}
+
+// 関数定義解析中のラベルと goto 文
+type jumps struct {
+	labels []*Label
+	gotos  []*Goto
+}
+
+// order
+// 次に現れるラベルもしくは goto 文の出現順を返す
+func (j *jumps) order() int {
+	return len(j.labels) + len(j.gotos)
+}
+
+// LookupLabel
+// 名前に一致するラベルを返す. 存在しない場合は nil
+func (v *FunctionDef) LookupLabel(name string) *Label {
+	for _, l := range v.Labels {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// UndefinedGotos
+// 飛び先のラベルが存在しない goto 文を返す
+func (v *FunctionDef) UndefinedGotos() []*Goto {
+	gs := []*Goto{}
+	for _, g := range v.Gotos {
+		if v.LookupLabel(g.Label) == nil {
+			gs = append(gs, g)
+		}
+	}
+	return gs
+}
+
+// UnusedLabels
+// どの goto 文からも参照されないラベルを返す
+func (v *FunctionDef) UnusedLabels() []*Label {
+	used := map[string]bool{}
+	for _, g := range v.Gotos {
+		used[g.Label] = true
+	}
+	ls := []*Label{}
+	for _, l := range v.Labels {
+		if !used[l.Name] {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+// BackwardGotos
+// 自身より前にあるラベルへ飛ぶ goto 文を返す
+// 前後は出現順で比べるため, 位置情報の有無によらず判定できる
+func (v *FunctionDef) BackwardGotos() []*Goto {
+	gs := []*Goto{}
+	for _, g := range v.Gotos {
+		if l := v.LookupLabel(g.Label); l != nil && l.Order < g.Order {
+			gs = append(gs, g)
+		}
+	}
+	return gs
+}
@@ -0,0 +1,106 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJumps(t *testing.T) {
+	testTbl := []struct {
+		comment   string
+		src       string
+		undefined []string
+		unused    []string
+		backward  []string
+	}{
+		{
+			"jumps 1",
+			`
+int func(int a)
+{
+    if (a)
+        goto cleanup;
+    return 0;
+cleanup:
+    return 1;
+}
+`,
+			[]string{},
+			[]string{},
+			[]string{},
+		},
+		{
+			"jumps 2",
+			`
+int func(int a)
+{
+retry:
+    if (a)
+        goto retry;
+    goto missing;
+unused:
+    return 0;
+}
+`,
+			[]string{"missing"},
+			[]string{"unused"},
+			[]string{"retry"},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		// ラベルと goto 文の前後は位置情報を指定しなくても判定できる
+		m := ParseModule(tt.src)
+		f, ok := m.Statements[0].(*FunctionDef)
+		if !ok {
+			t.Fatalf("got=%v", m)
+		}
+		undefined := []string{}
+		for _, g := range f.UndefinedGotos() {
+			undefined = append(undefined, g.Label)
+		}
+		unused := []string{}
+		for _, l := range f.UnusedLabels() {
+			unused = append(unused, l.Name)
+		}
+		backward := []string{}
+		for _, g := range f.BackwardGotos() {
+			backward = append(backward, g.Label)
+		}
+		if !reflect.DeepEqual(undefined, tt.undefined) {
+			t.Errorf("undefined got=%v, expect=%v", undefined, tt.undefined)
+		}
+		if !reflect.DeepEqual(unused, tt.unused) {
+			t.Errorf("unused got=%v, expect=%v", unused, tt.unused)
+		}
+		if !reflect.DeepEqual(backward, tt.backward) {
+			t.Errorf("backward got=%v, expect=%v", backward, tt.backward)
+		}
+	}
+}
+
+func TestJumpPositions(t *testing.T) {
+	src := "int f(int a)\n{\nretry:\n    if (a)\n        goto retry;\n}\n"
+	testTbl := []struct {
+		comment string
+		opts    ParseOptions
+		label   Position
+		jump    Position
+	}{
+		{"without positions", ParseOptions{}, Position{}, Position{}},
+		{"with positions", ParseOptions{Positions: true}, Position{Offset: 15, Line: 3, Column: 1}, Position{Offset: 41, Line: 5, Column: 9}},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		m, _ := ParseWithOptions(src, tt.opts)
+		f := m.Statements[0].(*FunctionDef)
+		if len(f.Labels) != 1 || f.Labels[0].Pos != tt.label {
+			t.Errorf("got labels=%v, expect=%v", f.Labels, tt.label)
+		}
+		if len(f.Gotos) != 1 || f.Gotos[0].Pos != tt.jump {
+			t.Errorf("got gotos=%v, expect=%v", f.Gotos, tt.jump)
+		}
+	}
+}
@@ -1,13 +1,35 @@
 package symc
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
 
 type Lexer struct {
-	input string
-	pos   int
+	input  string
+	pos    int
+	start  int    // 読み込み中のトークンの開始位置
+	spans  []span // トークンごとのソース上の範囲
+	errors []lexError
+	// トークン数の上限. 0 の場合は制限しない
+	maxTokens int
+	truncated bool // 上限に達して字句解析を打ち切ったか
+	// 字句解析中に終了を確認するコンテキスト. nil の場合は確認しない
+	ctx  context.Context
+	halt error // コンテキストの終了で字句解析を打ち切った理由
+}
+
+// 字句解析のエラー
+type lexError struct {
+	span
+	msg string
+}
+
+// トークンのソース上の範囲 [start, end)
+type span struct {
+	start int
+	end   int
 }
 
 type Token struct {
@@ -106,9 +128,25 @@ func NewLexer(src string) *Lexer {
 
 func (l *Lexer) lexicalize() []*Token {
 	ts := []*Token{}
+	l.spans = []span{}
 	for {
 		t := l.nextToken()
+		if l.maxTokens > 0 && len(ts) >= l.maxTokens && t.tokenType != eof {
+			// 上限を超えた場合は以降を読まずに終端とする
+			l.truncated = true
+			l.start = l.pos
+			t = &Token{tokenType: eof, literal: "eof"}
+		}
+		if l.ctx != nil && len(ts)%ctxCheckInterval == 0 && t.tokenType != eof {
+			if err := l.ctx.Err(); err != nil {
+				// 終了した場合は以降を読まずに終端とする
+				l.halt = err
+				l.start = l.pos
+				t = &Token{tokenType: eof, literal: "eof"}
+			}
+		}
 		ts = append(ts, t)
+		l.spans = append(l.spans, span{l.clamp(l.start), l.clamp(l.pos)})
 		if t.tokenType == eof {
 			break
 		}
@@ -130,6 +168,8 @@ func (l *Lexer) nextToken() *Token {
 		l.pos++
 	}
 
+	l.start = l.pos
+
 	// ソースの終端
 	if l.pos >= len(l.input) {
 		return &Token{tokenType: eof, literal: "eof"}
@@ -322,11 +362,31 @@ func (l *Lexer) nextToken() *Token {
 			tk = l.readWord()
 		} else if isDec(c) {
 			tk = l.readNumber()
+		} else {
+			// 解釈できない文字は1文字ずつ不正なトークンにする
+			tk = &Token{tokenType: illegal, literal: string(c)}
+			l.pos++
+			l.addError(l.pos, fmt.Sprintf("illegal character %q", c))
 		}
 	}
 	return tk
 }
 
+// clamp
+// 入力の終端を超えた位置を終端に丸める
+func (l *Lexer) clamp(pos int) int {
+	if pos > len(l.input) {
+		return len(l.input)
+	}
+	return pos
+}
+
+// addError
+// 読み込み中のトークンの開始位置から end までの範囲にエラーを記録する
+func (l *Lexer) addError(end int, msg string) {
+	l.errors = append(l.errors, lexError{span{l.start, end}, msg})
+}
+
 func (l *Lexer) readWord() *Token {
 	// ワードの終わりの次まで pos を進める
 	var next int
@@ -348,7 +408,7 @@ func (l *Lexer) readNumber() *Token {
 
 	next = l.pos
 	c := l.input[next]
-	if c == '0' {
+	if c == '0' && next+1 < len(l.input) {
 		next++
 		c = l.input[next]
 		switch c {
@@ -402,16 +462,19 @@ func (l *Lexer) readString() *Token {
 	// 次の " を探す
 	for next = l.pos + 1; next < len(l.input); next++ {
 		// エスケープシーケンス考慮
-		if l.input[next] == '\\' && l.input[next+1] == '\\' {
-			next++
-		} else if l.input[next] == '\\' && l.input[next+1] == '"' {
+		if l.input[next] == '\\' && next+1 < len(l.input) {
 			next++
 		} else if l.input[next] == '"' {
 			break
 		}
 	}
 	// 次の pos に進める
-	next++
+	if next < len(l.input) {
+		next++
+	} else {
+		next = len(l.input)
+		l.addError(next, "unterminated string literal")
+	}
 	w := l.input[l.pos:next]
 	l.pos = next
 	return &Token{tokenType: str, literal: w}
@@ -436,37 +499,30 @@ func (l *Lexer) readHashComment() *Token {
 	return tk
 }
 
+// readLetter
+// 文字定数を読み込む. リテラルはシングルクォートの内側をそのまま持つ
 func (l *Lexer) readLetter() *Token {
+	var next int
 
-	l.pos++
-	var s []byte
-	c := l.input[l.pos]
-	if c == '\\' {
-		s = l.getEscC()
-		l.pos++
-	} else {
-		s = append(s, c)
-		l.pos++
-		l.pos++
+	// 次の ' を探す
+	for next = l.pos + 1; next < len(l.input); next++ {
+		c := l.input[next]
+		if c == '\\' && next+1 < len(l.input) {
+			// エスケープシーケンス考慮
+			next++
+		} else if c == '\'' || c == '\n' {
+			break
+		}
 	}
-	return &Token{tokenType: letter, literal: string(s)}
-}
 
-func (l *Lexer) getEscC() []byte {
-	res := []byte{}
-	res = append(res, l.input[l.pos])
-	l.pos++
-	if l.input[l.pos] >= '0' && l.input[l.pos] <= '9' {
-		for l.input[l.pos] >= '0' && l.input[l.pos] <= '9' {
-			res = append(res, l.input[l.pos])
-			l.pos++
-		}
+	s := l.input[l.pos+1 : next]
+	if next < len(l.input) && l.input[next] == '\'' {
+		l.pos = next + 1
 	} else {
-		res = append(res, l.input[l.pos])
-		l.pos++
+		l.pos = next
+		l.addError(next, "unterminated character constant")
 	}
-
-	return res
+	return &Token{tokenType: letter, literal: s}
 }
 
 func (l *Lexer) newIllegal() *Token {
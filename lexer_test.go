@@ -1,7 +1,7 @@
 package symc
 
 import (
-	_ "reflect"
+	"reflect"
 	"testing"
 )
 
@@ -941,3 +941,50 @@ int func(int a) {
 		}
 	}
 }
+
+func TestLexErrors(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		expect  []string
+	}{
+		{
+			"ok",
+			`int a = 'A';`,
+			nil,
+		},
+		{
+			"unterminated string",
+			`char *s = "abc`,
+			[]string{"unterminated string literal"},
+		},
+		{
+			"unterminated letter",
+			`char c = '\`,
+			[]string{"unterminated character constant"},
+		},
+		{
+			"trailing zero",
+			`int a = 0`,
+			nil,
+		},
+		{
+			"illegal",
+			"int a = @;",
+			[]string{"illegal character '@'"},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		l := NewLexer(tt.src)
+		l.lexicalize()
+		var got []string
+		for _, e := range l.errors {
+			got = append(got, e.msg)
+		}
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("got=%v, expect=%v", got, tt.expect)
+		}
+	}
+}
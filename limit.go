@@ -0,0 +1,91 @@
+package symc
+
+// limit モジュール
+// 病的な入力に対して解析を打ち切るための資源制限
+
+import (
+	"context"
+	"fmt"
+)
+
+// 制限を指定しない場合の再帰の深さの上限. スタックの枯渇を防ぐ
+const defaultMaxDepth = 10000
+
+// コンテキストの終了を確認する間隔(解析の手数)
+const ctxCheckInterval = 1024
+
+// LimitError 資源制限を超えたため解析を打ち切ったことを表すエラー
+type LimitError struct {
+	Pos   Position
+	Limit string // 超過した制限の名前
+	Max   int    // 制限値
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message())
+}
+
+// Message
+// 位置を除いたメッセージを返す
+func (e *LimitError) Message() string {
+	return fmt.Sprintf("parse aborted: %s limit (%d) exceeded", e.Limit, e.Max)
+}
+
+// halted
+// 解析を打ち切ったか
+func (p *Parser) halted() bool {
+	return p.halt != nil
+}
+
+// abort
+// 解析を打ち切る. 以降のトークンはソースの終端として扱う
+func (p *Parser) abort(err error) {
+	if p.halt == nil {
+		p.halt = err
+	}
+}
+
+// checkLimits
+// 手数, 再帰の深さ, コンテキストを確認し, 超えていれば解析を打ち切る
+func (p *Parser) checkLimits() {
+	if p.halted() {
+		return
+	}
+	p.steps++
+	if max := p.opts.MaxSteps; max > 0 && p.steps > max {
+		p.abort(&LimitError{Pos: p.curPosition(), Limit: "backtracking steps", Max: max})
+		return
+	}
+	max := p.opts.MaxDepth
+	if max <= 0 {
+		max = defaultMaxDepth
+	}
+	if len(p.rules) > max {
+		p.abort(&LimitError{Pos: p.curPosition(), Limit: "nesting depth", Max: max})
+		return
+	}
+	if p.ctx != nil && p.steps%ctxCheckInterval == 0 {
+		if err := p.ctx.Err(); err != nil {
+			p.abort(err)
+		}
+	}
+}
+
+// ParseContext
+// コンテキストの終了や資源制限の超過で打ち切り可能な解析を行う
+// 打ち切った場合はそれまでに解析できた結果とエラーを返す
+func (p *Parser) ParseContext(ctx context.Context) (*Module, error) {
+	p.ctx = ctx
+	if err := ctx.Err(); err != nil {
+		return &Module{[]Statement{}}, err
+	}
+	if err := p.lexer.halt; err != nil {
+		// 字句解析の途中で終了した
+		return &Module{[]Statement{}}, err
+	}
+	m := p.Parse()
+	if p.halted() {
+		return m, p.halt
+	}
+	return m, nil
+}
@@ -0,0 +1,136 @@
+package symc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	deep := "int f(void) { x = " + strings.Repeat("(", 300) + "1" + strings.Repeat(")", 300) + "; }\n"
+	testTbl := []struct {
+		comment string
+		src     string
+		opts    ParseOptions
+		expect  string // 超過した制限. 空の場合はエラーなし
+	}{
+		{
+			"within limits",
+			`int a;
+int f(void) { a = (1 + 2); }
+`,
+			ParseOptions{MaxTokens: 100, MaxDepth: 100, MaxSteps: 10000},
+			"",
+		},
+		{
+			"max tokens",
+			`int a;
+int f(void) { a = (1 + 2); }
+`,
+			ParseOptions{MaxTokens: 10},
+			"token",
+		},
+		{
+			"max depth",
+			deep,
+			ParseOptions{MaxDepth: 100},
+			"nesting depth",
+		},
+		{
+			"max steps",
+			deep,
+			ParseOptions{MaxSteps: 100},
+			"backtracking steps",
+		},
+		{
+			"default depth",
+			"int f(void) { x = " + strings.Repeat("(", 100000) + "1" + strings.Repeat(")", 100000) + "; }\n",
+			ParseOptions{},
+			"nesting depth",
+		},
+		{
+			// 中置演算子や前置演算子の並びは入れ子として数えない
+			"default depth flat expression",
+			"int table[] = { " + strings.Repeat("a + -b * ", 20000) + "1 };\nint f(void) { return " + strings.Repeat("a + ", 20000) + "1; }\n",
+			ParseOptions{},
+			"",
+		},
+		{
+			"default depth skip brace",
+			"struct s " + strings.Repeat("{", 100000) + strings.Repeat("}", 100000) + ";\n",
+			ParseOptions{},
+			"nesting depth",
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		_, err := ParseWithOptions(tt.src, tt.opts)
+		if tt.expect == "" {
+			if err != nil {
+				t.Errorf("got err=%v", err)
+			}
+			continue
+		}
+		var le *LimitError
+		if !errors.As(err, &le) {
+			t.Fatalf("got err=%v, expect LimitError", err)
+		}
+		if le.Limit != tt.expect {
+			t.Errorf("got=%v, expect=%v", le.Limit, tt.expect)
+		}
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m, err := ParseContext(ctx, "int a;\n", ParseOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got err=%v, expect=%v", err, context.Canceled)
+	}
+	if m == nil || len(m.Statements) != 0 {
+		t.Errorf("got=%v", m)
+	}
+
+	m, err = ParseContext(context.Background(), "int a;\n", ParseOptions{})
+	if err != nil {
+		t.Errorf("got err=%v", err)
+	}
+	if len(m.Statements) != 1 {
+		t.Errorf("got=%v", m)
+	}
+}
+
+func TestParseContextDeadline(t *testing.T) {
+	// 閉じていない関数定義が続くと, 失敗した試行の破棄を何度も繰り返す
+	src := strings.Repeat("int f(void){ a; ", 20000)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := ParseContext(ctx, src, ParseOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got err=%v, expect=%v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("got elapsed=%v, expect within 2s", d)
+	}
+}
+
+func TestAnalyzeCanceledDuringLex(t *testing.T) {
+	src := strings.Repeat("int a;\n", 10000)
+	// 字句解析中の 2 回目の確認で終了する
+	ctx := &cancelAfter{Context: context.Background(), n: 2}
+	a, err := Analyze(ctx, src, ParseOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got err=%v, expect=%v", err, context.Canceled)
+	}
+	if len(a.Module.Statements) != 0 {
+		t.Errorf("got=%v", a.Module)
+	}
+	if n := len(a.p.tokens); n != 2*ctxCheckInterval+1 {
+		t.Errorf("got tokens=%d, expect=%d", n, 2*ctxCheckInterval+1)
+	}
+}
@@ -0,0 +1,85 @@
+package symc
+
+// linemarker モジュール
+// プリプロセッサが出力する行マーカー ( # 行番号 "ファイル名" フラグ... ) を扱う
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 行マーカー
+type lineMarker struct {
+	offset int    // マーカーの次の行の先頭のバイト位置
+	line   int    // 次の行の元ファイルでの行番号
+	file   string // 元ファイル名
+	system bool   // システムヘッダ由来か(フラグ 3)
+}
+
+// parseLineMarker
+// コメントトークンのリテラルを行マーカーとして解釈する
+// end はマーカー行の次の行の先頭のバイト位置
+func parseLineMarker(lit string, end int) (lineMarker, bool) {
+	fs := strings.Fields(lit)
+	if len(fs) > 0 && fs[0] == "line" {
+		// #line 形式
+		fs = fs[1:]
+	}
+	if len(fs) < 2 {
+		return lineMarker{}, false
+	}
+	line, err := strconv.Atoi(fs[0])
+	if err != nil {
+		return lineMarker{}, false
+	}
+	// ファイル名は空白を含みうるため引用符の範囲で取り出す
+	q := strings.Index(lit, `"`)
+	r := strings.LastIndex(lit, `"`)
+	if q < 0 || r <= q {
+		return lineMarker{}, false
+	}
+	file, err := strconv.Unquote(lit[q : r+1])
+	if err != nil {
+		file = lit[q+1 : r]
+	}
+	m := lineMarker{offset: end, line: line, file: file}
+	for _, f := range strings.Fields(lit[r+1:]) {
+		if f == "3" {
+			m.system = true
+		}
+	}
+	return m, true
+}
+
+// lineMarkers
+// トークン列から行マーカーを出現順に取り出す
+func lineMarkers(tks []*Token, spans []span) []lineMarker {
+	ms := []lineMarker{}
+	for i, t := range tks {
+		if !t.isToken(comment) {
+			continue
+		}
+		if m, ok := parseLineMarker(t.literal, spans[i].end); ok {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// marker
+// バイト位置に有効な行マーカーを返す
+func (p *Parser) marker(offset int) (lineMarker, bool) {
+	i := sort.Search(len(p.markers), func(i int) bool { return p.markers[i].offset > offset }) - 1
+	if i < 0 {
+		return lineMarker{}, false
+	}
+	return p.markers[i], true
+}
+
+// inSystemHeader
+// バイト位置がシステムヘッダ由来の範囲にあるか
+func (p *Parser) inSystemHeader(offset int) bool {
+	m, ok := p.marker(offset)
+	return ok && m.system
+}
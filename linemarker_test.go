@@ -0,0 +1,78 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLineMarker(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		lit     string
+		ok      bool
+		expect  lineMarker
+	}{
+		{
+			"marker 1",
+			` 1 "hoge.c"`,
+			true,
+			lineMarker{offset: 10, line: 1, file: "hoge.c"},
+		},
+		{
+			"marker system header",
+			` 28 "/usr/include/stdio.h" 3 4`,
+			true,
+			lineMarker{offset: 10, line: 28, file: "/usr/include/stdio.h", system: true},
+		},
+		{
+			"marker line directive",
+			`line 5 "a b.c"`,
+			true,
+			lineMarker{offset: 10, line: 5, file: "a b.c"},
+		},
+		{
+			"pragma",
+			`pragma once`,
+			false,
+			lineMarker{},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		got, ok := parseLineMarker(tt.lit, 10)
+		if ok != tt.ok {
+			t.Fatalf("got ok=%v, expect ok=%v", ok, tt.ok)
+		}
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("got=%+v, expect=%+v", got, tt.expect)
+		}
+	}
+}
+
+func TestLineMarkerPosition(t *testing.T) {
+	src := `# 1 "hoge.c"
+# 1 "hoge.h" 1
+int a;
+
+int f(void) { x = 1 }
+# 2 "hoge.c" 2
+int g(void) { y = 2 }
+`
+	_, err := ParseWithOptions(src, ParseOptions{FileName: "hoge.i"})
+	el, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("got error=%v", err)
+	}
+	expect := []Position{
+		{File: "hoge.h", Offset: 56, Line: 3, Column: 21},
+		{File: "hoge.c", Offset: 93, Line: 2, Column: 21},
+	}
+	got := []Position{}
+	for _, e := range el {
+		got = append(got, e.Pos)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got=%v, expect=%v", got, expect)
+	}
+}
@@ -0,0 +1,240 @@
+package symc
+
+// lsif モジュール
+// 索引を LSIF (JSON Lines のグラフ) として書き出す
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+)
+
+// LSIF のバージョン
+const lsifVersion = "0.4.3"
+
+type lsifPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lsifToolInfo struct {
+	Name string `json:"name"`
+}
+
+type lsifHoverContent struct {
+	Language string `json:"language"`
+	Value    string `json:"value"`
+}
+
+type lsifHover struct {
+	Contents []lsifHoverContent `json:"contents"`
+}
+
+// lsifElement 頂点と辺. 使わないフィールドは書き出さない
+type lsifElement struct {
+	ID               int           `json:"id"`
+	Type             string        `json:"type"`
+	Label            string        `json:"label"`
+	Version          string        `json:"version,omitempty"`
+	ProjectRoot      string        `json:"projectRoot,omitempty"`
+	PositionEncoding string        `json:"positionEncoding,omitempty"`
+	ToolInfo         *lsifToolInfo `json:"toolInfo,omitempty"`
+	Kind             string        `json:"kind,omitempty"`
+	URI              string        `json:"uri,omitempty"`
+	LanguageID       string        `json:"languageId,omitempty"`
+	Start            *lsifPosition `json:"start,omitempty"`
+	End              *lsifPosition `json:"end,omitempty"`
+	Result           *lsifHover    `json:"result,omitempty"`
+	OutV             int           `json:"outV,omitempty"`
+	InV              int           `json:"inV,omitempty"`
+	InVs             []int         `json:"inVs,omitempty"`
+	Document         int           `json:"document,omitempty"`
+	Property         string        `json:"property,omitempty"`
+}
+
+// lsifWriter 頂点と辺に連番を振って書き出す
+type lsifWriter struct {
+	enc *json.Encoder
+	id  int
+	err error
+}
+
+// emit
+func (w *lsifWriter) emit(e *lsifElement) int {
+	w.id++
+	e.ID = w.id
+	if w.err == nil {
+		w.err = w.enc.Encode(e)
+	}
+	return e.ID
+}
+
+// vertex
+func (w *lsifWriter) vertex(e *lsifElement) int {
+	e.Type = "vertex"
+	return w.emit(e)
+}
+
+// edge
+func (w *lsifWriter) edge(label string, out int, in ...int) {
+	e := &lsifElement{Type: "edge", Label: label, OutV: out}
+	if len(in) == 1 && label != "contains" && label != "item" {
+		e.InV = in[0]
+	} else {
+		e.InVs = in
+	}
+	w.emit(e)
+}
+
+// item
+func (w *lsifWriter) item(out, doc int, property string, in []int) {
+	w.emit(&lsifElement{Type: "edge", Label: "item", OutV: out, InVs: in, Document: doc, Property: property})
+}
+
+// シンボルひとつ分の出現の範囲
+type lsifSymbol struct {
+	info  *SymbolInfo
+	docs  []int         // 出現した文書. 初出順
+	defs  map[int][]int // 文書ごとの定義の範囲
+	refs  map[int][]int // 文書ごとの参照の範囲
+	all   []int
+	local bool
+}
+
+// WriteLSIF
+// 索引を LSIF として書き出す. 文書とシンボルを出現順に並べる
+func WriteLSIF(w io.Writer, idx *Index) error {
+	bw := bufio.NewWriter(w)
+	lw := &lsifWriter{enc: json.NewEncoder(bw)}
+	lw.enc.SetEscapeHTML(false)
+
+	lw.vertex(&lsifElement{Label: "metaData", Version: lsifVersion, ProjectRoot: fileURI(idx.ProjectRoot, ""), PositionEncoding: "utf-16", ToolInfo: &lsifToolInfo{Name: "symc"}})
+	project := lw.vertex(&lsifElement{Label: "project", Kind: "c"})
+
+	infos := map[string]*SymbolInfo{}
+	for _, d := range idx.Documents {
+		for _, s := range d.Symbols {
+			infos[s.Symbol] = s
+		}
+	}
+
+	docs := []int{}
+	order := []string{}
+	syms := map[string]*lsifSymbol{}
+	for _, d := range idx.Documents {
+		doc := lw.vertex(&lsifElement{Label: "document", URI: fileURI(idx.ProjectRoot, d.Path), LanguageID: "c"})
+		docs = append(docs, doc)
+		ranges := []int{}
+		for _, o := range d.Occurrences {
+			start := &lsifPosition{o.Line, utf16Column(d, o.Line, o.Column)}
+			end := &lsifPosition{o.Line, utf16Column(d, o.Line, o.EndColumn)}
+			r := lw.vertex(&lsifElement{Label: "range", Start: start, End: end})
+			ranges = append(ranges, r)
+
+			// ローカルシンボルは文書ごとに区別する
+			key := o.Symbol
+			local := isLocalSymbol(o.Symbol)
+			if local {
+				key = d.Path + "\x00" + o.Symbol
+			}
+			s, ok := syms[key]
+			if !ok {
+				info := infos[o.Symbol]
+				if local {
+					info = localSymbolInfo(d, o.Symbol)
+				}
+				s = &lsifSymbol{info: info, defs: map[int][]int{}, refs: map[int][]int{}, local: local}
+				syms[key] = s
+				order = append(order, key)
+			}
+			if _, ok := s.defs[doc]; !ok {
+				if _, ok := s.refs[doc]; !ok {
+					s.docs = append(s.docs, doc)
+				}
+			}
+			if o.Roles&(RoleDefinition|RoleForwardDefinition) != 0 {
+				s.defs[doc] = append(s.defs[doc], r)
+			} else {
+				s.refs[doc] = append(s.refs[doc], r)
+			}
+			s.all = append(s.all, r)
+		}
+		if len(ranges) > 0 {
+			lw.edge("contains", doc, ranges...)
+		}
+	}
+
+	for _, key := range order {
+		s := syms[key]
+		rs := lw.vertex(&lsifElement{Label: "resultSet"})
+		for _, r := range s.all {
+			lw.edge("next", r, rs)
+		}
+		if s.info != nil {
+			hover := lw.vertex(&lsifElement{Label: "hoverResult", Result: &lsifHover{Contents: []lsifHoverContent{{Language: "c", Value: s.info.Signature}}}})
+			lw.edge("textDocument/hover", rs, hover)
+		}
+		if len(s.defs) > 0 {
+			def := lw.vertex(&lsifElement{Label: "definitionResult"})
+			lw.edge("textDocument/definition", rs, def)
+			for _, doc := range s.docs {
+				if rs := s.defs[doc]; len(rs) > 0 {
+					lw.item(def, doc, "", rs)
+				}
+			}
+		}
+		ref := lw.vertex(&lsifElement{Label: "referenceResult"})
+		lw.edge("textDocument/references", rs, ref)
+		for _, doc := range s.docs {
+			if rs := s.defs[doc]; len(rs) > 0 {
+				lw.item(ref, doc, "definitions", rs)
+			}
+			if rs := s.refs[doc]; len(rs) > 0 {
+				lw.item(ref, doc, "references", rs)
+			}
+		}
+	}
+	if len(docs) > 0 {
+		lw.edge("contains", project, docs...)
+	}
+
+	if lw.err != nil {
+		return lw.err
+	}
+	return bw.Flush()
+}
+
+// isLocalSymbol
+func isLocalSymbol(sym string) bool {
+	return len(sym) > 6 && sym[:6] == "local "
+}
+
+// localSymbolInfo
+func localSymbolInfo(d *IndexDocument, sym string) *SymbolInfo {
+	for _, s := range d.Symbols {
+		if s.Symbol == sym {
+			return s
+		}
+	}
+	return nil
+}
+
+// utf16Column
+// バイト単位の列を UTF-16 の符号単位の列に換算する. ソースの行がない場合はそのまま返す
+func utf16Column(d *IndexDocument, line, col int) int {
+	if line < 0 || line >= len(d.lines) || col > len(d.lines[line]) {
+		return col
+	}
+	n := 0
+	for s := d.lines[line][:col]; s != ""; {
+		r, size := utf8.DecodeRuneInString(s)
+		if r >= 0x10000 {
+			// サロゲートペア
+			n++
+		}
+		n++
+		s = s[size:]
+	}
+	return n
+}
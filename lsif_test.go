@@ -0,0 +1,141 @@
+package symc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWriteLSIF(t *testing.T) {
+	idx := buildTestIndex(t, nil)
+	var b bytes.Buffer
+	if err := WriteLSIF(&b, idx); err != nil {
+		t.Fatalf("got err=%v", err)
+	}
+
+	elems := map[int]*lsifElement{}
+	edges := []*lsifElement{}
+	for i, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		e := &lsifElement{}
+		if err := json.Unmarshal([]byte(l), e); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if e.ID != i+1 {
+			t.Fatalf("line %d: got id=%d", i+1, e.ID)
+		}
+		// 辺は参照する頂点より後に現れる
+		for _, in := range append(e.InVs, e.InV, e.OutV) {
+			if in != 0 && elems[in] == nil {
+				t.Fatalf("line %d: unknown vertex %d", i+1, in)
+			}
+		}
+		elems[e.ID] = e
+		if e.Type == "edge" {
+			edges = append(edges, e)
+		}
+	}
+	if elems[1].Label != "metaData" || elems[1].ProjectRoot != "file:///src" {
+		t.Errorf("got metaData=%+v", elems[1])
+	}
+	follow := func(out int, label string) []*lsifElement {
+		xs := []*lsifElement{}
+		for _, e := range edges {
+			if e.OutV == out && e.Label == label {
+				xs = append(xs, e)
+			}
+		}
+		return xs
+	}
+
+	// a.c の count への代入から a.h の extern 宣言と a.c の定義へ移動できる
+	var doc, rng int
+	for _, e := range elems {
+		if e.Label == "document" && e.URI == "file:///src/a.c" {
+			doc = e.ID
+		}
+	}
+	for _, in := range follow(doc, "contains")[0].InVs {
+		if r := elems[in]; r.Start.Line == 3 && r.Start.Character == 4 {
+			rng = r.ID
+		}
+	}
+	if rng == 0 {
+		t.Fatalf("range not found")
+	}
+	rs := follow(rng, "next")[0].InV
+	def := follow(rs, "textDocument/definition")[0].InV
+	uris := []string{}
+	for _, item := range follow(def, "item") {
+		for _, in := range item.InVs {
+			uris = append(uris, fmt.Sprintf("%s:%d:%d", elems[item.Document].URI, elems[in].Start.Line, elems[in].Start.Character))
+		}
+	}
+	expect := "file:///src/a.h:0:11 file:///src/a.c:0:4"
+	if strings.Join(uris, " ") != expect {
+		t.Errorf("got definitions=%v, expect=%v", uris, expect)
+	}
+
+	hover := elems[follow(rs, "textDocument/hover")[0].InV]
+	if hover.Result.Contents[0].Value != "count" {
+		t.Errorf("got hover=%+v", hover.Result)
+	}
+
+	refs := elems[follow(rs, "textDocument/references")[0].InV]
+	n := 0
+	for _, item := range follow(refs.ID, "item") {
+		n += len(item.InVs)
+	}
+	if n != 4 {
+		t.Errorf("got %d references, expect 4", n)
+	}
+}
+
+func TestWriteLSIFUTF16(t *testing.T) {
+	src := "char *s = \"日本語 😀\"; int count;\n"
+	m, err := ParseWithOptions(src, ParseOptions{FileName: "/src/u.c", Positions: true})
+	if err != nil {
+		t.Fatalf("got err=%v", err)
+	}
+
+	testTbl := []struct {
+		comment string
+		read    func(string) (string, error)
+		expect  string
+	}{
+		{
+			"columns in UTF-16 code units",
+			func(string) (string, error) { return src, nil },
+			"0:24-0:29",
+		},
+		{
+			"source not readable",
+			nil,
+			"0:32-0:37",
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		idx := BuildIndex([]*Module{m}, IndexOptions{ProjectRoot: "/src", Read: tt.read})
+		var b bytes.Buffer
+		if err := WriteLSIF(&b, idx); err != nil {
+			t.Fatalf("got err=%v", err)
+		}
+		actual := ""
+		for _, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+			e := &lsifElement{}
+			if err := json.Unmarshal([]byte(l), e); err != nil {
+				t.Fatal(err)
+			}
+			if e.Label == "range" {
+				// 最後の範囲が count
+				actual = fmt.Sprintf("%d:%d-%d:%d", e.Start.Line, e.Start.Character, e.End.Line, e.End.Character)
+			}
+		}
+		if actual != tt.expect {
+			t.Errorf("got=%v, expect=%v", actual, tt.expect)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package symc
+
+// metrics モジュール
+// 関数定義ごとの複雑度と規模を計測する
+
+import (
+	"fmt"
+)
+
+// Metrics 関数定義ひとつ分の計測結果
+type Metrics struct {
+	Name       string
+	Complexity int // サイクロマティック複雑度
+	MaxNesting int // 制御構文の最大ネスト数
+	Statements int // 文の数
+	Params     int // 引数の数
+	Returns    int // return の数
+	FanOut     int // 呼び出している関数の種類数
+	Globals    int // 参照・代入しているグローバル変数の種類数
+}
+
+func (m *Metrics) String() string {
+	return fmt.Sprintf("Metrics : Name=%s, Complexity=%d, MaxNesting=%d, Statements=%d, Params=%d, Returns=%d, FanOut=%d, Globals=%d",
+		m.Name, m.Complexity, m.MaxNesting, m.Statements, m.Params, m.Returns, m.FanOut, m.Globals)
+}
+
+// 関数定義解析中の計測情報
+type funcCounter struct {
+	nesting    int
+	maxNesting int
+	statements int
+	nested     [][2]int // 入れ子関数の本体のトークン範囲
+}
+
+// enterNest
+func (c *funcCounter) enterNest() {
+	c.nesting++
+	if c.nesting > c.maxNesting {
+		c.maxNesting = c.nesting
+	}
+}
+
+// leaveNest
+func (c *funcCounter) leaveNest() {
+	c.nesting--
+}
+
+// measure
+// 関数本体のトークン範囲 [start, end) と解析結果からメトリクスを算出する
+func (p *Parser) measure(f *FunctionDef, start, end int) *Metrics {
+	m := &Metrics{
+		Name:       f.Name,
+		Complexity: 1,
+		MaxNesting: p.counter.maxNesting,
+		Statements: p.counter.statements,
+		Params:     len(f.Params),
+	}
+
+	for i := start; i < end; i++ {
+		if p.inNested(i) {
+			continue
+		}
+		switch p.tokens[i].tokenType {
+		case keyIf, keyFor, keyWhile, keyCase, and, or, question:
+			// do-while は while 側で数える
+			m.Complexity++
+		case keyReturn:
+			m.Returns++
+		}
+	}
+
+	callees := map[string]bool{}
+	walkStatements(f.Statements, func(s Statement) {
+		if v, ok := s.(*CallFunc); ok {
+			callees[v.Name] = true
+		}
+	})
+	m.FanOut = len(callees)
+	// ローカル変数と列挙定数は参照した時点のスコープで除いてある
+	globals := map[string]bool{}
+	for i, n := range p.globalRefs {
+		if start <= i && i < end && !p.inNested(i) {
+			globals[n] = true
+		}
+	}
+	m.Globals = len(globals)
+
+	return m
+}
+
+// inNested
+// i 番目のトークンが計測中の関数の入れ子関数の本体に含まれるか
+func (p *Parser) inNested(i int) bool {
+	for _, r := range p.counter.nested {
+		if r[0] <= i && i < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// refGlobal
+// i 番目のトークンの識別子がファイルスコープの変数を指す場合に記憶する
+func (p *Parser) refGlobal(i int) {
+	if p.funcName == "" || !p.isGlobalVariable(p.tokens[i].literal) {
+		return
+	}
+	if p.globalRefs == nil {
+		p.globalRefs = map[int]string{}
+	}
+	p.undo = append(p.undo, undoEntry{kind: undoGlobal, index: i, ref: p.globalRefs[i]})
+	p.globalRefs[i] = p.tokens[i].literal
+}
+
+// declareEnumerators
+// トークン範囲 [from, to) の列挙型の本体で宣言した列挙定数を現在のスコープに宣言する
+func (p *Parser) declareEnumerators(from, to int) {
+	for i := from; i < to; i++ {
+		if !p.tokens[i].isToken(keyEnum) {
+			continue
+		}
+		j := i + 1
+		if j < to && p.tokens[j].isToken(word) {
+			j++
+		}
+		if j >= to || !p.tokens[j].isToken(lbrace) {
+			continue
+		}
+		// 列挙定数は本体の先頭と ',' の直後に現れる. '=' 以降の定数式は読み飛ばす
+		depth, head := 0, true
+		for j++; j < to; j++ {
+			t := p.tokens[j]
+			if depth == 0 && t.isToken(rbrace) {
+				break
+			}
+			switch {
+			case t.isToken(lparen) || t.isToken(lbracket) || t.isToken(lbrace):
+				depth++
+			case t.isToken(rparen) || t.isToken(rbracket) || t.isToken(rbrace):
+				depth--
+			case depth == 0 && t.isToken(comma):
+				head = true
+				continue
+			case head && t.isToken(word):
+				// 列挙定数は宣言したスコープで変数や typedef 名を隠す
+				p.declare(t.literal, false)
+				p.enumerators[len(p.scopes)-1][t.literal] = true
+			}
+			head = false
+		}
+		i = j
+	}
+}
+
+// walkStatements
+// 関数呼び出しの引数も含めて全ての文を順に訪問する
+func walkStatements(ss []Statement, f func(Statement)) {
+	for _, s := range ss {
+		f(s)
+		if c, ok := s.(*CallFunc); ok {
+			walkStatements(c.Args, f)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		expect  []*Metrics
+	}{
+		{
+			"metrics 1",
+			`
+void func(void) {}
+`,
+			[]*Metrics{
+				{Name: "func", Complexity: 1},
+			},
+		},
+		{
+			"metrics 2",
+			`
+int g_count;
+int g_flag;
+
+int func(int a, int b)
+{
+    int i;
+    if (a && b) {
+        for (i = 0; i < a; i++) {
+            if (i == b || g_flag) {
+                return 1;
+            }
+        }
+    } else if (a) {
+        g_count = sub(a);
+    }
+    return other(a) + sub(b);
+}
+`,
+			[]*Metrics{
+				{Name: "func", Complexity: 7, MaxNesting: 3, Statements: 7, Params: 2, Returns: 2, FanOut: 2, Globals: 2},
+			},
+		},
+		{
+			"metrics 3",
+			`
+void func(int x)
+{
+    switch (x) {
+    case 1:
+        a = x ? 1 : 2;
+        break;
+    case 2:
+        do {
+            x--;
+        } while (x);
+        break;
+    default:
+        break;
+    }
+}
+
+void func2(void)
+{
+    while (1)
+        func(b);
+}
+`,
+			[]*Metrics{
+				{Name: "func", Complexity: 5, MaxNesting: 2, Statements: 7, Params: 1, Returns: 0, FanOut: 0, Globals: 1},
+				{Name: "func2", Complexity: 2, MaxNesting: 1, Statements: 2, Params: 0, Returns: 0, FanOut: 1, Globals: 1},
+			},
+		},
+		{
+			"metrics 4",
+			`
+int func(int x)
+{
+    int y = ({ int _t = x; _t * 2; });
+    int inner(int a) { if (a) return g_b; return 0; }
+    return inner(y) + g_a;
+}
+`,
+			[]*Metrics{
+				{Name: "inner", Complexity: 2, MaxNesting: 1, Statements: 3, Params: 1, Returns: 2, FanOut: 0, Globals: 1},
+				{Name: "func", Complexity: 1, MaxNesting: 0, Statements: 5, Params: 1, Returns: 1, FanOut: 1, Globals: 1},
+			},
+		},
+		{
+			"case and default labels are not statements",
+			`
+int func(int x)
+{
+    switch (x) {
+    case 1:
+    case 2:
+        return 1;
+    default:
+        return 0;
+    }
+}
+`,
+			[]*Metrics{
+				{Name: "func", Complexity: 3, MaxNesting: 1, Statements: 3, Params: 1, Returns: 2, FanOut: 0, Globals: 0},
+			},
+		},
+		{
+			"enumerators are not globals",
+			`
+enum color { RED, GREEN = (1 << 2), BLUE };
+typedef enum { ON = 1, OFF } state;
+int g_mode;
+
+int func(int x)
+{
+    if (x == RED || x == BLUE) {
+        return ON;
+    }
+    g_mode = GREEN;
+    return OFF;
+}
+`,
+			[]*Metrics{
+				{Name: "func", Complexity: 3, MaxNesting: 1, Statements: 4, Params: 1, Returns: 2, FanOut: 0, Globals: 1},
+			},
+		},
+		{
+			"enumerators are scoped to their block",
+			`
+int g_state;
+
+int func(void)
+{
+    typedef enum { g_state = 1 } state;
+    return g_state;
+}
+
+int func2(void)
+{
+    return g_state;
+}
+`,
+			[]*Metrics{
+				{Name: "func", Complexity: 1, MaxNesting: 0, Statements: 2, Params: 0, Returns: 1, FanOut: 0, Globals: 0},
+				{Name: "func2", Complexity: 1, MaxNesting: 0, Statements: 1, Params: 0, Returns: 1, FanOut: 0, Globals: 1},
+			},
+		},
+		{
+			"locals are scoped to their block",
+			`
+int g;
+
+void func(void)
+{
+    {
+        int g;
+        g = 2;
+    }
+    g = 1;
+}
+`,
+			[]*Metrics{
+				{Name: "func", Complexity: 1, MaxNesting: 0, Statements: 3, Params: 0, Returns: 0, FanOut: 0, Globals: 1},
+			},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		got := ParseMetrics(tt.src)
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("\ngot=   %v\nexpect=%v\n", got, tt.expect)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package symc
+
+// options モジュール
+// 解析の振る舞いを切り替えるオプション
+
+// Dialect 解析対象とする C 言語の方言
+type Dialect int
+
+const (
+	DialectGNU Dialect = iota // GNU 拡張を受け付ける(既定)
+	DialectISO                // ISO C. 文式や入れ子関数などの GNU 拡張を受け付けない
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case DialectGNU:
+		return "gnu"
+	case DialectISO:
+		return "iso"
+	}
+	return "unknown"
+}
+
+// ParseOptions 解析オプション
+// ゼロ値は ParseModule と同じ振る舞いになる
+type ParseOptions struct {
+	FileName          string  // 位置情報に付与するファイル名. 行マーカーがある場合はそちらを優先する
+	Dialect           Dialect // 受け付ける方言
+	Positions         bool    // 各シンボルに位置情報を付与する
+	SkipSystemHeaders bool    // システムヘッダ由来の宣言を結果から除く
+	Strict            bool    // 宣言と式を文法どおりに検証し, 推測で解釈する代わりにエラーとする
+
+	// 資源制限. 0 の場合は制限しない
+	MaxTokens int // トークン数の上限
+	MaxDepth  int // 文法規則の再帰の深さの上限. 0 の場合は既定の上限を用いる
+	MaxSteps  int // 文法規則を試す回数(バックトラックを含む)の上限
+}
+
+// gnu
+// GNU 拡張を受け付けるか
+func (o *ParseOptions) gnu() bool {
+	return o.Dialect == DialectGNU
+}
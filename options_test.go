@@ -0,0 +1,139 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWithOptions(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		opts    ParseOptions
+		expect  *Module
+	}{
+		{
+			"default options",
+			`int a;
+int f(int x) { a = x; g(a); }
+`,
+			ParseOptions{},
+			&Module{
+				[]Statement{
+					&VariableDef{Name: "a"},
+					&FunctionDef{
+						Name:   "f",
+						Params: []*VariableDef{{Name: "x"}},
+						Statements: []Statement{
+							&Assigne{Name: "a"},
+							&RefVar{Name: "x", Local: true},
+							&CallFunc{Name: "g", Args: []Statement{&RefVar{Name: "a"}}},
+						},
+					},
+				},
+			},
+		},
+		{
+			"positions",
+			`int a;
+int f(int x) { a = x; g(a); }
+extern int b;
+void h(void);
+`,
+			ParseOptions{FileName: "hoge.c", Positions: true},
+			&Module{
+				[]Statement{
+					&VariableDef{Name: "a", Pos: Position{File: "hoge.c", Offset: 4, Line: 1, Column: 5}},
+					&FunctionDef{
+						Name:   "f",
+						Params: []*VariableDef{{Name: "x", Pos: Position{File: "hoge.c", Offset: 17, Line: 2, Column: 11}}},
+						Statements: []Statement{
+							&Assigne{Name: "a", Pos: Position{File: "hoge.c", Offset: 22, Line: 2, Column: 16}},
+							&RefVar{Name: "x", Pos: Position{File: "hoge.c", Offset: 26, Line: 2, Column: 20}, Local: true},
+							&CallFunc{Name: "g", Pos: Position{File: "hoge.c", Offset: 29, Line: 2, Column: 23},
+								Args: []Statement{&RefVar{Name: "a", Pos: Position{File: "hoge.c", Offset: 31, Line: 2, Column: 25}}}},
+						},
+						Pos: Position{File: "hoge.c", Offset: 11, Line: 2, Column: 5},
+					},
+					&VariableDecl{Name: "b", Pos: Position{File: "hoge.c", Offset: 48, Line: 3, Column: 12}},
+					&PrototypeDecl{Name: "h", Pos: Position{File: "hoge.c", Offset: 56, Line: 4, Column: 6}},
+				},
+			},
+		},
+		{
+			"skip system headers",
+			`# 1 "hoge.c"
+# 1 "/usr/include/stdio.h" 1 3 4
+extern int printf(const char *, ...);
+int stdio_var;
+# 2 "hoge.c" 2
+int a;
+`,
+			ParseOptions{SkipSystemHeaders: true},
+			&Module{
+				[]Statement{
+					&VariableDef{Name: "a"},
+				},
+			},
+		},
+		{
+			"iso dialect nested function",
+			`int a = 1;
+int f(void) { int g(void) { return a; } return g(); }
+int b;
+`,
+			ParseOptions{Dialect: DialectISO},
+			&Module{
+				[]Statement{
+					&VariableDef{Name: "a"},
+					&VariableDef{Name: "b"},
+				},
+			},
+		},
+		{
+			"iso dialect statement expression",
+			`int a = 1;
+int f(void) { x = ({ a; }) + 1; }
+int b;
+`,
+			ParseOptions{Dialect: DialectISO},
+			&Module{
+				[]Statement{
+					&VariableDef{Name: "a"},
+					&VariableDef{Name: "b"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		got, _ := ParseWithOptions(tt.src, tt.opts)
+		// 読み飛ばした宣言は比較対象外とする
+		got.Statements = withoutInvalid(got.Statements)
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("got=%v, expect=%v", got, tt.expect)
+		}
+	}
+}
+
+func TestParseWithOptionsErrors(t *testing.T) {
+	_, err := ParseWithOptions("int f(void) { x = 1 }\n", ParseOptions{FileName: "hoge.c"})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	expect := "hoge.c:1:21: expected ';', found '}'"
+	if err.Error() != expect {
+		t.Errorf("got=%v, expect=%v", err.Error(), expect)
+	}
+}
+
+func withoutInvalid(ss []Statement) []Statement {
+	ts := []Statement{}
+	for _, s := range ss {
+		if _, ok := s.(*InvalidStatement); !ok {
+			ts = append(ts, s)
+		}
+	}
+	return ts
+}
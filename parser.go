@@ -5,7 +5,10 @@ package symc
 // 構文解析後、次のトークンに必ず位置を合わせること
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 type Module struct {
@@ -47,6 +50,8 @@ type InvalidStatement struct {
 	Contents string
 	Tk       *Token
 	Remain   []*Token
+	Start    Position // 読み飛ばした範囲の先頭
+	End      Position // 読み飛ばした範囲の終端
 }
 
 func (v *InvalidStatement) statementNode() {}
@@ -59,6 +64,7 @@ func (v *InvalidStatement) PrettyString() string {
 
 type VariableDef struct {
 	Name string
+	Pos  Position // 識別子の位置. ParseOptions.Positions 指定時のみ
 }
 
 func (v *VariableDef) statementNode() {}
@@ -71,6 +77,7 @@ func (v *VariableDef) PrettyString() string {
 
 type VariableDecl struct {
 	Name string
+	Pos  Position // 識別子の位置. ParseOptions.Positions 指定時のみ
 }
 
 func (v *VariableDecl) statementNode() {}
@@ -83,6 +90,7 @@ func (v *VariableDecl) PrettyString() string {
 
 type PrototypeDecl struct {
 	Name string
+	Pos  Position // 識別子の位置. ParseOptions.Positions 指定時のみ
 }
 
 func (v *PrototypeDecl) statementNode() {}
@@ -97,6 +105,10 @@ type FunctionDef struct {
 	Name       string
 	Params     []*VariableDef
 	Statements []Statement
+	Labels     []*Label
+	Gotos      []*Goto
+	OldStyle   bool     // K&R 形式の関数定義
+	Pos        Position // 関数名の位置. ParseOptions.Positions 指定時のみ
 }
 
 func (v *FunctionDef) statementNode() {}
@@ -116,9 +128,12 @@ func (v *FunctionDef) PrettyString() string {
 	txt += ") {\n"
 
 	for _, t := range v.Statements {
-		txt += "    "
-		txt += t.PrettyString()
-		txt += "\n"
+		// 入れ子関数の場合は複数行になる
+		for _, l := range strings.Split(strings.TrimRight(t.PrettyString(), "\n"), "\n") {
+			txt += "    "
+			txt += l
+			txt += "\n"
+		}
 	}
 	txt += "}\n"
 
@@ -126,11 +141,16 @@ func (v *FunctionDef) PrettyString() string {
 }
 
 type RefVar struct {
-	Name string
+	Name  string
+	Pos   Position // 識別子の位置. ParseOptions.Positions 指定時のみ
+	Local bool     // ブロックスコープの宣言か仮引数を指す
 }
 
 func (v *RefVar) statementNode() {}
 func (v *RefVar) String() string {
+	if v.Local {
+		return fmt.Sprintf("RefVar : Name=%s LOCAL", v.Name)
+	}
 	return fmt.Sprintf("RefVar : Name=%s", v.Name)
 }
 func (v *RefVar) PrettyString() string {
@@ -138,11 +158,16 @@ func (v *RefVar) PrettyString() string {
 }
 
 type Assigne struct {
-	Name string
+	Name  string
+	Pos   Position // 識別子の位置. ParseOptions.Positions 指定時のみ
+	Local bool     // ブロックスコープの宣言か仮引数を指す
 }
 
 func (v *Assigne) statementNode() {}
 func (v *Assigne) String() string {
+	if v.Local {
+		return fmt.Sprintf("Assigne : Name=%s LOCAL", v.Name)
+	}
 	return fmt.Sprintf("Assigne : Name=%s", v.Name)
 }
 func (v *Assigne) PrettyString() string {
@@ -152,6 +177,7 @@ func (v *Assigne) PrettyString() string {
 type CallFunc struct {
 	Name string
 	Args []Statement
+	Pos  Position // 識別子の位置. ParseOptions.Positions 指定時のみ
 }
 
 func (v *CallFunc) statementNode() {}
@@ -172,6 +198,7 @@ func (v *CallFunc) PrettyString() string {
 
 type Typedef struct {
 	Name string
+	Pos  Position // 識別子の位置. ParseOptions.Positions 指定時のみ
 }
 
 func (v *Typedef) statementNode() {}
@@ -186,10 +213,35 @@ func (v *Typedef) PrettyString() string {
 type Parser struct {
 	lexer   *Lexer
 	tokens  []*Token
+	spans   []span
+	lines   lineTable
 	pos     int
 	prevPos int
-	errLog  string
 	leftVarInfo
+	counter  funcCounter
+	metrics  []*Metrics
+	funcName string
+	callArg
+	strLits map[int]*StringLit
+	jumps
+	diagnostics []*Diagnostic
+	rules       []string // 解析中の文法規則のスタック
+	farthest    *ParseError
+	errors      []*ParseError
+	opts        ParseOptions
+	markers     []lineMarker
+	ctx         context.Context
+	steps       int   // 規則を試した回数
+	halt        error // 解析を打ち切った理由
+	decls       []*topDecl
+	reach       int            // 参照した最も先のトークンの位置
+	skipped     []string       // トークンごとに読み飛ばした規則. 解析した場合は空
+	strictErr   *ParseError    // 厳密モードで文法上決められなかった箇所
+	scopes      []scope        // 外側から順に並べたスコープ. 先頭はファイルスコープ
+	topNames    scope          // 解析中のトップレベルの宣言で宣言した識別子
+	enumerators []scope        // スコープごとに列挙型の本体で宣言した列挙定数. scopes と同じ並び
+	globalRefs  map[int]string // 関数本体でファイルスコープの変数として参照した識別子. トークンの位置から名前
+	undo        []undoEntry    // 解析中のトップレベルの宣言で書き込んだ記録. 失敗した試行の破棄に用いる
 }
 
 // 代入先識別子情報
@@ -203,8 +255,30 @@ type leftVarInfo struct {
 // -----------------------------------------------------------
 
 func NewParser(l *Lexer) *Parser {
+	return NewParserWithOptions(l, ParseOptions{})
+}
+
+// NewParserWithOptions
+// オプションを指定して構文解析器を生成する
+func NewParserWithOptions(l *Lexer, opts ParseOptions) *Parser {
+	l.maxTokens = opts.MaxTokens
 	tks := l.lexicalize()
-	return &Parser{lexer: l, tokens: tks, pos: 0, prevPos: 0}
+	p := &Parser{lexer: l, tokens: tks, spans: l.spans, lines: newLineTable(l.input), pos: 0, prevPos: 0, opts: opts}
+	p.markers = lineMarkers(tks, l.spans)
+	if l.halt != nil {
+		p.abort(l.halt)
+	}
+	if l.truncated {
+		p.abort(&LimitError{Pos: p.position(l.spans[len(l.spans)-1].start), Limit: "token", Max: opts.MaxTokens})
+	}
+	for _, e := range l.errors {
+		p.diagnostics = append(p.diagnostics, &Diagnostic{
+			Start:   p.position(e.start),
+			End:     p.position(e.end),
+			Message: e.msg,
+		})
+	}
+	return p
 }
 
 // Parse
@@ -214,35 +288,124 @@ func (p *Parser) Parse() *Module {
 	return ast
 }
 
+// Errors
+// 解析に失敗した宣言ごとの構文エラーを出現順に返す
+func (p *Parser) Errors() []*ParseError {
+	return p.errors
+}
+
+// Diagnostics
+// 解析できずに読み飛ばした範囲を出現順に返す
+func (p *Parser) Diagnostics() []*Diagnostic {
+	sort.SliceStable(p.diagnostics, func(i, j int) bool {
+		return p.diagnostics[i].Start.Offset < p.diagnostics[j].Start.Offset
+	})
+	return p.diagnostics
+}
+
+// Metrics
+// 解析済みの関数定義のメトリクスを出現順に返す
+func (p *Parser) Metrics() []*Metrics {
+	return p.metrics
+}
+
 // trimComment
 func (p *Parser) trimComment() {
 	trimedTokens := []*Token{}
-	for _, t := range p.tokens {
+	trimedSpans := []span{}
+	for i, t := range p.tokens {
 		if !t.isToken(comment) {
 			trimedTokens = append(trimedTokens, t)
+			trimedSpans = append(trimedSpans, p.spans[i])
 		}
 	}
 	p.tokens = trimedTokens
+	p.spans = trimedSpans
 }
 
 func (p *Parser) parseModule() *Module {
 	ss := []Statement{}
-FOR:
 	for p.curToken().tokenType != eof {
 		if p.curToken().tokenType == comment {
 			p.pos++
 			continue
 		}
-		if ts := p.parseStatement(); ts != nil {
-			ss = append(ss, ts...)
-			for _, v := range ts {
-				if _, yes := v.(*InvalidStatement); yes {
-					break FOR
+		start := p.index()
+		ne := len(p.errors)
+		// 破棄はトップレベルの宣言を越えないため, 宣言ごとに記録を空にする
+		p.undo = p.undo[:0]
+		at := p.begin()
+		p.reach = start
+		p.topNames = scope{}
+		ts := p.parseStatement()
+		if p.halted() {
+			// 打ち切った宣言は結果に含めない
+			break
+		}
+		if ts == nil {
+			// 読み飛ばせなかった宣言も解析できなかったものとして扱う
+			if p.farthest == nil {
+				p.updateErrLog("declaration")
+			}
+			ts = []Statement{&InvalidStatement{Contents: p.invalidContents(), Tk: p.curToken(), Remain: p.tokens[p.index():]}}
+		}
+		parsed := -1
+		if p.strictErr != nil {
+			// 厳密モードで決められなかった宣言は解析できなかったものとして扱う
+			parsed = p.pos
+			p.farthest = p.strictErr
+			ts = []Statement{&InvalidStatement{Contents: p.invalidContents(), Tk: p.tokens[p.strictErr.index], Remain: p.tokens[p.strictErr.index:]}}
+			p.discard(at)
+		}
+		if len(onlyInvalid(ts)) == 0 {
+			p.declareStatements(ts)
+		}
+		if p.opts.SkipSystemHeaders && p.inSystemHeader(p.spans[start].start) {
+			// システムヘッダ由来の宣言は結果に含めない
+			ts = onlyInvalid(ts)
+		}
+		ss = append(ss, ts...)
+		for _, v := range ts {
+			if inv, yes := v.(*InvalidStatement); yes {
+				// 次の文の先頭まで読み飛ばして解析を続ける
+				p.discard(at)
+				p.pos = start
+				p.synchronize()
+				if p.index() < parsed {
+					// 解析できた宣言の途中では再開しない
+					p.pos = parsed
+				}
+				p.skip("synchronize", start, p.index())
+				inv.Start = p.position(p.spans[start].start)
+				inv.End = p.position(p.spans[p.index()-1].end)
+				p.diagnostics = append(p.diagnostics, &Diagnostic{
+					Start:   inv.Start,
+					End:     inv.End,
+					Message: fmt.Sprintf("skipped unparsable declaration starting at '%s'", p.tokens[start].literal),
+				})
+				if p.farthest != nil {
+					p.errors = append(p.errors, p.farthest)
 				}
 			}
 		}
-		// エラーログを初期化
-		p.errLog = ""
+		if p.index() <= start {
+			// 読み進められなかった場合は無限ループを避けるため読み飛ばす
+			p.pos = start
+			p.synchronize()
+		}
+		p.pos = p.index()
+		// 差分解析のために宣言ごとの範囲と結果を記憶する
+		p.decls = append(p.decls, &topDecl{
+			start: p.spans[start].start,
+			end:   p.spans[p.index()-1].end,
+			reach: p.spans[p.reach].end,
+			stmts: ts,
+			errs:  p.errors[ne:len(p.errors):len(p.errors)],
+			names: p.topNames,
+		})
+		// エラーを初期化
+		p.farthest = nil
+		p.strictErr = nil
 	}
 	m := &Module{ss}
 	return m
@@ -250,12 +413,16 @@ FOR:
 
 // parseStatement
 func (p *Parser) parseStatement() []Statement {
+	defer p.trace("parseStatement")()
 	ss := []Statement{}
 	switch p.curToken().tokenType {
 	case keyTypedef:
-		if p.skipStructureLike() == nil {
+		start := p.pos
+		if ss, _ := p.skipStructureLike(); ss == nil {
 			return nil
 		}
+		p.declareTypedefs(start, p.pos)
+		p.declareEnumerators(start, p.pos)
 	case keyExtern:
 		prePos := p.pos
 		ss = p.parsePrototypeDecl()
@@ -264,43 +431,74 @@ func (p *Parser) parseStatement() []Statement {
 			ss = p.parseVariableDecl()
 		}
 		if ss == nil {
-			return []Statement{&InvalidStatement{Contents: p.errLog, Tk: p.curToken(), Remain: p.tokens[p.pos:]}}
-		}
-	case keyUnion:
-		if p.skipStructureLike() == nil {
-			return nil
-		}
-	case keyStruct:
-		if p.skipStructureLike() == nil {
-			return nil
+			return []Statement{&InvalidStatement{Contents: p.invalidContents(), Tk: p.curToken(), Remain: p.tokens[p.index():]}}
+		}
+	case keyUnion, keyStruct, keyEnum:
+		start := p.pos
+		at := p.begin()
+		if p.opts.Strict {
+			// 厳密モードでは本体のない構造体などの変数や関数の宣言を読み飛ばさずに解析する
+			if ts := p.tryDeclaration(); ts != nil {
+				ss = ts
+				break
+			}
+			p.pos = start
+			p.discard(at)
+			p.strictErr = nil
+		}
+		if ts, declarator := p.skipStructureLike(); ts != nil {
+			p.declareEnumerators(start, p.pos)
+			if p.opts.Strict && declarator >= 0 {
+				// 本体に続く宣言子は解析できないため誤りとする
+				p.ambiguous(declarator, "';'")
+			}
+			break
 		}
-	case keyEnum:
-		if p.skipStructureLike() == nil {
+		// 構造体などを返す関数の定義
+		p.pos = start
+		ss = p.parseFunctionDef()
+		if ss == nil {
+			p.pos = start
+			p.discard(at)
 			return nil
 		}
 	case keyAttribute:
+		start := p.pos
 		p.pos++
 		p.skipParen()
+		p.skip("parseAttribute", start, p.pos)
 	default:
-		prePos := p.pos
-		ss = p.parseFunctionDef()
+		ss = p.tryDeclaration()
 		if ss == nil {
-			p.pos = prePos
-			ss = p.parsePrototypeDecl()
-		}
-		if ss == nil {
-			p.pos = prePos
-			ss = p.parseVariableDef()
-		}
-		if ss == nil {
-			return []Statement{&InvalidStatement{Contents: p.errLog, Tk: p.curToken(), Remain: p.tokens[p.pos:]}}
+			return []Statement{&InvalidStatement{Contents: p.invalidContents(), Tk: p.curToken(), Remain: p.tokens[p.index():]}}
 		}
 	}
 	return ss
 }
 
+// tryDeclaration
+// 関数定義, プロトタイプ宣言, 変数定義の順に解析を試す
+func (p *Parser) tryDeclaration() []Statement {
+	prePos := p.pos
+	at := p.begin()
+	ss := p.parseFunctionDef()
+	if ss == nil {
+		p.pos = prePos
+		p.discard(at)
+		p.strictErr = nil
+		ss = p.parsePrototypeDecl()
+	}
+	if ss == nil {
+		p.pos = prePos
+		p.discard(at)
+		ss = p.parseVariableDef()
+	}
+	return ss
+}
+
 // parseVariableDef
 func (p *Parser) parseVariableDef() []Statement {
+	defer p.trace("parseVariableDef")()
 	ss := []Statement{}
 
 	prePos := p.pos
@@ -310,7 +508,7 @@ func (p *Parser) parseVariableDef() []Statement {
 		ts = p.parseNormalVarDef()
 	}
 	if ts == nil {
-		p.updateErrLog(fmt.Sprintf("parseVariableDef:token[%s]", p.curToken().literal))
+		p.updateErrLog("variable definition")
 		return nil
 	}
 	ss = append(ss, ts...)
@@ -319,14 +517,31 @@ func (p *Parser) parseVariableDef() []Statement {
 
 // parseFuncPointerVarDef
 func (p *Parser) parseFuncPointerVarDef() []Statement {
+	defer p.trace("parseFuncPointerVarDef")()
+
+	// 宣言は ( から始まらない. 型名の位置に変数や関数として宣言済みの識別子があれば式とみなす
+	specs := 0
+	start := p.index()
+	end := start
+	for ; end < len(p.tokens)-1 && p.look(end).isTypeToken(); end++ {
+		if !p.tokens[end].isToken(asterisk) && !p.tokens[end].isToken(caret) {
+			specs++
+		}
+	}
+	// 関数ポインタの宣言子は ( * から始まる
+	pointer := p.look(end).isToken(lparen) && (p.look(end+1).isToken(asterisk) || p.look(end+1).isToken(caret))
+	if specs == 0 || !pointer || p.hasOrdinaryName(start, end) {
+		p.updateErrLog("declaration specifiers")
+		return nil
+	}
 
 	ss := p.parseFuncPointerVarDefSub()
 	if ss == nil {
-		p.updateErrLog(fmt.Sprintf("parseFuncPointerVarDef:token[%s]", p.curToken().literal))
+		p.updateErrLog("function pointer declarator")
 		return nil
 	}
 	if !p.curToken().isToken(semicolon) {
-		p.updateErrLog(fmt.Sprintf("parseFuncPointerVarDef:token[%s]", p.curToken().literal))
+		p.updateErrLog("';'")
 		return nil
 	}
 	p.pos++
@@ -335,15 +550,18 @@ func (p *Parser) parseFuncPointerVarDef() []Statement {
 
 // parseNormalVarDef
 func (p *Parser) parseNormalVarDef() []Statement {
+	defer p.trace("parseNormalVarDef")()
 	ss := []Statement{}
 
 	if !p.checkExists2word() {
 		// 型名と変数名の合計が2以上なければ変数定義ではない
-		p.updateErrLog(fmt.Sprintf("parseNormalVarDef:token[%s]", p.curToken().literal))
+		p.updateErrLog("type name and identifier")
 		return nil
 	}
 
-	for {
+	start := p.pos
+	ambiguous := false
+	for first := true; ; first = false {
 
 		if p.curToken().isToken(semicolon) {
 			p.pos++
@@ -352,6 +570,7 @@ func (p *Parser) parseNormalVarDef() []Statement {
 			p.pos++
 		}
 
+		declStart := p.pos
 		for p.curToken().isTypeToken() {
 			p.pos++
 		}
@@ -361,11 +580,43 @@ func (p *Parser) parseNormalVarDef() []Statement {
 			!p.curToken().isToken(keyAsm) &&
 			!p.curToken().isToken(keyAttribute) &&
 			!p.curToken().isToken(lbracket) {
-			p.updateErrLog(fmt.Sprintf("parseNormalVarDef:token[%s]", p.curToken().literal))
+			p.updateErrLog("';'")
+			return nil
+		}
+		if p.opts.Strict {
+			// 2 つ目以降の宣言子はポインタと識別子のみ
+			var ok bool
+			if first {
+				var s specifiers
+				s, ok = p.checkStrict(declStart, p.pos, true)
+				// ブロック内の 未宣言の型名 * 識別子 は乗算式とも解釈できる
+				ambiguous = ok && p.funcName != "" && s.isTypedefName() && s.pointer && p.hasUnknownName(declStart, p.pos-1)
+			} else {
+				ok = checkDeclarator(p.tokens[declStart:p.pos])
+			}
+			if !ok {
+				valid := checkDeclarator
+				if first {
+					valid = func(tks []*Token) bool {
+						_, ok := checkSpecifiers(tks, true)
+						return ok
+					}
+				}
+				var expected string
+				p.pos, expected = p.strictErrorAt(declStart, p.pos, valid, "';'")
+				p.updateErrLog(expected)
+				return nil
+			}
+		}
+		if first && p.hasOrdinaryName(declStart, p.pos-1) {
+			// 変数や関数として宣言済みの識別子は型名ではない
+			p.pos = declStart
+			p.updateErrLog("type name")
 			return nil
 		}
 		p.pos--
 		id := p.curToken().literal
+		idPos := p.symbolPosition(p.pos)
 		p.pos++
 
 		for p.curToken().isToken(lbracket) {
@@ -374,7 +625,7 @@ func (p *Parser) parseNormalVarDef() []Statement {
 			if !p.curToken().isToken(rbracket) {
 				p.parseExpression()
 				if !p.curToken().isToken(rbracket) {
-					p.updateErrLog(fmt.Sprintf("parseNormalVarDef:token[%s]", p.curToken().literal))
+					p.updateErrLog("']'")
 					return nil
 				}
 			}
@@ -382,41 +633,47 @@ func (p *Parser) parseNormalVarDef() []Statement {
 			p.pos++
 		}
 
-		ss = append(ss, &VariableDef{Name: id})
+		ss = append(ss, &VariableDef{Name: id, Pos: idPos})
 
 		if p.curToken().isToken(assign) {
 			// 初期化子あり
 			p.pos++
 			is := p.parseInitialValue()
 			if is == nil {
-				p.updateErrLog(fmt.Sprintf("parseNormalVarDef:token[%s]", p.curToken().literal))
+				p.updateErrLog("initializer")
 				return nil
 			}
 			ss = append(ss, is...)
 		} else if p.curToken().isToken(keyAsm) {
 			if p.parseAsm() == nil {
-				p.updateErrLog(fmt.Sprintf("parseNormalVarDef:token[%s]", p.curToken().literal))
+				p.updateErrLog("asm label")
 				return nil
 			}
 		} else if p.curToken().isToken(keyAttribute) {
 			if p.parseAttribute() == nil {
-				p.updateErrLog(fmt.Sprintf("parseNormalVarDef:token[%s]", p.curToken().literal))
+				p.updateErrLog("attribute")
 				return nil
 			}
 		}
 
 	}
+	if ambiguous {
+		p.ambiguous(start, "unambiguous declaration")
+	}
 	return ss
 }
 
-//parseAsm
+// parseAsm
 func (p *Parser) parseAsm() []Statement {
+	defer p.trace("parseAsm")()
 	if !p.curToken().isToken(keyAsm) {
-		p.updateErrLog(fmt.Sprintf("parseAsm:token[%s]", p.curToken().literal))
+		p.updateErrLog("'__asm'")
 		return nil
 	}
+	start := p.pos
 	p.pos++
 	p.skipParen()
+	p.skip("parseAsm", start, p.pos)
 
 	return []Statement{}
 }
@@ -439,18 +696,19 @@ func (p *Parser) checkExists2word() bool {
 
 // parseInitialValue
 func (p *Parser) parseInitialValue() []Statement {
+	defer p.trace("parseInitialValue")()
 
 	if p.curToken().isToken(lbrace) {
 		// 配列の初期化子
 		if p.parseArrValue() == nil {
-			p.updateErrLog(fmt.Sprintf("parseVariableDef:token[%s]", p.curToken().literal))
+			p.updateErrLog("initializer")
 			return nil
 		}
 	}
 
 	ss := p.parseExpression()
 	if ss == nil {
-		p.updateErrLog(fmt.Sprintf("parseVariableDef:token[%s]", p.curToken().literal))
+		p.updateErrLog("expression")
 		return nil
 	}
 	return ss
@@ -458,17 +716,18 @@ func (p *Parser) parseInitialValue() []Statement {
 
 // parseArrValue
 func (p *Parser) parseArrValue() []Statement {
+	defer p.trace("parseArrValue")()
 
 	switch p.curToken().tokenType {
 	case lbrace:
 		p.pos++
 		xs := p.parseArrValue()
 		if xs == nil {
-			p.updateErrLog(fmt.Sprintf("parseArrValue:token[%s]", p.curToken().literal))
+			p.updateErrLog("initializer")
 			return nil
 		}
 		if !p.curToken().isToken(rbrace) {
-			p.updateErrLog(fmt.Sprintf("parseArrValue:token[%s]", p.curToken().literal))
+			p.updateErrLog("'}'")
 			return nil
 		}
 		p.pos++
@@ -477,14 +736,27 @@ func (p *Parser) parseArrValue() []Statement {
 			p.pos++
 			xs = p.parseArrValue()
 			if xs == nil {
-				p.updateErrLog(fmt.Sprintf("parseArrValue:token[%s]", p.curToken().literal))
+				p.updateErrLog("initializer")
 				return nil
 			}
 		}
 	default:
+		start := p.pos
 		for !p.curToken().isToken(rbrace) && !p.curToken().isToken(semicolon) && !p.curToken().isToken(eof) {
+			if p.curToken().isToken(str) {
+				// 読み飛ばす要素の文字列リテラルも収集する
+				strStart := p.pos
+				lits := []string{}
+				for p.curToken().isToken(str) {
+					lits = append(lits, p.curToken().literal)
+					p.pos++
+				}
+				p.recordString(strStart, lits)
+				continue
+			}
 			p.pos++
 		}
+		p.skip("parseArrValue", start, p.pos)
 	}
 
 	return []Statement{}
@@ -492,6 +764,7 @@ func (p *Parser) parseArrValue() []Statement {
 
 // parseVariableDefSub
 func (p *Parser) parseVariableDefSub() []Statement {
+	defer p.trace("parseVariableDefSub")()
 	prePos := p.pos
 
 	// はじめに関数ポインタか確認
@@ -504,19 +777,25 @@ func (p *Parser) parseVariableDefSub() []Statement {
 		for p.curToken().isTypeToken() {
 			p.pos++
 		}
+		if p.pos == prePos {
+			// 宣言の先頭より前のトークンは参照しない
+			return nil
+		}
 		p.pos--
 
 		if p.curToken().tokenType != word {
 			return nil
 		}
 
-		s := &VariableDef{Name: p.curToken().literal}
+		s := &VariableDef{Name: p.curToken().literal, Pos: p.symbolPosition(p.pos)}
 
 		p.pos++
 
 		if p.curToken().tokenType == lbracket {
-			// 配列の場合
+			// 配列の場合. 要素数は解析しない
+			start := p.pos + 1
 			p.progUntil(rbracket)
+			p.skip("parseVariableDefSub", start, p.pos)
 			p.pos++
 		}
 		ss = append(ss, s)
@@ -527,6 +806,7 @@ func (p *Parser) parseVariableDefSub() []Statement {
 
 // parseFuncPointerVarDefSub
 func (p *Parser) parseFuncPointerVarDefSub() []Statement {
+	defer p.trace("parseFuncPointerVarDefSub")()
 	for p.curToken().isTypeToken() {
 		p.pos++
 	}
@@ -557,22 +837,23 @@ func (p *Parser) parseFuncPointerVarDefSub() []Statement {
 
 // parseVariableDecl
 func (p *Parser) parseVariableDecl() []Statement {
+	defer p.trace("parseVariableDecl")()
 	if !p.curToken().isToken(keyExtern) {
-		p.updateErrLog(fmt.Sprintf("parseVariableDecl:token[%s]", p.curToken().literal))
+		p.updateErrLog("'extern'")
 		return nil
 	}
 	p.pos++
 
 	ss := p.parseVariableDef()
 	if ss == nil {
-		p.updateErrLog(fmt.Sprintf("parseVariableDecl:token[%s]", p.curToken().literal))
+		p.updateErrLog("declaration")
 		return nil
 	}
 
 	// next
 
 	if len(ss) < 1 {
-		p.updateErrLog(fmt.Sprintf("parseVariableDecl:token[%s]", p.curToken().literal))
+		p.updateErrLog("declaration")
 		return nil
 	}
 
@@ -580,10 +861,10 @@ func (p *Parser) parseVariableDecl() []Statement {
 	for _, s := range ss {
 		defv, ok := s.(*VariableDef)
 		if !ok {
-			p.updateErrLog(fmt.Sprintf("parseVariableDecl:token[%s]", p.curToken().literal))
+			p.updateErrLog("declaration")
 			return nil
 		}
-		ts = append(ts, &VariableDecl{Name: defv.Name})
+		ts = append(ts, &VariableDecl{Name: defv.Name, Pos: defv.Pos})
 	}
 
 	return ts
@@ -591,6 +872,7 @@ func (p *Parser) parseVariableDecl() []Statement {
 
 // parsePrototypeDecl
 func (p *Parser) parsePrototypeDecl() []Statement {
+	defer p.trace("parsePrototypeDecl")()
 
 	if p.curToken().tokenType == keyExtern {
 		p.pos++
@@ -599,19 +881,23 @@ func (p *Parser) parsePrototypeDecl() []Statement {
 	xs := p.parsePrototypeDeclSub()
 
 	if xs == nil {
-		p.updateErrLog(fmt.Sprintf("parsePrototypeDecl_3:token[%s]", p.curToken().literal))
+		p.updateErrLog("function declarator")
 		return nil
 	}
 
 	if p.curToken().tokenType == keyAttribute {
 		// attribute の場合はセミコロンまでスキップ
+		start := p.pos
 		p.progUntil(semicolon)
+		p.skip("parseAttribute", start, p.pos)
 	} else if p.curToken().isToken(keyAsm) {
 		// __asm の場合はセミコロンまでスキップ
+		start := p.pos
 		p.progUntil(semicolon)
+		p.skip("parseAsm", start, p.pos)
 	} else if p.curToken().tokenType != semicolon {
 		// セミコロン意外はプロトタイプ宣言ではない
-		p.updateErrLog(fmt.Sprintf("parsePrototypeDecl_4:token[%s]", p.curToken().literal))
+		p.updateErrLog("';'")
 		return nil
 	}
 
@@ -621,28 +907,39 @@ func (p *Parser) parsePrototypeDecl() []Statement {
 
 }
 
-//parsePrototypeDeclSub
+// parsePrototypeDeclSub
 func (p *Parser) parsePrototypeDeclSub() []Statement {
+	defer p.trace("parsePrototypeDeclSub")()
+	start := p.pos
 	for p.curToken().isTypeToken() {
 		p.pos++
 	}
 
 	if p.curToken().tokenType != lparen {
 		// ( でなければプロトタイプ宣言ではない
-		p.updateErrLog(fmt.Sprintf("parsePrototypeDeclSub:not lparen:token[%s]", p.curToken().literal))
+		if p.badSpecifierRun(start, p.pos) {
+			return nil
+		}
+		p.updateErrLog("'('")
+		return nil
+	}
+	if p.pos == start {
+		// 宣言の先頭より前のトークンは参照しない
+		p.updateErrLog("identifier")
 		return nil
 	}
 
 	p.pos--
 
 	if !p.curToken().isTypeToken() {
-		p.updateErrLog(fmt.Sprintf("parsePrototypeDeclSub_2:token[%s]", p.curToken().literal))
+		p.updateErrLog("identifier")
 		return nil
 	}
 
 	// Name
 	// 仮の識別子名を取得
 	id := p.curToken().literal
+	idPos := p.symbolPosition(p.pos)
 	p.pos++
 
 	// 入れ子のプロトタイプ宣言のパターンを解析する
@@ -658,7 +955,7 @@ func (p *Parser) parsePrototypeDeclSub() []Statement {
 	}
 
 	if xs == nil {
-		p.updateErrLog(fmt.Sprintf("parsePrototypeDeclSub_3:token[%s]", p.curToken().literal))
+		p.updateErrLog("function declarator")
 		return nil
 	}
 
@@ -666,26 +963,28 @@ func (p *Parser) parsePrototypeDeclSub() []Statement {
 	if len(xs) == 1 {
 		if v, ok := xs[0].(*PrototypeDecl); ok {
 			id = v.Name
+			idPos = v.Pos
 			if !p.curToken().isToken(rparen) {
-				p.updateErrLog(fmt.Sprintf("parsePrototypeDeclSub_4:token[%s]", p.curToken().literal))
+				p.updateErrLog("')'")
 				return nil
 			}
 			p.pos++
 			xs = p.parsePrototypeParameter()
 			if xs == nil {
-				p.updateErrLog(fmt.Sprintf("parsePrototypeDeclSub_5:token[%s]", p.curToken().literal))
+				p.updateErrLog("parameter list")
 				return nil
 			}
 		}
 	}
 
-	return []Statement{&PrototypeDecl{Name: id}}
+	return []Statement{&PrototypeDecl{Name: id, Pos: idPos}}
 
 }
 
 // parsePrototypeParameter
 // 構文解析のみ行い成功か失敗かを返すのみ
 func (p *Parser) parsePrototypeParameter() []Statement {
+	defer p.trace("parsePrototypeParameter")()
 	// lparen
 	if !p.curToken().isToken(lparen) {
 		return nil
@@ -711,7 +1010,7 @@ func (p *Parser) parsePrototypeParameter() []Statement {
 			xs = p.parsePrototypeFPointerVar()
 		}
 		if xs == nil {
-			p.updateErrLog(fmt.Sprintf("parsePrototypeParameter:token[%s]", p.curToken().literal))
+			p.updateErrLog("function pointer parameter")
 			return nil
 		}
 	}
@@ -719,18 +1018,19 @@ func (p *Parser) parsePrototypeParameter() []Statement {
 
 // parseVariadicArgument
 func (p *Parser) parseVariadicArgument() []Statement {
+	defer p.trace("parseVariadicArgument")()
 	if !p.curToken().isToken(period) {
-		p.updateErrLog(fmt.Sprintf("parseVariadicArgument:token[%s]", p.curToken().literal))
+		p.updateErrLog("'.'")
 		return nil
 	}
 	p.pos++
 	if !p.curToken().isToken(period) {
-		p.updateErrLog(fmt.Sprintf("parseVariadicArgument:token[%s]", p.curToken().literal))
+		p.updateErrLog("'.'")
 		return nil
 	}
 	p.pos++
 	if !p.curToken().isToken(period) {
-		p.updateErrLog(fmt.Sprintf("parseVariadicArgument:token[%s]", p.curToken().literal))
+		p.updateErrLog("'.'")
 		return nil
 	}
 	p.pos++
@@ -739,9 +1039,14 @@ func (p *Parser) parseVariadicArgument() []Statement {
 
 // parsePrototypeParamVar
 func (p *Parser) parsePrototypeParamVar() []Statement {
+	defer p.trace("parsePrototypeParamVar")()
+	start := p.pos
 	for p.curToken().isTypeToken() {
 		p.pos++
 	}
+	if p.badParameterRun(start, p.pos) {
+		return nil
+	}
 
 	if p.curToken().isToken(lbracket) {
 		// 配列の場合
@@ -753,11 +1058,11 @@ func (p *Parser) parsePrototypeParamVar() []Statement {
 		} else {
 			xs := p.parseExpression()
 			if xs == nil {
-				p.updateErrLog(fmt.Sprintf("parsePrototypeParamVar_1:token[%s]", p.curToken().literal))
+				p.updateErrLog("expression")
 				return nil
 			}
 			if !p.curToken().isToken(rbracket) {
-				p.updateErrLog(fmt.Sprintf("parsePrototypeParamVar_2:token[%s]", p.curToken().literal))
+				p.updateErrLog("']'")
 				return nil
 			}
 			p.pos++
@@ -769,43 +1074,48 @@ func (p *Parser) parsePrototypeParamVar() []Statement {
 	} else if p.curToken().isToken(keyAttribute) {
 		return p.parseAttribute()
 	} else {
-		p.updateErrLog(fmt.Sprintf("parsePrototypeParamVar_3:token[%s]", p.curToken().literal))
+		p.updateErrLog("',' or ')'")
 		return nil
 	}
 }
 
 // parsePrototypeFPointerVar
 func (p *Parser) parsePrototypeFPointerVar() []Statement {
+	defer p.trace("parsePrototypeFPointerVar")()
+	start := p.pos
 	for p.curToken().isTypeToken() {
 		p.pos++
 	}
 
 	if !p.curToken().isToken(lparen) {
-		p.updateErrLog(fmt.Sprintf("parsePrototypeFPointerVar_1:token[%s]", p.curToken().literal))
+		if p.badParameterRun(start, p.pos) {
+			return nil
+		}
+		p.updateErrLog("'('")
 		return nil
 	}
 	p.pos++
 
 	xs := p.parsePrototypeParamVar()
 	if xs == nil {
-		p.updateErrLog(fmt.Sprintf("parsePrototypeFPointerVar_2:token[%s]", p.curToken().literal))
+		p.updateErrLog("parameter")
 		return nil
 	}
 
 	if !p.curToken().isToken(rparen) {
-		p.updateErrLog(fmt.Sprintf("parsePrototypeFPointerVar_3:token[%s]", p.curToken().literal))
+		p.updateErrLog("')'")
 		return nil
 	}
 	p.pos++
 
 	if !p.curToken().isToken(lparen) {
-		p.updateErrLog(fmt.Sprintf("parsePrototypeFPointerVar_4:token[%s]", p.curToken().literal))
+		p.updateErrLog("'('")
 		return nil
 	}
 
 	xs = p.parsePrototypeParameter()
 	if xs == nil {
-		p.updateErrLog(fmt.Sprintf("parsePrototypeFPointerVar_5:token[%s]", p.curToken().literal))
+		p.updateErrLog("parameter list")
 		return nil
 	}
 
@@ -814,7 +1124,7 @@ func (p *Parser) parsePrototypeFPointerVar() []Statement {
 	} else if p.curToken().isToken(keyAttribute) {
 		return p.parseAttribute()
 	} else {
-		p.updateErrLog(fmt.Sprintf("parsePrototypeFPointerVar_6:token[%s]", p.curToken().literal))
+		p.updateErrLog("',' or ')'")
 		return nil
 	}
 
@@ -822,62 +1132,174 @@ func (p *Parser) parsePrototypeFPointerVar() []Statement {
 
 // parseAttribute
 func (p *Parser) parseAttribute() []Statement {
+	defer p.trace("parseAttribute")()
 	if !p.curToken().isToken(keyAttribute) {
-		p.updateErrLog(fmt.Sprintf("parseAttribute:token[%s]", p.curToken().literal))
+		p.updateErrLog("'__attribute__'")
 		return nil
 	}
+	start := p.pos
 	p.pos++
 	p.skipParen()
+	p.skip("parseAttribute", start, p.pos)
 	return []Statement{}
 }
 
 // parseFunctionDef
 func (p *Parser) parseFunctionDef() []Statement {
+	defer p.trace("parseFunctionDef")()
+	// 属性を除いた宣言指定子と識別子
+	tks := []*Token{}
+	start := p.pos
+	if p.curToken().isTypeToken() {
+		tks = append(tks, p.curToken())
+	}
 	// lparen or eof の手前まで pos を進める
 	for p.peekToken().isTypeToken() || p.peekToken().isToken(keyAttribute) {
 		p.pos++
 		if p.curToken().isToken(keyAttribute) {
+			start := p.pos
 			p.skipParen()
+			p.skip("parseAttribute", start, p.pos)
+		} else {
+			tks = append(tks, p.curToken())
 		}
 	}
 	if !p.peekToken().isToken(lparen) {
-		p.updateErrLog(fmt.Sprintf("parseFunctionDef:token[%s]", p.curToken().literal))
+		if len(tks) == p.pos-start+1 && p.badSpecifierRun(start, p.pos+1) {
+			return nil
+		}
+		p.updateErrLog("'('")
 		return nil
 	}
+	if p.opts.Strict {
+		if _, ok := checkSpecifiers(tks, true); !ok {
+			if len(tks) == p.pos-start+1 && p.badSpecifierRun(start, p.pos+1) {
+				return nil
+			}
+			p.updateErrLog("declaration specifiers")
+			return nil
+		}
+	}
 
 	// Name
 	id := p.curToken().literal
+	idPos := p.symbolPosition(p.pos)
 
 	p.pos++
 
 	// 引数のパース
+	psStart := p.pos
 	ps := p.parseParameter()
 
-	// lbrace かチェック
-	if p.curToken().tokenType != lbrace {
-		p.updateErrLog(fmt.Sprintf("parseFunctionDef:token[%s]", p.curToken().literal))
-		return nil
+	// 識別子並びの場合は K&R 形式の関数定義
+	oldStyle := len(ps) > 0 && p.isIdentifierList(psStart, p.pos)
+	if oldStyle && !p.curToken().isToken(lbrace) {
+		if p.parseOldStyleParamDecl(ps) == nil {
+			p.updateErrLog("parameter declaration")
+			return nil
+		}
 	}
 
+	// lbrace かチェック
+	if p.curToken().tokenType != lbrace {
+		p.updateErrLog("'{'")
+		return nil
+	}
+
+	// 計測情報は関数ごとに初期化する
+	outer := p.counter
+	outerName := p.funcName
+	outerJumps := p.jumps
+	p.counter = funcCounter{}
+	p.funcName = id
+	p.jumps = jumps{}
+	// 仮引数は関数本体から参照できる
+	p.pushScope()
+	for _, v := range ps {
+		p.declare(v.Name, false)
+	}
+	defer p.popScope()
+	defer func() {
+		p.counter = outer
+		p.funcName = outerName
+		p.jumps = outerJumps
+	}()
+
+	bodyStart := p.pos
 	ss := p.parseBlockStatement()
 	if ss == nil {
-		p.updateErrLog(fmt.Sprintf("parseFunctionDef:token[%s]", p.curToken().literal))
+		p.updateErrLog("compound statement")
 		return nil
 	}
 
-	return []Statement{&FunctionDef{Name: id, Params: ps, Statements: ss}}
+	f := &FunctionDef{Name: id, Params: ps, Statements: ss, Labels: p.jumps.labels, Gotos: p.jumps.gotos, OldStyle: oldStyle, Pos: idPos}
+	p.metrics = append(p.metrics, p.measure(f, bodyStart, p.pos))
+	// 入れ子関数の範囲は外側の関数の計測対象から除く
+	outer.nested = append(outer.nested, [2]int{bodyStart, p.pos})
+	return []Statement{f}
+}
+
+// isIdentifierList
+// [start, end) が ( 識別子, ... ) の並びか判定する
+func (p *Parser) isIdentifierList(start, end int) bool {
+	if end > len(p.tokens) || end-start < 3 || !p.tokens[start].isToken(lparen) || !p.tokens[end-1].isToken(rparen) {
+		return false
+	}
+	for i := start + 1; i < end-1; i++ {
+		if (i-start)%2 == 1 && (!p.tokens[i].isToken(word) || basicTypeWords[p.tokens[i].literal] || p.isTypedefName(p.tokens[i].literal)) {
+			// 型名は仮引数名ではない
+			return false
+		} else if (i-start)%2 == 0 && !p.tokens[i].isToken(comma) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseOldStyleParamDecl
+// K&R 形式の引数宣言を解析する
+// 宣言された名前は全て識別子並びに含まれている必要がある
+func (p *Parser) parseOldStyleParamDecl(ps []*VariableDef) []Statement {
+	defer p.trace("parseOldStyleParamDecl")()
+	names := map[string]bool{}
+	for _, v := range ps {
+		names[v.Name] = true
+	}
+
+	for !p.curToken().isToken(lbrace) {
+		ts := p.parseVariableDef()
+		if ts == nil {
+			p.updateErrLog("declaration")
+			return nil
+		}
+		for _, t := range ts {
+			v, ok := t.(*VariableDef)
+			if !ok || !names[v.Name] {
+				p.updateErrLog("parameter declaration")
+				return nil
+			}
+		}
+	}
+	return []Statement{}
 }
 
 // parseBlockStatement
 func (p *Parser) parseBlockStatement() []Statement {
+	defer p.trace("parseBlockStatement")()
 	ss := []Statement{}
+	p.pushScope()
+	defer p.popScope()
 
 	p.pos++
 
 	for p.curToken().tokenType != rbrace {
+		if p.curToken().isToken(eof) {
+			p.updateErrLog("'}'")
+			return nil
+		}
 		ts := p.parseInnerStatement()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseBlockStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("statement")
 			return nil
 		}
 		ss = append(ss, ts...)
@@ -890,162 +1312,216 @@ func (p *Parser) parseBlockStatement() []Statement {
 
 // parseInnerStatement
 func (p *Parser) parseInnerStatement() []Statement {
+	defer p.trace("parseInnerStatement")()
 	ss := []Statement{}
+	// ブロックとラベル, case と default ラベルは文の数に含めない
+	isStatement := true
 
 	switch p.curToken().tokenType {
 	case lbrace:
+		isStatement = false
 		ts := p.parseBlockStatement()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("compound statement")
 			return nil
 		}
 		ss = append(ss, ts...)
 	case keyExtern:
 		ts := p.parseVariableDecl()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("declaration")
 			return nil
 		}
+		p.declareStatements(ts)
 		ss = append(ss, ts...)
+	case keyTypedef:
+		start := p.pos
+		if ss, _ := p.skipStructureLike(); ss == nil {
+			p.updateErrLog("typedef declaration")
+			return nil
+		}
+		p.declareTypedefs(start, p.pos)
+		p.declareEnumerators(start, p.pos)
 	case keyReturn:
 		ts := p.parseReturn()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("return statement")
 			return nil
 		}
 		ss = append(ss, ts...)
 	case keyIf:
+		p.counter.enterNest()
 		ts := p.parseIfStatement()
+		p.counter.leaveNest()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("if statement")
 			return nil
 		}
 		ss = append(ss, ts...)
 	case keyFor:
+		p.counter.enterNest()
 		ts := p.parseForStatement()
+		p.counter.leaveNest()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("for statement")
 			return nil
 		}
 		ss = append(ss, ts...)
 	case keyWhile:
+		p.counter.enterNest()
 		ts := p.parseWhileStatement()
+		p.counter.leaveNest()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("while statement")
 			return nil
 		}
 		ss = append(ss, ts...)
 	case keyDo:
+		p.counter.enterNest()
 		ts := p.parseDoWhileStatement()
+		p.counter.leaveNest()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("do statement")
 			return nil
 		}
 		ss = append(ss, ts...)
 	case keySwitch:
+		p.counter.enterNest()
 		ts := p.parseSwitchStatement()
+		p.counter.leaveNest()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("switch statement")
 			return nil
 		}
 		ss = append(ss, ts...)
 	case keyCase:
+		isStatement = false
 		ts := p.parseCaseStatement()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("case label")
 			return nil
 		}
 		ss = append(ss, ts...)
 	case keyDefault:
+		isStatement = false
 		ts := p.parseDefaultStatement()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("default label")
 			return nil
 		}
 		ss = append(ss, ts...)
 	case keyBreak:
 		p.pos++
 		if !p.curToken().isToken(semicolon) {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("';'")
 			return nil
 		}
 		p.pos++
 	case keyContinue:
 		p.pos++
 		if !p.curToken().isToken(semicolon) {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("';'")
 			return nil
 		}
 		p.pos++
 	case keyGoto:
+		pos := p.symbolPosition(p.index())
 		p.pos++
 		if !p.curToken().isToken(word) {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("identifier")
 			return nil
 		}
+		g := &Goto{Label: p.curToken().literal, Order: p.jumps.order(), Pos: pos}
 		p.pos++
 		if !p.curToken().isToken(semicolon) {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("';'")
 			return nil
 		}
 		p.pos++
+		p.jumps.gotos = append(p.jumps.gotos, g)
 	default:
 		prevPos := p.pos
+		// 文式の中の文を解析した後にやり直す場合に備えて計測情報を記憶する
+		counter := p.counter
+		js := p.jumps
+		at := p.begin()
 		ts := p.parseLabel()
+		if ts == nil && p.opts.gnu() {
+			p.pos = prevPos
+			// GNU 拡張の入れ子関数
+			ts = p.parseFunctionDef()
+		} else if ts != nil {
+			isStatement = false
+		}
 		if ts == nil {
 			p.pos = prevPos
+			p.discard(at)
 			ts = p.parseVariableDef()
 		}
+		if ts != nil {
+			// 宣言した識別子は以降の文の解析に用いる
+			p.declareStatements(ts)
+		}
 		if ts == nil {
 			p.pos = prevPos
+			p.discard(at)
+			p.counter = counter
+			p.jumps = js
 			// other statement
 			ts = p.parseExpressionStatement()
 		}
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseInnerStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("expression statement")
 			return nil
 		}
 		ss = append(ss, ts...)
 	}
+	if isStatement {
+		p.counter.statements++
+	}
 	return ss
 }
 
 // parseLabel
 func (p *Parser) parseLabel() []Statement {
+	defer p.trace("parseLabel")()
 	if !p.curToken().isToken(word) {
-		p.updateErrLog(fmt.Sprintf("parseLabel:token[%s]", p.curToken().literal))
+		p.updateErrLog("identifier")
 		return nil
 	}
+	l := &Label{Name: p.curToken().literal, Order: p.jumps.order(), Pos: p.symbolPosition(p.index())}
 	p.pos++
 	if !p.curToken().isToken(colon) {
-		p.updateErrLog(fmt.Sprintf("parseLabel:token[%s]", p.curToken().literal))
+		p.updateErrLog("':'")
 		return nil
 	}
 	p.pos++
+	p.jumps.labels = append(p.jumps.labels, l)
 	return []Statement{}
 }
 
 // parseDoWhileStatement
 func (p *Parser) parseDoWhileStatement() []Statement {
+	defer p.trace("parseDoWhileStatement")()
 	ss := []Statement{}
 	// do
 	p.pos++
 
 	ts := p.parseBlockStatement()
 	if ts == nil {
-		p.updateErrLog(fmt.Sprintf("parseDoWhileStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("compound statement")
 		return nil
 	}
 
 	if !p.curToken().isToken(keyWhile) {
-		p.updateErrLog(fmt.Sprintf("parseDoWhileStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("'while'")
 		return nil
 	}
 
 	p.pos++
 	// lparen
 	if !p.curToken().isToken(lparen) {
-		p.updateErrLog(fmt.Sprintf("parseDoWhileStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("'('")
 		return nil
 	}
 
@@ -1053,18 +1529,18 @@ func (p *Parser) parseDoWhileStatement() []Statement {
 
 	us := p.parseExpression()
 	if us == nil {
-		p.updateErrLog(fmt.Sprintf("parseDoWhileStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("expression")
 		return nil
 	}
 
 	if !p.curToken().isToken(rparen) {
-		p.updateErrLog(fmt.Sprintf("parseDoWhileStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("')'")
 		return nil
 	}
 
 	p.pos++
 	if !p.curToken().isToken(semicolon) {
-		p.updateErrLog(fmt.Sprintf("parseDoWhileStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("';'")
 		return nil
 	}
 	p.pos++
@@ -1077,20 +1553,21 @@ func (p *Parser) parseDoWhileStatement() []Statement {
 
 // parseCaseStatement
 func (p *Parser) parseCaseStatement() []Statement {
+	defer p.trace("parseCaseStatement")()
 	if !p.curToken().isToken(keyCase) {
-		p.updateErrLog(fmt.Sprintf("parseCaseStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("'case'")
 		return nil
 	}
 	p.pos++
 
 	xs := p.parseValue()
 	if xs == nil {
-		p.updateErrLog(fmt.Sprintf("parseCaseStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("constant expression")
 		return nil
 	}
 
 	if !p.curToken().isToken(colon) {
-		p.updateErrLog(fmt.Sprintf("parseCaseStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("':'")
 		return nil
 	}
 	p.pos++
@@ -1100,6 +1577,10 @@ func (p *Parser) parseCaseStatement() []Statement {
 
 // parseValue
 func (p *Parser) parseValue() []Statement {
+	defer p.trace("parseValue")()
+	if p.opts.Strict {
+		return p.parseConstantExpression()
+	}
 	ss := []Statement{}
 	switch p.curToken().tokenType {
 	case word:
@@ -1109,6 +1590,7 @@ func (p *Parser) parseValue() []Statement {
 	case letter:
 		fallthrough
 	case integer:
+		p.skip("parseValue", p.pos, p.pos+1)
 		p.pos++
 		return ss
 	default:
@@ -1118,11 +1600,12 @@ func (p *Parser) parseValue() []Statement {
 
 // parseDefaultStatement
 func (p *Parser) parseDefaultStatement() []Statement {
+	defer p.trace("parseDefaultStatement")()
 	// default
 	p.pos++
 
 	if !p.curToken().isToken(colon) {
-		p.updateErrLog(fmt.Sprintf("parseDefaultStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("':'")
 		return nil
 	}
 	p.pos++
@@ -1132,37 +1615,38 @@ func (p *Parser) parseDefaultStatement() []Statement {
 
 // parseSwitchStatement
 func (p *Parser) parseSwitchStatement() []Statement {
+	defer p.trace("parseSwitchStatement")()
 	ss := []Statement{}
 
 	// switch
 	p.pos++
 
 	if !p.curToken().isToken(lparen) {
-		p.updateErrLog(fmt.Sprintf("parseSwitchStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("'('")
 		return nil
 	}
 	p.pos++
 
 	ts := p.parseExpression()
 	if ts == nil {
-		p.updateErrLog(fmt.Sprintf("parseSwitchStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("expression")
 		return nil
 	}
 	ss = append(ss, ts...)
 
 	if !p.curToken().isToken(rparen) {
-		p.updateErrLog(fmt.Sprintf("parseSwitchStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("')'")
 		return nil
 	}
 	p.pos++
 
 	if !p.curToken().isToken(lbrace) {
-		p.updateErrLog(fmt.Sprintf("parseSwitchStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("'{'")
 		return nil
 	}
 	ts = p.parseBlockStatement()
 	if ts == nil {
-		p.updateErrLog(fmt.Sprintf("parseSwitchStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("compound statement")
 		return nil
 	}
 	ss = append(ss, ts...)
@@ -1172,25 +1656,26 @@ func (p *Parser) parseSwitchStatement() []Statement {
 
 // parseWhileStatement
 func (p *Parser) parseWhileStatement() []Statement {
+	defer p.trace("parseWhileStatement")()
 	ss := []Statement{}
 
 	p.pos++
 
 	if !p.curToken().isToken(lparen) {
-		p.updateErrLog(fmt.Sprintf("parseWhileStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("'('")
 		return nil
 	}
 	p.pos++
 
 	ts := p.parseExpression()
 	if ts == nil {
-		p.updateErrLog(fmt.Sprintf("parseWhileStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("expression")
 		return nil
 	}
 	ss = append(ss, ts...)
 
 	if !p.curToken().isToken(rparen) {
-		p.updateErrLog(fmt.Sprintf("parseWhileStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("')'")
 		return nil
 	}
 	p.pos++
@@ -1199,14 +1684,14 @@ func (p *Parser) parseWhileStatement() []Statement {
 		// ブロック文
 		ts = p.parseBlockStatement()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseWhileStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("compound statement")
 			return nil
 		}
 	} else {
 		// １行命令の場合
 		ts = p.parseInnerStatement()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseForStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("statement")
 			return nil
 		}
 	}
@@ -1218,13 +1703,14 @@ func (p *Parser) parseWhileStatement() []Statement {
 
 // parseForStatement
 func (p *Parser) parseForStatement() []Statement {
+	defer p.trace("parseForStatement")()
 	ss := []Statement{}
 
 	// for
 	p.pos++
 
 	if !p.curToken().isToken(lparen) {
-		p.updateErrLog(fmt.Sprintf("parseForStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("'('")
 		return nil
 	}
 	p.pos++
@@ -1237,7 +1723,7 @@ func (p *Parser) parseForStatement() []Statement {
 			ts = p.parseExpression()
 		}
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseForStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("expression")
 			return nil
 		}
 		ss = append(ss, ts...)
@@ -1254,7 +1740,7 @@ func (p *Parser) parseForStatement() []Statement {
 		// ブロックの場合
 		ts := p.parseBlockStatement()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseForStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("compound statement")
 			return nil
 		}
 		ss = append(ss, ts...)
@@ -1262,7 +1748,7 @@ func (p *Parser) parseForStatement() []Statement {
 		// １行命令の場合
 		ts := p.parseInnerStatement()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseForStatement:token[%s]", p.curToken().literal))
+			p.updateErrLog("statement")
 			return nil
 		}
 		ss = append(ss, ts...)
@@ -1273,6 +1759,7 @@ func (p *Parser) parseForStatement() []Statement {
 
 // parseIfStatement
 func (p *Parser) parseIfStatement() []Statement {
+	defer p.trace("parseIfStatement")()
 	// if
 	p.pos++
 	// lparen
@@ -1283,7 +1770,7 @@ func (p *Parser) parseIfStatement() []Statement {
 	// 条件式
 	ts := p.parseExpression()
 	if ts == nil {
-		p.updateErrLog(fmt.Sprintf("parseIfStatement_1:token[%s]", p.curToken().literal))
+		p.updateErrLog("expression")
 		return nil
 	}
 	ss = append(ss, ts...)
@@ -1295,7 +1782,7 @@ func (p *Parser) parseIfStatement() []Statement {
 		// ブロック文
 		ts := p.parseBlockStatement()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseIfStatement_2:token[%s]", p.curToken().literal))
+			p.updateErrLog("compound statement")
 			return nil
 		}
 		ss = append(ss, ts...)
@@ -1306,7 +1793,7 @@ func (p *Parser) parseIfStatement() []Statement {
 				p.pos++
 				ts := p.parseIfStatement()
 				if ts == nil {
-					p.updateErrLog(fmt.Sprintf("parseIfStatement_3:token[%s]", p.curToken().literal))
+					p.updateErrLog("if statement")
 					return nil
 				}
 				ss = append(ss, ts...)
@@ -1315,7 +1802,7 @@ func (p *Parser) parseIfStatement() []Statement {
 				p.pos++
 				ts = p.parseBlockStatement()
 				if ts == nil {
-					p.updateErrLog(fmt.Sprintf("parseIfStatement_4:token[%s]", p.curToken().literal))
+					p.updateErrLog("compound statement")
 					return nil
 				}
 				ss = append(ss, ts...)
@@ -1325,7 +1812,7 @@ func (p *Parser) parseIfStatement() []Statement {
 		// １行命令
 		ts := p.parseInnerStatement()
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseIfStatement_5:token[%s]", p.curToken().literal))
+			p.updateErrLog("statement")
 			return nil
 		}
 		ss = append(ss, ts...)
@@ -1335,7 +1822,7 @@ func (p *Parser) parseIfStatement() []Statement {
 			p.pos++
 			ts = p.parseInnerStatement()
 			if ts == nil {
-				p.updateErrLog(fmt.Sprintf("parseIfStatement_6:token[%s]", p.curToken().literal))
+				p.updateErrLog("statement")
 				return nil
 			}
 			ss = append(ss, ts...)
@@ -1346,6 +1833,7 @@ func (p *Parser) parseIfStatement() []Statement {
 }
 
 func (p *Parser) parseReturn() []Statement {
+	defer p.trace("parseReturn")()
 	var ss []Statement = nil
 	if p.curToken().tokenType == keyReturn {
 		ss = []Statement{}
@@ -1353,8 +1841,16 @@ func (p *Parser) parseReturn() []Statement {
 		if p.curToken().tokenType != semicolon {
 			// 何らかの式がある
 			ts := p.parseExpression()
+			if p.opts.Strict && ts == nil {
+				p.updateErrLog("expression")
+				return nil
+			}
 			ss = append(ss, ts...)
 		}
+		if p.opts.Strict && !p.curToken().isToken(semicolon) {
+			p.updateErrLog("';'")
+			return nil
+		}
 		p.pos++
 		// next
 	}
@@ -1362,180 +1858,240 @@ func (p *Parser) parseReturn() []Statement {
 }
 
 func (p *Parser) parseExpressionStatement() []Statement {
+	defer p.trace("parseExpressionStatement")()
 	ss := p.parseExpression()
 	if ss == nil {
-		p.updateErrLog(fmt.Sprintf("parseExpressionStatement:token[%s]", p.curToken().literal))
+		p.updateErrLog("expression")
+		return nil
+	}
+	if !p.curToken().isToken(semicolon) {
+		p.updateErrLog("';'")
 		return nil
 	}
-	// semicolon
 	p.pos++
 	return ss
 }
 
 // parseExpression
+// 前置演算子と中置演算子の並びは再帰せずに繰り返し解析する. 再帰の深さは括弧などの入れ子の分のみ増える
 func (p *Parser) parseExpression() []Statement {
-	ss := []Statement{}
-
-	if p.curToken().isPrefixExpression() {
-		// 前置式
-		p.pos++
+	defer p.trace("parseExpression")()
+	all := []Statement{}
 
-		ts := p.parseExpression()
-		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseExpression:token[%s]", p.curToken().literal))
-			return nil
-		}
-		ss = append(ss, ts...)
-		return ss
-	}
+	incdec := false
+	for {
+		ss := []Statement{}
 
-	switch p.curToken().tokenType {
-	case semicolon:
-		// 空式
-	case lparen:
-		prePos := p.pos
-		ts := p.parseCast()
-		if ts != nil {
-			ss = append(ss, ts...)
-		} else {
-			p.pos = prePos
-			p.pos++
-			ts := p.parseExpression()
-			ss = append(ss, ts...)
-			// rparen
+		if p.curToken().isPrefixExpression() {
+			// 前置式
+			if p.curToken().isToken(increment) || p.curToken().isToken(decrement) {
+				incdec = true
+			}
 			p.pos++
-		}
-	case word:
-		ls := p.parseIdentifire()
-		if ls == nil {
-			p.updateErrLog(fmt.Sprintf("parseExpression:token[%s]", p.curToken().literal))
-			return nil
-		}
-		ss = append(ss, ls...)
-
-		// RefVar の場合あとで assigne や callfunc に変更する時のためにインデックスと変数名を記憶する
-		if refv, ok := ss[len(ss)-1].(*RefVar); ok {
-			p.leftVarInfo.idIndex = len(ss) - 1
-			p.leftVarInfo.idName = refv.Name
+			continue
 		}
 
-	case lbrace:
-		p.pos++
-		if !p.curToken().isToken(rbrace) {
-			p.updateErrLog(fmt.Sprintf("parseExpression:token[%s]", p.curToken().literal))
-			return nil
-		}
-		p.pos++
-	case keySizeof:
-		ts := p.parseSizeof()
-		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseExpression:token[%s]", p.curToken().literal))
-			return nil
-		}
-	case str:
-		// 文字列が連続する場合がある
-		for p.curToken().isToken(str) {
-			p.pos++
-		}
-	case float:
-		fallthrough
-	case letter:
-		fallthrough
-	case integer:
-		p.pos++
-	default:
-		p.updateErrLog(fmt.Sprintf("parseExpression:token[%s]", p.curToken().literal))
-		return nil
-	}
+		switch p.curToken().tokenType {
+		case semicolon:
+			// 空式
+		case lparen:
+			if p.peekToken().isToken(lbrace) {
+				if !p.opts.gnu() {
+					// ISO C では文式を受け付けない
+					p.updateErrLog("expression")
+					return nil
+				}
+				// GNU 拡張の文式
+				ts := p.parseStatementExpression()
+				if ts == nil {
+					p.updateErrLog("statement expression")
+					return nil
+				}
+				ss = append(ss, ts...)
+				break
+			}
+			prePos := p.pos
+			ts := p.parseCast()
+			if ts != nil {
+				ss = append(ss, ts...)
+			} else {
+				p.pos = prePos
+				p.pos++
+				ts := p.parseExpression()
+				if p.opts.Strict && (ts == nil || !p.curToken().isToken(rparen)) {
+					p.updateErrLog("')'")
+					return nil
+				}
+				ss = append(ss, ts...)
+				// rparen
+				p.pos++
+			}
+		case word:
+			idIndex := p.index()
+			ls := p.parseIdentifire()
+			if ls == nil {
+				p.updateErrLog("identifier")
+				return nil
+			}
+			if !p.curToken().isToken(lparen) {
+				// 関数呼び出しは変数の参照として数えない
+				p.refGlobal(idIndex)
+			}
+			ss = append(ss, ls...)
 
-	// 構造体アクセスか配列
-	for p.curToken().isToken(lbracket) ||
-		p.curToken().isToken(period) ||
-		p.curToken().isToken(arrow) {
+			// RefVar の場合あとで assigne や callfunc に変更する時のためにインデックスと変数名を記憶する
+			if refv, ok := ss[len(ss)-1].(*RefVar); ok {
+				p.leftVarInfo.idIndex = len(ss) - 1
+				p.leftVarInfo.idName = refv.Name
+			}
 
-		if p.curToken().isToken(lbracket) {
-			// 配列
-			ts := p.parseBracket()
-			if ts == nil {
-				p.updateErrLog(fmt.Sprintf("parseExpression:token[%s]", p.curToken().literal))
+		case lbrace:
+			p.pos++
+			if !p.curToken().isToken(rbrace) {
+				p.updateErrLog("'}'")
 				return nil
 			}
-			ss = append(ss, ts...)
-		} else {
-			// 構造体のアクセス
 			p.pos++
-			if xs := p.parseIdentifire(); xs == nil {
-				p.updateErrLog(fmt.Sprintf("parseExpression:token[%s]", p.curToken().literal))
+		case keySizeof:
+			ts := p.parseSizeof()
+			if ts == nil {
+				p.updateErrLog("sizeof expression")
 				return nil
 			}
+		case str:
+			// 文字列が連続する場合がある
+			start := p.pos
+			lits := []string{}
+			for p.curToken().isToken(str) {
+				lits = append(lits, p.curToken().literal)
+				p.pos++
+			}
+			p.recordString(start, lits)
+		case float:
+			fallthrough
+		case letter:
+			fallthrough
+		case integer:
+			p.pos++
+		default:
+			p.updateErrLog("expression")
+			return nil
 		}
-	}
 
-	// 後置演算式
-	if p.curToken().isPostExpression() {
-		if p.curToken().isToken(lparen) {
-			// 関数コール
-			p.pos++
-			as := []Statement{}
+		// 構造体アクセスか配列
+		for p.curToken().isToken(lbracket) ||
+			p.curToken().isToken(period) ||
+			p.curToken().isToken(arrow) {
 
-			if !p.curToken().isToken(rparen) {
-				// 引数あり
-				for {
-
-					// leftVarInfo 上書き防止
-					idIndex := p.leftVarInfo.idIndex
-					idName := p.leftVarInfo.idName
-					xs := p.parseExpression()
-					p.leftVarInfo.idIndex = idIndex
-					p.leftVarInfo.idName = idName
-
-					if xs == nil {
-						p.updateErrLog(fmt.Sprintf("parseExpression:token[%s]", p.curToken().literal))
-						return nil
-					}
-					as = append(as, xs...)
-
-					if p.curToken().isToken(rparen) {
-						break
-					} else if p.curToken().isToken(comma) {
-						p.pos++
-					} else {
-						p.updateErrLog(fmt.Sprintf("parseExpression:token[%s]", p.curToken().literal))
-						return nil
-					}
+			if p.curToken().isToken(lbracket) {
+				// 配列
+				ts := p.parseBracket()
+				if ts == nil {
+					p.updateErrLog("array subscript")
+					return nil
+				}
+				ss = append(ss, ts...)
+			} else {
+				// 構造体のアクセス
+				p.pos++
+				if xs := p.parseIdentifire(); xs == nil {
+					p.updateErrLog("identifier")
+					return nil
 				}
 			}
+		}
 
-			ss[p.leftVarInfo.idIndex] = &CallFunc{Name: p.leftVarInfo.idName, Args: as}
-			// p.pos++
+		// 後置演算式
+		if p.curToken().isPostExpression() {
+			if p.curToken().isToken(lparen) {
+				// 関数コール
+				p.pos++
+				as := []Statement{}
+
+				if !p.curToken().isToken(rparen) {
+					// 引数あり
+					outerCall := p.callArg
+					for i := 0; ; i++ {
+
+						// leftVarInfo 上書き防止
+						idIndex := p.leftVarInfo.idIndex
+						idName := p.leftVarInfo.idName
+						p.callArg = callArg{name: idName, index: i}
+						xs := p.parseExpression()
+						p.callArg = outerCall
+						p.leftVarInfo.idIndex = idIndex
+						p.leftVarInfo.idName = idName
+
+						if xs == nil {
+							p.updateErrLog("expression")
+							return nil
+						}
+						as = append(as, xs...)
+
+						if p.curToken().isToken(rparen) {
+							break
+						} else if p.curToken().isToken(comma) {
+							p.pos++
+						} else {
+							p.updateErrLog("',' or ')'")
+							return nil
+						}
+					}
+				}
+
+				if p.leftVarInfo.idIndex < len(ss) {
+					ss[p.leftVarInfo.idIndex] = &CallFunc{Name: p.leftVarInfo.idName, Args: as, Pos: refPosition(ss[p.leftVarInfo.idIndex])}
+				} else {
+					// 呼び出し先の識別子がない場合は引数の参照のみ残す
+					ss = append(ss, as...)
+				}
+				// p.pos++
+			} else {
+				// 後置のインクリメントとデクリメントは対象への書き込み
+				p.markAssigned(ss)
+			}
+			p.pos++
+		}
+		if incdec {
+			// 前置のインクリメントとデクリメントも同様
+			p.markAssigned(ss)
+			incdec = false
 		}
-		p.pos++
-	}
 
-	// 中置演算式
-	if p.curToken().isOperator() {
+		// 中置演算式
+		if !p.curToken().isOperator() {
+			all = append(all, ss...)
+			return all
+		}
 		if p.curToken().isToken(assign) || p.curToken().isCompoundOp() {
 			// 代入式の場合は対象の識別子を Assigne 型に変更
-			ss[p.leftVarInfo.idIndex] = &Assigne{p.leftVarInfo.idName}
-		} else if p.curToken().isToken(lparen) {
+			if p.leftVarInfo.idIndex < len(ss) {
+				ss[p.leftVarInfo.idIndex] = &Assigne{Name: p.leftVarInfo.idName, Pos: refPosition(ss[p.leftVarInfo.idIndex]), Local: refLocal(ss[p.leftVarInfo.idIndex])}
+			}
 		}
+		all = append(all, ss...)
 		p.pos++
-		r := p.parseExpression()
-		if r == nil {
-			p.updateErrLog(fmt.Sprintf("parseExpression:token[%s]", p.curToken().literal))
-			return nil
-		}
-		ss = append(ss, r...)
 	}
+}
 
-	return ss
+// markAssigned
+// 直前に解析した識別子の参照を書き込みに変更する
+func (p *Parser) markAssigned(ss []Statement) {
+	i := p.leftVarInfo.idIndex
+	if i >= len(ss) {
+		return
+	}
+	if v, ok := ss[i].(*RefVar); ok && v.Name == p.leftVarInfo.idName {
+		ss[i] = &Assigne{Name: v.Name, Pos: v.Pos, Local: v.Local}
+	}
 }
 
 // parseBracket
 func (p *Parser) parseBracket() []Statement {
+	defer p.trace("parseBracket")()
 	ss := []Statement{}
 	if !p.curToken().isToken(lbracket) {
-		p.updateErrLog(fmt.Sprintf("parseBracket:token[%s]", p.curToken().literal))
+		p.updateErrLog("'['")
 		return nil
 	}
 	if p.curToken().isToken(lbracket) {
@@ -1549,11 +2105,11 @@ func (p *Parser) parseBracket() []Statement {
 		p.leftVarInfo.idName = idName
 
 		if ts == nil {
-			p.updateErrLog(fmt.Sprintf("parseBracket:token[%s]", p.curToken().literal))
+			p.updateErrLog("expression")
 			return nil
 		}
 		if !p.curToken().isToken(rbracket) {
-			p.updateErrLog(fmt.Sprintf("parseBracket:token[%s]", p.curToken().literal))
+			p.updateErrLog("']'")
 			return nil
 		}
 		p.pos++
@@ -1565,39 +2121,78 @@ func (p *Parser) parseBracket() []Statement {
 
 // parseSizeof
 func (p *Parser) parseSizeof() []Statement {
+	defer p.trace("parseSizeof")()
 	if !p.curToken().isToken(keySizeof) {
-		p.updateErrLog(fmt.Sprintf("parseSizeof:token[%s]", p.curToken().literal))
+		p.updateErrLog("'sizeof'")
 		return nil
 	}
+	start := p.pos
 	p.pos++
 	if !p.curToken().isToken(lparen) {
-		p.updateErrLog(fmt.Sprintf("parseSizeof:token[%s]", p.curToken().literal))
+		p.updateErrLog("'('")
 		return nil
 	}
 	p.skipParen()
+	p.skip("parseSizeof", start, p.pos)
 
 	return []Statement{}
 }
 
 // parseCast
 func (p *Parser) parseCast() []Statement {
+	defer p.trace("parseCast")()
 	if p.curToken().tokenType != lparen {
-		p.updateErrLog(fmt.Sprintf("parseCast:token[%s]", p.curToken().literal))
+		p.updateErrLog("'('")
 		return nil
 	}
 	p.pos++
+	typeStart := p.pos
+	typeEnd := -1
+	isArray := false
 	for p.curToken().tokenType != rparen {
-		if !p.curToken().isTypeToken() {
-			p.updateErrLog(fmt.Sprintf("parseCast:token[%s]", p.curToken().literal))
+		if p.curToken().isToken(lbracket) {
+			// 配列型は複合リテラルの場合のみ
+			isArray = true
+			if typeEnd < 0 {
+				typeEnd = p.pos
+			}
+			p.progUntil(rbracket)
+			if p.curToken().isToken(eof) {
+				p.updateErrLog("']'")
+				return nil
+			}
+		} else if !p.curToken().isTypeToken() {
+			p.updateErrLog("type name")
 			return nil
 		}
 		p.pos++
 	}
+	if typeEnd < 0 {
+		typeEnd = p.pos
+	}
+	s, ok := p.checkStrict(typeStart, typeEnd, false)
+	if !ok || p.hasOrdinaryName(typeStart, typeEnd) {
+		p.pos = typeStart
+		p.updateErrLog("type name")
+		return nil
+	}
 
 	p.pos++
 
+	if p.curToken().isToken(lbrace) {
+		// 複合リテラル
+		return p.parseInitializerList()
+	}
+	if p.opts.Strict && s.isTypedefName() && !s.pointer && isAmbiguousAfterCast(p.curToken()) && p.hasUnknownName(typeStart, typeEnd) {
+		// 宣言のない (識別子) の後に単項演算子や括弧が続く場合はキャストか括弧付きの式か決められない
+		p.ambiguous(typeStart-1, "unambiguous cast or parenthesized expression")
+	}
+	if isArray {
+		p.updateErrLog("'{'")
+		return nil
+	}
 	if p.curToken().isToken(semicolon) {
-		p.updateErrLog(fmt.Sprintf("parseCast:token[%s]", p.curToken().literal))
+		p.updateErrLog("expression")
 		return nil
 	}
 	ss := p.parseExpression()
@@ -1605,20 +2200,124 @@ func (p *Parser) parseCast() []Statement {
 	return ss
 }
 
+// parseStatementExpression
+// GNU 拡張の文式 ({ ... }) を解析する
+func (p *Parser) parseStatementExpression() []Statement {
+	defer p.trace("parseStatementExpression")()
+	if !p.curToken().isToken(lparen) || !p.peekToken().isToken(lbrace) {
+		p.updateErrLog("statement expression")
+		return nil
+	}
+	p.pos++
+
+	// leftVarInfo 上書き防止
+	idIndex := p.leftVarInfo.idIndex
+	idName := p.leftVarInfo.idName
+	ss := p.parseBlockStatement()
+	p.leftVarInfo.idIndex = idIndex
+	p.leftVarInfo.idName = idName
+
+	if ss == nil {
+		p.updateErrLog("compound statement")
+		return nil
+	}
+	if !p.curToken().isToken(rparen) {
+		p.updateErrLog("')'")
+		return nil
+	}
+	p.pos++
+	return ss
+}
+
+// parseInitializerList
+// { .member = 値, [添字] = 値, ... } 形式の初期化子を解析し参照を抽出する
+func (p *Parser) parseInitializerList() []Statement {
+	defer p.trace("parseInitializerList")()
+	if !p.curToken().isToken(lbrace) {
+		p.updateErrLog("'{'")
+		return nil
+	}
+	p.pos++
+
+	// leftVarInfo 上書き防止
+	idIndex := p.leftVarInfo.idIndex
+	idName := p.leftVarInfo.idName
+	defer func() {
+		p.leftVarInfo.idIndex = idIndex
+		p.leftVarInfo.idName = idName
+	}()
+
+	ss := []Statement{}
+	for !p.curToken().isToken(rbrace) {
+		// 指示子
+		designated := false
+		for p.curToken().isToken(period) || p.curToken().isToken(lbracket) {
+			designated = true
+			if p.curToken().isToken(period) {
+				p.pos++
+				if !p.curToken().isToken(word) {
+					p.updateErrLog("identifier")
+					return nil
+				}
+				p.pos++
+			} else {
+				ts := p.parseBracket()
+				if ts == nil {
+					p.updateErrLog("array subscript")
+					return nil
+				}
+				ss = append(ss, ts...)
+			}
+		}
+		if designated {
+			if !p.curToken().isToken(assign) {
+				p.updateErrLog("'='")
+				return nil
+			}
+			p.pos++
+		}
+
+		var ts []Statement
+		if p.curToken().isToken(lbrace) {
+			ts = p.parseInitializerList()
+		} else {
+			ts = p.parseExpression()
+		}
+		if ts == nil {
+			p.updateErrLog("expression")
+			return nil
+		}
+		ss = append(ss, ts...)
+
+		if p.curToken().isToken(comma) {
+			p.pos++
+		} else if !p.curToken().isToken(rbrace) {
+			p.updateErrLog("'}'")
+			return nil
+		}
+	}
+	p.pos++
+
+	return ss
+}
+
 // parseIdentifire
 func (p *Parser) parseIdentifire() []Statement {
+	defer p.trace("parseIdentifire")()
 	if p.curToken().tokenType != word {
-		p.updateErrLog(fmt.Sprintf("parseIdentifire:token[%s]", p.curToken().literal))
+		p.updateErrLog("identifier")
 		return nil
 	}
 	n := p.curToken().literal
+	pos := p.symbolPosition(p.pos)
 	p.pos++
 
-	return []Statement{&RefVar{Name: n}}
+	return []Statement{&RefVar{Name: n, Pos: pos, Local: p.isLocalName(n)}}
 }
 
 // parseParameter
 func (p *Parser) parseParameter() []*VariableDef {
+	defer p.trace("parseParameter")()
 	ss := []*VariableDef{}
 	// lparen
 	p.pos++
@@ -1637,7 +2336,7 @@ func (p *Parser) parseParameter() []*VariableDef {
 			p.pos++
 			// rparen
 			if !p.curToken().isToken(rparen) {
-				p.updateErrLog(fmt.Sprintf("parseParameter:token[%s]", p.curToken().literal))
+				p.updateErrLog("')'")
 				return nil
 			}
 			p.pos++
@@ -1653,19 +2352,28 @@ func (p *Parser) parseParameter() []*VariableDef {
 			p.pos = prePos
 			ts := p.parseVariableDefSub()
 			if ts == nil {
-				p.updateErrLog(fmt.Sprintf("parseParameter:token[%s]", p.curToken().literal))
+				p.updateErrLog("parameter")
 				return nil
 			}
 			if len(ts) != 1 {
-				p.updateErrLog(fmt.Sprintf("parseParameter:token[%s]", p.curToken().literal))
+				p.updateErrLog("parameter")
 				return nil
 			}
 			v, ok := ts[0].(*VariableDef)
 			if !ok {
-				p.updateErrLog(fmt.Sprintf("parseParameter:token[%s]", p.curToken().literal))
+				p.updateErrLog("parameter")
 				return nil
 			}
-			ss = append(ss, v)
+			if !p.checkParameter(prePos, p.pos) {
+				if !p.badParameterRun(prePos, p.pos) {
+					p.pos = prePos
+					p.updateErrLog("parameter declaration")
+				}
+				return nil
+			}
+			if !p.isUnnamedParameter(prePos, v) {
+				ss = append(ss, v)
+			}
 		}
 
 		switch p.curToken().tokenType {
@@ -1677,7 +2385,7 @@ func (p *Parser) parseParameter() []*VariableDef {
 			p.pos++
 			// next
 		default:
-			p.updateErrLog(fmt.Sprintf("parseParameter:token[%s]", p.curToken().literal))
+			p.updateErrLog("',' or ')'")
 			return nil
 		}
 	}
@@ -1688,11 +2396,43 @@ func (p *Parser) peekToken() *Token {
 	if p.curToken().tokenType == eof {
 		return p.curToken()
 	}
-	return p.tokens[p.pos+1]
+	return p.look(p.index() + 1)
 }
 
 func (p *Parser) curToken() *Token {
-	return p.tokens[p.pos]
+	return p.look(p.index())
+}
+
+// look
+// トークンを参照し, 参照した最も先の位置を記憶する. 終端より先は終端(eof)とする
+func (p *Parser) look(i int) *Token {
+	if i >= len(p.tokens) {
+		i = len(p.tokens) - 1
+	}
+	if i > p.reach {
+		p.reach = i
+	}
+	return p.tokens[i]
+}
+
+// curPosition
+func (p *Parser) curPosition() Position {
+	return p.position(p.spans[p.index()].start)
+}
+
+// index
+// 範囲外に出た現在位置をトークン列の先頭か終端(eof)に丸める
+func (p *Parser) index() int {
+	if p.halted() {
+		// 打ち切った後はソースの終端として扱う
+		return len(p.tokens) - 1
+	}
+	if p.pos < 0 {
+		return 0
+	} else if p.pos >= len(p.tokens) {
+		return len(p.tokens) - 1
+	}
+	return p.pos
 }
 
 func (p *Parser) progUntil(tkType int) {
@@ -1712,44 +2452,149 @@ func (p *Parser) progUntilPrev(tkType int) {
 }
 
 // skipStructureLike
-// 失敗した時は構文解析のパーサと同様 nil を返す
-func (p *Parser) skipStructureLike() []Statement {
-
-	for {
-		if p.curToken().isToken(eof) ||
-			p.curToken().isToken(semicolon) ||
-			p.curToken().isToken(lbrace) {
-			break
-		}
+// 構造体などの指定子と宣言子の並びを ; まで読み飛ばす
+// 失敗した時は構文解析のパーサと同様 nil を返す. 最初の宣言子の位置も返す. 宣言子がない場合は -1
+func (p *Parser) skipStructureLike() ([]Statement, int) {
+	defer p.trace("skipStructureLike")()
+	start := p.pos
+	declarator := -1
+	if p.curToken().isToken(keyTypedef) {
 		p.pos++
 	}
 
-	switch p.curToken().tokenType {
-	case eof:
-		return nil
-	case semicolon:
-		p.pos++
-	case lbrace:
-		if p.skipBrace() == nil {
-			return nil
-		}
-		if p.curToken().isToken(word) {
+	// 型指定子の種類. 基本型は組み合わせられるが構造体や typedef 名とは組み合わせられない
+	const (
+		noSpec = iota
+		basicSpec
+		namedSpec
+	)
+	spec := noSpec
+	named := false // 宣言子の識別子を読んだか
+	for !p.curToken().isToken(semicolon) {
+		t := p.curToken()
+		switch {
+		case t.isToken(keyStruct) || t.isToken(keyUnion) || t.isToken(keyEnum):
+			if named || spec != noSpec {
+				p.updateErrLog("';'")
+				return nil, -1
+			}
+			spec = namedSpec
 			p.pos++
+			if p.curToken().isToken(word) {
+				// タグ
+				p.pos++
+			}
+			if p.curToken().isToken(lbrace) && p.skipBrace() == nil {
+				p.updateErrLog("'}'")
+				return nil, -1
+			}
+			continue
+		case t.isToken(keyVoid) || t.isToken(word) && basicTypeWords[t.literal]:
+			if named || spec == namedSpec {
+				p.updateErrLog("';'")
+				return nil, -1
+			}
+			spec = basicSpec
+		case t.isToken(word) && qualifierWords[t.literal],
+			t.isToken(keyConst), t.isToken(keyVolatile), t.isToken(asterisk), t.isToken(caret):
+			if named {
+				p.updateErrLog("';'")
+				return nil, -1
+			}
+		case t.isToken(word):
+			if named {
+				p.updateErrLog("';'")
+				return nil, -1
+			}
+			if spec == noSpec {
+				// typedef 名
+				spec = namedSpec
+			} else {
+				named = true
+				if declarator < 0 {
+					declarator = p.pos
+				}
+			}
+		case t.isToken(lparen):
+			// 識別子の前の括弧は (*名前) の宣言子, 後の括弧は引数の並び
+			if declarator < 0 {
+				declarator = p.pos
+			}
+			if !p.skipGroup() {
+				p.updateErrLog("')'")
+				return nil, -1
+			}
+			named = true
+			continue
+		case t.isToken(lbracket):
+			if !p.skipGroup() {
+				p.updateErrLog("']'")
+				return nil, -1
+			}
+			continue
+		case t.isToken(keyAttribute) || t.isToken(keyAsm):
+			p.pos++
+			if p.curToken().isToken(lparen) && !p.skipGroup() {
+				p.updateErrLog("')'")
+				return nil, -1
+			}
+			continue
+		case t.isToken(assign) && named:
+			// 初期化子
+			p.pos++
+			for !p.curToken().isToken(comma) && !p.curToken().isToken(semicolon) {
+				if p.curToken().isToken(eof) {
+					p.updateErrLog("';'")
+					return nil, -1
+				}
+				if p.curToken().isToken(lparen) || p.curToken().isToken(lbracket) || p.curToken().isToken(lbrace) {
+					if !p.skipGroup() {
+						p.updateErrLog("initializer")
+						return nil, -1
+					}
+					continue
+				}
+				p.pos++
+			}
+			continue
+		case t.isToken(comma) && named:
+			named = false
+		default:
+			p.updateErrLog("';'")
+			return nil, -1
 		}
-		if !p.curToken().isToken(semicolon) {
-			return nil
-		}
-		// semicolon
 		p.pos++
-	default:
-		return nil
 	}
+	// semicolon
+	p.pos++
 
-	return []Statement{}
+	p.skip("skipStructureLike", start, p.pos)
+	return []Statement{}, declarator
+}
+
+// skipGroup
+// 現在位置の括弧から対応の取れた閉じ括弧の次まで読み飛ばす. 対応が取れない場合は false を返す
+func (p *Parser) skipGroup() bool {
+	depth := 0
+	for {
+		switch p.curToken().tokenType {
+		case lparen, lbracket, lbrace:
+			depth++
+		case rparen, rbracket, rbrace:
+			depth--
+		case eof:
+			return false
+		}
+		p.pos++
+		if depth <= 0 {
+			return depth == 0
+		}
+	}
 }
 
 // skipBrace
 func (p *Parser) skipBrace() []Statement {
+	defer p.trace("skipBrace")()
 	if !p.curToken().isToken(lbrace) {
 		return nil
 	}
@@ -1764,6 +2609,7 @@ func (p *Parser) skipBrace() []Statement {
 			if xs == nil {
 				return nil
 			}
+			continue
 		} else if p.curToken().isToken(eof) {
 			return nil
 		}
@@ -1771,7 +2617,64 @@ func (p *Parser) skipBrace() []Statement {
 	}
 }
 
+// synchronize
+// エラー回復のため次のトップレベルの ; もしくは対応の取れた } の次まで読み飛ばす
+func (p *Parser) synchronize() {
+	depth := 0
+	for !p.curToken().isToken(eof) {
+		t := p.curToken()
+		p.pos++
+		switch t.tokenType {
+		case lbrace:
+			depth++
+		case rbrace:
+			depth--
+			if depth <= 0 {
+				if p.curToken().isToken(semicolon) {
+					p.pos++
+				}
+				return
+			}
+		case semicolon:
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// onlyInvalid
+// 解析結果のうち InvalidStatement のみを残す
+func onlyInvalid(ss []Statement) []Statement {
+	ts := []Statement{}
+	for _, s := range ss {
+		if _, ok := s.(*InvalidStatement); ok {
+			ts = append(ts, s)
+		}
+	}
+	return ts
+}
+
+// refPosition
+// 参照として解析済みの識別子の位置を返す
+func refPosition(s Statement) Position {
+	if r, ok := s.(*RefVar); ok {
+		return r.Pos
+	}
+	return Position{}
+}
+
+// refLocal
+// 参照していた識別子がブロックスコープの宣言か仮引数を指すか
+func refLocal(s Statement) bool {
+	if r, ok := s.(*RefVar); ok {
+		return r.Local
+	}
+	return false
+}
+
 func (p *Parser) skipParen() {
+	defer p.trace("skipParen")()
 	for {
 		if p.curToken().tokenType == lparen {
 			p.pos++
@@ -1780,6 +2683,8 @@ func (p *Parser) skipParen() {
 			return
 		} else if p.curToken().tokenType == rparen {
 			return
+		} else if p.curToken().tokenType == eof {
+			return
 		} else {
 			p.pos++
 		}
@@ -1799,8 +2704,50 @@ func (p *Parser) fetchID() string {
 	return id
 }
 
-func (p *Parser) updateErrLog(msg string) {
-	delimiter := ";"
-	p.errLog += msg
-	p.errLog += delimiter
+// invalidContents
+// 解析できなかった宣言の内容として, 最も先まで解析できた箇所のエラーを位置とメッセージで表す
+func (p *Parser) invalidContents() string {
+	if p.farthest == nil {
+		return ""
+	}
+	return errorContents(p.farthest)
+}
+
+// errorContents
+// エラーを位置とメッセージで表す
+func errorContents(e *ParseError) string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message())
+}
+
+// updateErrLog
+// 解析の失敗を記録する. expected は期待した構文要素
+func (p *Parser) updateErrLog(expected string) {
+	// 最も先まで解析できた箇所の失敗をエラーとして残す
+	// 同じ位置の場合はより内側の規則、同じ深さの場合は後から試した規則を優先する
+	if e := p.farthest; e != nil {
+		if p.pos < e.index || p.pos == e.index && len(p.rules) < len(e.Rules) {
+			return
+		}
+	}
+	found := p.curToken().literal
+	if p.curToken().isToken(eof) {
+		found = ""
+	}
+	p.farthest = &ParseError{
+		Pos:      p.curPosition(),
+		Expected: expected,
+		Found:    found,
+		Rules:    append([]string{}, p.rules...),
+		index:    p.pos,
+	}
+}
+
+// trace
+// 文法規則のスタックに積み、取り除くための関数を返す
+func (p *Parser) trace(rule string) func() {
+	p.rules = append(p.rules, rule)
+	p.checkLimits()
+	return func() {
+		p.rules = p.rules[:len(p.rules)-1]
+	}
 }
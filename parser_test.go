@@ -208,7 +208,7 @@ void func(void)
 						Params: []*VariableDef{},
 						Statements: []Statement{
 							&VariableDef{Name: "c"},
-							&Assigne{Name: "c"},
+							&Assigne{Name: "c", Local: true},
 						},
 					},
 				},
@@ -601,12 +601,12 @@ int muruchi_piyomi(char *s) {
 						Statements: []Statement{
 							&VariableDef{Name: "purin"},
 							&VariableDef{Name: "p"},
-							&Assigne{Name: "purin"},
-							&Assigne{Name: "purin"},
-							&Assigne{Name: "p"},
-							&RefVar{Name: "purin"},
-							&Assigne{Name: "p"},
-							&RefVar{Name: "purin"},
+							&Assigne{Name: "purin", Local: true},
+							&Assigne{Name: "purin", Local: true},
+							&Assigne{Name: "p", Local: true},
+							&RefVar{Name: "purin", Local: true},
+							&Assigne{Name: "p", Local: true},
+							&RefVar{Name: "purin", Local: true},
 						}},
 				},
 			},
@@ -626,7 +626,7 @@ void hoge(void){
 					&FunctionDef{Name: "hoge",
 						Params: []*VariableDef{},
 						Statements: []Statement{
-							&RefVar{Name: "_p"},
+							&Assigne{Name: "_p"},
 							&RefVar{Name: "_p"},
 							&RefVar{Name: "_p"},
 							&RefVar{Name: "_c"},
@@ -772,7 +772,7 @@ void func(void)
 							&RefVar{Name: "p"},
 							&CallFunc{Name: "print", Args: []Statement{
 								&RefVar{Name: "b"},
-								&RefVar{Name: "p"},
+								&Assigne{Name: "p"},
 							},
 							},
 						},
@@ -799,7 +799,7 @@ void func(void)
 							&RefVar{Name: "x"},
 							&CallFunc{Name: "print", Args: []Statement{
 								&RefVar{Name: "b"},
-								&RefVar{Name: "p"},
+								&Assigne{Name: "p"},
 							},
 							},
 						},
@@ -957,7 +957,7 @@ void func(void)
 						Params: []*VariableDef{},
 						Statements: []Statement{
 							&RefVar{Name: "c"},
-							&RefVar{Name: "var1"},
+							&Assigne{Name: "var1"},
 						},
 					},
 				},
@@ -1003,7 +1003,7 @@ void func(void)
 								Name: "subst",
 								Args: []Statement{
 									&RefVar{Name: "macro"},
-									&RefVar{Name: "hideset"},
+									&RefVar{Name: "hideset", Local: true},
 								},
 							},
 							&CallFunc{
@@ -1074,7 +1074,7 @@ void func(void)
 					&FunctionDef{Name: "func",
 						Params: []*VariableDef{},
 						Statements: []Statement{
-							&Assigne{"hoge"},
+							&Assigne{Name: "hoge"},
 						},
 					},
 				},
@@ -1093,8 +1093,8 @@ void func(void)
 					&FunctionDef{Name: "func",
 						Params: []*VariableDef{},
 						Statements: []Statement{
-							&Assigne{"hoge"},
-							&RefVar{"a"},
+							&Assigne{Name: "hoge"},
+							&RefVar{Name: "a"},
 						},
 					},
 				},
@@ -1113,8 +1113,8 @@ void func(void)
 					&FunctionDef{Name: "func",
 						Params: []*VariableDef{},
 						Statements: []Statement{
-							&Assigne{"arrVar"},
-							&RefVar{"i"},
+							&Assigne{Name: "arrVar"},
+							&RefVar{Name: "i"},
 						},
 					},
 				},
@@ -1134,13 +1134,13 @@ void func(void)
 					&FunctionDef{Name: "func",
 						Params: []*VariableDef{},
 						Statements: []Statement{
-							&Assigne{"arrVar2"},
-							&RefVar{"i"},
-							&RefVar{"j"},
-							&Assigne{"arrVar3"},
-							&RefVar{"i"},
-							&RefVar{"j"},
-							&RefVar{"k"},
+							&Assigne{Name: "arrVar2"},
+							&RefVar{Name: "i"},
+							&RefVar{Name: "j"},
+							&Assigne{Name: "arrVar3"},
+							&RefVar{Name: "i"},
+							&RefVar{Name: "j"},
+							&RefVar{Name: "k"},
 						},
 					},
 				},
@@ -1159,8 +1159,8 @@ void func(void)
 					&FunctionDef{Name: "func",
 						Params: []*VariableDef{},
 						Statements: []Statement{
-							&Assigne{"_p"},
-							&RefVar{"_c"},
+							&Assigne{Name: "_p"},
+							&RefVar{Name: "_c"},
 						},
 					},
 				},
@@ -1837,7 +1837,7 @@ void func(int a)
 						Params: []*VariableDef{{Name: "a"}},
 						Statements: []Statement{
 							&VariableDef{Name: "aaa"},
-							&Assigne{Name: "a"},
+							&Assigne{Name: "a", Local: true},
 							&Assigne{Name: "b"},
 							&Assigne{Name: "c"},
 							&Assigne{Name: "d"},
@@ -1890,7 +1890,7 @@ void func()
 					&FunctionDef{Name: "func",
 						Params: []*VariableDef{},
 						Statements: []Statement{
-							&RefVar{"hoge"},
+							&RefVar{Name: "hoge"},
 						}},
 				},
 			},
@@ -1910,7 +1910,7 @@ static void pop_function(void *ignore) {
 							{Name: "ignore"},
 						},
 						Statements: []Statement{
-							&RefVar{"dumpstack"},
+							&RefVar{Name: "dumpstack"},
 							&CallFunc{
 								Name: "vec_pop",
 								Args: []Statement{
@@ -1922,6 +1922,76 @@ static void pop_function(void *ignore) {
 				},
 			},
 		},
+		{
+			"function def old style 1",
+			`
+int f(a, b)
+int a;
+char *b;
+{
+    return a + g;
+}
+`,
+			&Module{
+				[]Statement{
+					&FunctionDef{Name: "f",
+						Params: []*VariableDef{
+							{Name: "a"},
+							{Name: "b"},
+						},
+						Statements: []Statement{
+							&RefVar{Name: "a", Local: true},
+							&RefVar{Name: "g"},
+						},
+						OldStyle: true,
+					},
+				},
+			},
+		},
+		{
+			"function def old style 2",
+			`
+static int f(a, b, fp)
+register int a, b;
+int (*fp)();
+{
+}
+int x;
+`,
+			&Module{
+				[]Statement{
+					&FunctionDef{Name: "f",
+						Params: []*VariableDef{
+							{Name: "a"},
+							{Name: "b"},
+							{Name: "fp"},
+						},
+						Statements: []Statement{},
+						OldStyle:   true,
+					},
+					&VariableDef{Name: "x"},
+				},
+			},
+		},
+		{
+			"function def old style 3",
+			`
+int f(a)
+{
+}
+`,
+			&Module{
+				[]Statement{
+					&FunctionDef{Name: "f",
+						Params: []*VariableDef{
+							{Name: "a"},
+						},
+						Statements: []Statement{},
+						OldStyle:   true,
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range testTbl {
@@ -2227,6 +2297,103 @@ static void push_xmm(int reg) {
 				},
 			},
 		},
+		{
+			"test gcc statement expression 1",
+			`
+int f(int x)
+{
+    int y = ({ int _t = x; _t * 2; });
+    return ({ g_a; }) + y;
+}
+`,
+			&Module{
+				[]Statement{
+					&FunctionDef{Name: "f",
+						Params: []*VariableDef{
+							{Name: "x"},
+						},
+						Statements: []Statement{
+							&VariableDef{Name: "y"},
+							&VariableDef{Name: "_t"},
+							&RefVar{Name: "x", Local: true},
+							&RefVar{Name: "_t", Local: true},
+							&RefVar{Name: "g_a"},
+							&RefVar{Name: "y", Local: true},
+						},
+					},
+				},
+			},
+		},
+		{
+			"test gcc compound literal 1",
+			`
+void f(void)
+{
+    struct point p = (struct point){ .x = 1, .y = g_y };
+    q = (struct point){ 1, g_z };
+    draw((int [2]){ [0] = a, [idx] = { b } }, (struct point){0});
+}
+`,
+			&Module{
+				[]Statement{
+					&FunctionDef{Name: "f",
+						Params: []*VariableDef{},
+						Statements: []Statement{
+							&VariableDef{Name: "p"},
+							&RefVar{Name: "g_y"},
+							&Assigne{Name: "q"},
+							&RefVar{Name: "g_z"},
+							&CallFunc{
+								Name: "draw",
+								Args: []Statement{
+									&RefVar{Name: "a"},
+									&RefVar{Name: "idx"},
+									&RefVar{Name: "b"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"test gcc nested function 1",
+			`
+int f(int x)
+{
+    int inner(int a) { return a + g_h; }
+    return inner(x);
+}
+int g;
+`,
+			&Module{
+				[]Statement{
+					&FunctionDef{Name: "f",
+						Params: []*VariableDef{
+							{Name: "x"},
+						},
+						Statements: []Statement{
+							&FunctionDef{Name: "inner",
+								Params: []*VariableDef{
+									{Name: "a"},
+								},
+								Statements: []Statement{
+									&RefVar{Name: "a", Local: true},
+									&RefVar{Name: "g_h"},
+								},
+							},
+							&CallFunc{
+								Name: "inner",
+								Args: []Statement{
+									&RefVar{Name: "x", Local: true},
+								},
+							},
+						},
+					},
+					&VariableDef{Name: "g"},
+				},
+			},
+		},
 	}
 
 	for _, tt := range testTbl {
@@ -3091,14 +3258,14 @@ void func()
 					&FunctionDef{Name: "func",
 						Params: []*VariableDef{},
 						Statements: []Statement{
-							&RefVar{Name: "a"},
-							&RefVar{Name: "b"},
-							&RefVar{Name: "c"},
-							&RefVar{Name: "d"},
-							&RefVar{Name: "e"},
-							&RefVar{Name: "f"},
-							&RefVar{Name: "g"},
-							&RefVar{Name: "h"},
+							&Assigne{Name: "a"},
+							&Assigne{Name: "b"},
+							&Assigne{Name: "c"},
+							&Assigne{Name: "d"},
+							&Assigne{Name: "e"},
+							&Assigne{Name: "f"},
+							&Assigne{Name: "g"},
+							&Assigne{Name: "h"},
 						},
 					},
 				},
@@ -3258,9 +3425,9 @@ void func()
 					&FunctionDef{Name: "func",
 						Params: []*VariableDef{},
 						Statements: []Statement{
-							&RefVar{Name: "a"},
-							&RefVar{Name: "b"},
-							&RefVar{Name: "c"},
+							&Assigne{Name: "a"},
+							&Assigne{Name: "b"},
+							&Assigne{Name: "c"},
 						},
 					},
 				},
@@ -3452,7 +3619,7 @@ void func(void)
 						Statements: []Statement{
 							&Assigne{Name: "i"},
 							&RefVar{Name: "i"},
-							&RefVar{Name: "i"},
+							&Assigne{Name: "i"},
 						},
 					},
 				},
@@ -3493,7 +3660,7 @@ void func(void)
 						Statements: []Statement{
 							&Assigne{Name: "i"},
 							&RefVar{Name: "i"},
-							&RefVar{Name: "i"},
+							&Assigne{Name: "i"},
 							&Assigne{Name: "arrVar"},
 							&RefVar{Name: "i"},
 							&RefVar{Name: "i"},
@@ -3520,7 +3687,7 @@ void func(void)
 							&Assigne{Name: "i"},
 							&RefVar{Name: "i"},
 							&RefVar{Name: "len"},
-							&RefVar{Name: "i"},
+							&Assigne{Name: "i"},
 							&CallFunc{Name: "buf_write", Args: []Statement{
 								&RefVar{Name: "b"},
 								&RefVar{Name: "s"},
@@ -3552,7 +3719,7 @@ void func(void)
 							},
 							},
 							&RefVar{Name: "i"},
-							&RefVar{Name: "i"},
+							&Assigne{Name: "i"},
 						},
 					},
 				},
@@ -3581,11 +3748,11 @@ void func(void)
 								},
 							},
 							&RefVar{Name: "i"},
-							&RefVar{Name: "i"},
+							&Assigne{Name: "i"},
 							&VariableDef{Name: "j"},
 							&RefVar{Name: "j"},
 							&RefVar{Name: "i"},
-							&RefVar{Name: "j"},
+							&Assigne{Name: "j"},
 							&Assigne{Name: "a"},
 							&RefVar{Name: "j"},
 						},
@@ -3616,11 +3783,11 @@ void func(void)
 								},
 							},
 							&RefVar{Name: "i"},
-							&RefVar{Name: "i"},
+							&Assigne{Name: "i"},
 							&VariableDef{Name: "j"},
 							&RefVar{Name: "j"},
 							&RefVar{Name: "i"},
-							&RefVar{Name: "j"},
+							&Assigne{Name: "j"},
 						},
 					},
 				},
@@ -3675,6 +3842,46 @@ void func(void)
 								},
 							},
 						},
+						Labels: []*Label{
+							{Name: "err", Order: 1},
+						},
+						Gotos: []*Goto{
+							{Label: "err", Order: 0},
+						},
+					},
+				},
+			},
+		},
+		{
+			"goto 2",
+			`
+int func(int a)
+{
+retry:
+    if (a)
+        goto retry;
+    goto out;
+out:
+    return 0;
+}
+`,
+			&Module{
+				[]Statement{
+					&FunctionDef{Name: "func",
+						Params: []*VariableDef{
+							{Name: "a"},
+						},
+						Statements: []Statement{
+							&RefVar{Name: "a", Local: true},
+						},
+						Labels: []*Label{
+							{Name: "retry", Order: 0},
+							{Name: "out", Order: 3},
+						},
+						Gotos: []*Goto{
+							{Label: "retry", Order: 1},
+							{Label: "out", Order: 2},
+						},
 					},
 				},
 			},
@@ -3750,7 +3957,7 @@ void func(void)
 						Statements: []Statement{
 							&Assigne{Name: "i"},
 							&RefVar{Name: "i"},
-							&RefVar{Name: "i"},
+							&Assigne{Name: "i"},
 						},
 					},
 				},
@@ -4061,7 +4268,7 @@ int hoge[] = {100, 200};
 `,
 			&Module{
 				[]Statement{
-					&VariableDef{"hoge"},
+					&VariableDef{Name: "hoge"},
 				},
 			},
 		},
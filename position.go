@@ -0,0 +1,71 @@
+package symc
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Position ソース上の位置
+type Position struct {
+	File   string // ファイル名. 不明な場合は空
+	Offset int    // 先頭からのバイト位置
+	Line   int    // 1 始まり
+	Column int    // 1 始まり. バイト単位
+}
+
+func (v Position) String() string {
+	if v.File != "" {
+		return fmt.Sprintf("%s:%d:%d", v.File, v.Line, v.Column)
+	}
+	return fmt.Sprintf("%d:%d", v.Line, v.Column)
+}
+
+// IsValid
+func (v Position) IsValid() bool {
+	return v.Line > 0
+}
+
+// 行の開始位置の一覧
+type lineTable []int
+
+func newLineTable(src string) lineTable {
+	t := lineTable{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			t = append(t, i+1)
+		}
+	}
+	return t
+}
+
+// position
+// バイト位置から行と列を求める
+func (t lineTable) position(offset int) Position {
+	i := sort.Search(len(t), func(i int) bool { return t[i] > offset }) - 1
+	if i < 0 {
+		i = 0
+	}
+	return Position{Offset: offset, Line: i + 1, Column: offset - t[i] + 1}
+}
+
+// position
+// バイト位置からファイル名付きの位置を求める
+// 行マーカーがある場合は元ファイルの名前と行番号に読み替える. Offset は入力上の位置のまま
+func (p *Parser) position(offset int) Position {
+	pos := p.lines.position(offset)
+	pos.File = p.opts.FileName
+	if m, ok := p.marker(offset); ok {
+		pos.File = m.file
+		pos.Line = m.line + pos.Line - p.lines.position(m.offset).Line
+	}
+	return pos
+}
+
+// symbolPosition
+// トークン位置のシンボルの位置を求める. 位置情報を付与しない場合はゼロ値
+func (p *Parser) symbolPosition(i int) Position {
+	if !p.opts.Positions || i < 0 || i >= len(p.spans) {
+		return Position{}
+	}
+	return p.position(p.spans[i].start)
+}
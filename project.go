@@ -0,0 +1,290 @@
+package symc
+
+// project モジュール
+// コンパイルデータベース (compile_commands.json) の各翻訳単位を前処理して解析する
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// CompileCommand コンパイルデータベースの項目ひとつ
+type CompileCommand struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Command   string   `json:"command,omitempty"`
+	Arguments []string `json:"arguments,omitempty"`
+	Output    string   `json:"output,omitempty"`
+}
+
+// ReadCompileCommands
+// コンパイルデータベースを読み込む
+func ReadCompileCommands(r io.Reader) ([]*CompileCommand, error) {
+	cmds := []*CompileCommand{}
+	if err := json.NewDecoder(r).Decode(&cmds); err != nil {
+		return nil, fmt.Errorf("symc: bad compilation database: %v", err)
+	}
+	for i, c := range cmds {
+		if c.File == "" || (c.Command == "" && len(c.Arguments) == 0) {
+			return nil, fmt.Errorf("symc: bad compilation database: entry %d has no file or command", i)
+		}
+	}
+	return cmds, nil
+}
+
+// LoadCompileCommands
+// ファイルからコンパイルデータベースを読み込む
+func LoadCompileCommands(path string) ([]*CompileCommand, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCompileCommands(f)
+}
+
+// Path
+// 翻訳単位のファイルのパス. 相対パスは Directory を基準とする
+func (c *CompileCommand) Path() string {
+	if filepath.IsAbs(c.File) {
+		return filepath.Clean(c.File)
+	}
+	return filepath.Join(c.Directory, c.File)
+}
+
+// PreprocessArgs
+// コンパイルのコマンドを, 前処理の結果を標準出力に書き出すコマンドに書き換える
+// -c と出力ファイル, 依存関係の出力の指定を除き -E を加える
+func (c *CompileCommand) PreprocessArgs() ([]string, error) {
+	args := c.Arguments
+	if len(args) == 0 {
+		var err error
+		if args, err = splitCommand(c.Command); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("symc: empty command for %s", c.File)
+	}
+
+	pp := []string{args[0], "-E"}
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "-c" || a == "-E" || a == "-M" || a == "-MM" || a == "-MD" || a == "-MMD" || a == "-MG" || a == "-MP":
+		case a == "-o" || a == "-MF" || a == "-MT" || a == "-MQ":
+			// 値を取るオプション
+			i++
+		case strings.HasPrefix(a, "-o") || strings.HasPrefix(a, "-MF") || strings.HasPrefix(a, "-MT") || strings.HasPrefix(a, "-MQ"):
+		default:
+			pp = append(pp, a)
+		}
+	}
+	return pp, nil
+}
+
+// splitCommand
+// シェルのコマンド行を引数に分割する. 引用符とバックスラッシュによるエスケープを解釈する
+func splitCommand(s string) ([]string, error) {
+	args := []string{}
+	var cur strings.Builder
+	inArg := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		case ch == '\\':
+			inArg = true
+			if i+1 < len(s) {
+				i++
+				cur.WriteByte(s[i])
+			}
+		case ch == '\'':
+			inArg = true
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("symc: unterminated quote in command %q", s)
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case ch == '"':
+			inArg = true
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				// 二重引用符の中では \ は一部の文字のみをエスケープする
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0 {
+					i++
+				}
+				cur.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("symc: unterminated quote in command %q", s)
+			}
+		default:
+			inArg = true
+			cur.WriteByte(ch)
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// Runner コマンドを dir で実行し, 標準出力を返す
+type Runner func(ctx context.Context, dir string, args []string) ([]byte, error)
+
+// ExecRunner
+// コマンドをプロセスとして実行する. 失敗した場合は標準エラー出力をエラーに含める
+func ExecRunner(ctx context.Context, dir string, args []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %v\n%s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("%s: %v", args[0], err)
+	}
+	return out, nil
+}
+
+// Preprocess
+// 項目のコンパイラで翻訳単位を前処理し, 結果を返す. run が nil の場合は ExecRunner を用いる
+func Preprocess(ctx context.Context, c *CompileCommand, run Runner) (string, error) {
+	if run == nil {
+		run = ExecRunner
+	}
+	args, err := c.PreprocessArgs()
+	if err != nil {
+		return "", err
+	}
+	out, err := run(ctx, c.Directory, args)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// UnitError 翻訳単位ひとつの前処理もしくは解析の失敗
+type UnitError struct {
+	File string
+	Err  error
+}
+
+func (e *UnitError) Error() string {
+	return e.File + ": " + e.Err.Error()
+}
+
+func (e *UnitError) Unwrap() error {
+	return e.Err
+}
+
+// Program 複数の翻訳単位の解析結果
+type Program struct {
+	Files    []string    // 翻訳単位のファイル名
+	Modules  []*Module   // Files と同じ順序
+	Analyses []*Analysis // Files と同じ順序. メトリクスや網羅状況, 解析したソースを取り出せる
+}
+
+// ProjectOptions プロジェクトの解析オプション
+type ProjectOptions struct {
+	Parse   ParseOptions // FileName は翻訳単位ごとに設定する
+	Run     Runner       // nil の場合は ExecRunner
+	Workers int          // 同時に処理する翻訳単位の数. 0 の場合は CPU 数
+}
+
+// AnalyzeProject
+// コンパイルデータベースの各翻訳単位を前処理して解析する
+// 失敗した翻訳単位は打ち切らずに *UnitError として返す. 前処理できた翻訳単位は解析エラーがあっても結果に含める
+// ctx が終了した場合は ParseFiles と同じく, 打ち切った翻訳単位と未処理の翻訳単位を除いた結果と ctx のエラーひとつを返す
+func AnalyzeProject(ctx context.Context, cmds []*CompileCommand, opts ProjectOptions) (*Program, []error) {
+	analyses := make([]*Analysis, len(cmds))
+	errs := make([]error, len(cmds))
+	runPool(len(cmds), opts.Workers, func(i int) {
+		c := cmds[i]
+		if ctx.Err() != nil {
+			return
+		}
+		src, err := Preprocess(ctx, c, opts.Run)
+		if err != nil && ctx.Err() != nil {
+			// 前処理を打ち切った翻訳単位は ctx のエラーにまとめる
+			return
+		}
+		if err != nil {
+			errs[i] = &UnitError{File: c.Path(), Err: err}
+			return
+		}
+		po := opts.Parse
+		po.FileName = c.Path()
+		analyses[i], err = Analyze(ctx, src, po)
+		if err != nil && err == ctx.Err() {
+			// 途中で打ち切った結果は含めない
+			analyses[i] = nil
+			return
+		}
+		if err != nil {
+			errs[i] = &UnitError{File: c.Path(), Err: err}
+		}
+	})
+
+	prog := &Program{Files: []string{}, Modules: []*Module{}, Analyses: []*Analysis{}}
+	failures := []error{}
+	for i, c := range cmds {
+		if a := analyses[i]; a != nil {
+			prog.Files = append(prog.Files, c.Path())
+			prog.Modules = append(prog.Modules, a.Module)
+			prog.Analyses = append(prog.Analyses, a)
+		}
+		if errs[i] != nil {
+			failures = append(failures, errs[i])
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		failures = append(failures, err)
+	}
+	return prog, failures
+}
+
+// runPool
+// 0 から n-1 までの i について f を最大 workers 個並行に呼び出す. workers が 0 以下の場合は CPU 数
+func runPool(n, workers int, f func(i int)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > n {
+		workers = n
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
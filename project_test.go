@@ -0,0 +1,164 @@
+package symc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCompileCommands(t *testing.T) {
+	src := `[
+  {"directory": "/src", "file": "a.c", "command": "cc -c a.c"},
+  {"directory": "/src", "file": "/src/b.c", "arguments": ["cc", "-c", "b.c"], "output": "b.o"}
+]`
+	cmds, err := ReadCompileCommands(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expect := []*CompileCommand{
+		{Directory: "/src", File: "a.c", Command: "cc -c a.c"},
+		{Directory: "/src", File: "/src/b.c", Arguments: []string{"cc", "-c", "b.c"}, Output: "b.o"},
+	}
+	if !reflect.DeepEqual(cmds, expect) {
+		t.Errorf("expect %v, actual %v", expect, cmds)
+	}
+	if cmds[0].Path() != "/src/a.c" || cmds[1].Path() != "/src/b.c" {
+		t.Errorf("unexpected paths %s, %s", cmds[0].Path(), cmds[1].Path())
+	}
+
+	for _, bad := range []string{`{}`, `[{"directory": "/src", "file": "a.c"}]`} {
+		if _, err := ReadCompileCommands(strings.NewReader(bad)); err == nil {
+			t.Errorf("expect error for %s", bad)
+		}
+	}
+}
+
+func TestPreprocessArgs(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		cmd     *CompileCommand
+		expect  []string
+		err     bool
+	}{
+		{
+			"arguments",
+			&CompileCommand{Arguments: []string{"gcc", "-c", "-o", "a.o", "-Iinc", "-DX=1", "a.c"}},
+			[]string{"gcc", "-E", "-Iinc", "-DX=1", "a.c"},
+			false,
+		},
+		{
+			"command with quotes",
+			&CompileCommand{Command: `cc -DNAME="\"x y\"" '-DZ=a b' -c a\ b.c -oa.o`},
+			[]string{"cc", "-E", `-DNAME="x y"`, "-DZ=a b", "a b.c"},
+			false,
+		},
+		{
+			"dependency output",
+			&CompileCommand{Command: "cc -MD -MF a.d -MT a.o -MMD -c a.c"},
+			[]string{"cc", "-E", "a.c"},
+			false,
+		},
+		{
+			"unterminated quote",
+			&CompileCommand{Command: "cc 'a.c"},
+			nil,
+			true,
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		actual, err := tt.cmd.PreprocessArgs()
+		if (err != nil) != tt.err {
+			t.Errorf("unexpected error %v", err)
+		}
+		if !reflect.DeepEqual(actual, tt.expect) {
+			t.Errorf("expect %q, actual %q", tt.expect, actual)
+		}
+	}
+}
+
+func TestAnalyzeProject(t *testing.T) {
+	sources := map[string]string{
+		"a.c": "# 1 \"a.c\"\nint a;\nint f(void) { return a; }\n",
+		"b.c": "# 1 \"b.c\"\nint b;\n}\n",
+		"d.c": "# 1 \"d.c\"\nvoid g(void) { f(); }\n",
+	}
+	run := func(ctx context.Context, dir string, args []string) ([]byte, error) {
+		if dir != "/src" || args[1] != "-E" {
+			t.Errorf("unexpected command %s %q", dir, args)
+		}
+		src, ok := sources[args[len(args)-1]]
+		if !ok {
+			return nil, errors.New("no such file")
+		}
+		return []byte(src), nil
+	}
+	cmds := []*CompileCommand{
+		{Directory: "/src", File: "a.c", Command: "cc -c a.c"},
+		{Directory: "/src", File: "b.c", Command: "cc -c b.c"},
+		{Directory: "/src", File: "c.c", Command: "cc -c c.c"},
+		{Directory: "/src", File: "d.c", Command: "cc -c d.c"},
+	}
+
+	prog, errs := AnalyzeProject(context.Background(), cmds, ProjectOptions{Run: run, Workers: 2})
+
+	if expect := []string{"/src/a.c", "/src/b.c", "/src/d.c"}; !reflect.DeepEqual(prog.Files, expect) {
+		t.Errorf("expect %v, actual %v", expect, prog.Files)
+	}
+	if len(prog.Modules) != 3 || prog.Modules[2].Statements[0].(*FunctionDef).Name != "g" {
+		t.Errorf("unexpected modules %v", prog.Modules)
+	}
+	if len(prog.Analyses) != 3 || prog.Analyses[2].Module != prog.Modules[2] || prog.Analyses[2].Source() != sources["d.c"] {
+		t.Errorf("unexpected analyses %v", prog.Analyses)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("expect 2 errors, actual %v", errs)
+	}
+	if e, ok := errs[0].(*UnitError); !ok || e.File != "/src/b.c" {
+		t.Errorf("unexpected error %v", errs[0])
+	}
+	if e, ok := errs[1].(*UnitError); !ok || e.File != "/src/c.c" || e.Error() != "/src/c.c: no such file" {
+		t.Errorf("unexpected error %v", errs[1])
+	}
+}
+
+func TestAnalyzeProjectCanceled(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		fail    bool // 前処理が ctx のエラーで失敗するか
+	}{
+		{"canceled during preprocessing", true},
+		{"canceled before parsing", false},
+	}
+	cmds := []*CompileCommand{
+		{Directory: "/src", File: "a.c", Command: "cc -c a.c"},
+		{Directory: "/src", File: "b.c", Command: "cc -c b.c"},
+		{Directory: "/src", File: "c.c", Command: "cc -c c.c"},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		ctx, cancel := context.WithCancel(context.Background())
+		// b.c の前処理中に終了する
+		run := func(ctx context.Context, dir string, args []string) ([]byte, error) {
+			file := args[len(args)-1]
+			if file == "b.c" {
+				cancel()
+				if tt.fail {
+					return nil, ctx.Err()
+				}
+			}
+			return []byte("int " + file[:1] + ";\n"), nil
+		}
+		prog, errs := AnalyzeProject(ctx, cmds, ProjectOptions{Run: run, Workers: 1})
+		if !reflect.DeepEqual(prog.Files, []string{"/src/a.c"}) || len(prog.Modules) != 1 || len(prog.Analyses) != 1 {
+			t.Errorf("unexpected files %v", prog.Files)
+		}
+		if !reflect.DeepEqual(errs, []error{context.Canceled}) {
+			t.Errorf("unexpected errors %v", errs)
+		}
+	}
+}
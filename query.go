@@ -0,0 +1,97 @@
+package symc
+
+// query モジュール
+// 平坦化した記録から定義, 参照, 呼び出し関係を検索する
+
+// Query 複数のモジュールをまとめた検索対象
+type Query struct {
+	records []*Record
+}
+
+// NewQuery
+// モジュールを平坦化して検索対象を作る
+func NewQuery(ms ...*Module) *Query {
+	q := &Query{records: []*Record{}}
+	for _, m := range ms {
+		q.records = append(q.records, Flatten(m)...)
+	}
+	return q
+}
+
+// filter
+func (q *Query) filter(match func(r *Record) bool) []*Record {
+	rs := []*Record{}
+	for _, r := range q.records {
+		if match(r) {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// global
+// 記録の名前がファイルスコープのシンボルを指すか
+// ブロックスコープの宣言か仮引数を指す参照と代入は除く
+func (q *Query) global(r *Record) bool {
+	return !r.Local
+}
+
+// Defs
+// ファイルスコープの定義と宣言を返す
+func (q *Query) Defs() []*Record {
+	return q.filter(func(r *Record) bool {
+		return r.Function == "" && (r.Kind == KindDefinition || r.Kind == KindDeclaration || r.Kind == KindPrototype)
+	})
+}
+
+// Refs
+// ファイルスコープのシンボル name の参照, 代入, 呼び出しを返す
+func (q *Query) Refs(name string) []*Record {
+	return q.filter(func(r *Record) bool {
+		return r.Symbol == name && (r.Kind == KindRead || r.Kind == KindWrite || r.Kind == KindCall) && q.global(r)
+	})
+}
+
+// Readers
+// グローバル変数 name を参照する箇所を返す
+func (q *Query) Readers(name string) []*Record {
+	return q.filter(func(r *Record) bool {
+		return r.Symbol == name && r.Kind == KindRead && q.global(r)
+	})
+}
+
+// Writers
+// グローバル変数 name に代入する箇所を返す. ++ と -- による更新を含む
+func (q *Query) Writers(name string) []*Record {
+	return q.filter(func(r *Record) bool {
+		return r.Symbol == name && r.Kind == KindWrite && q.global(r)
+	})
+}
+
+// Callers
+// 関数 name を呼び出す箇所を返す. 呼び出し元は Function
+func (q *Query) Callers(name string) []*Record {
+	return q.filter(func(r *Record) bool {
+		return r.Symbol == name && r.Kind == KindCall
+	})
+}
+
+// Callees
+// 関数 name から depth 段までに呼び出す箇所を返す. depth が 0 以下の場合は制限しない
+func (q *Query) Callees(name string, depth int) []*Record {
+	reached := map[string]bool{name: true}
+	frontier := map[string]bool{name: true}
+	for d := 1; len(frontier) > 0 && (depth <= 0 || d < depth); d++ {
+		next := map[string]bool{}
+		for _, r := range q.records {
+			if r.Kind == KindCall && frontier[r.Function] && !reached[r.Symbol] {
+				reached[r.Symbol] = true
+				next[r.Symbol] = true
+			}
+		}
+		frontier = next
+	}
+	return q.filter(func(r *Record) bool {
+		return r.Kind == KindCall && reached[r.Function]
+	})
+}
@@ -0,0 +1,201 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+const querySrc = `int g_state;
+extern int g_count;
+void reset(void);
+void set(int v) { g_state = v; }
+int get(void) { return g_state; }
+void local(void) { int g_state; g_state = 1; }
+void param(int g_state) { g_state = 2; }
+void tick(void) { set(get() + 1); reset(); }
+void run(void) { tick(); g_count = g_state; }
+`
+
+func TestQuery(t *testing.T) {
+	q := NewQuery(ParseModule(querySrc))
+
+	testTbl := []struct {
+		comment string
+		actual  []*Record
+		expect  []*Record
+	}{
+		{
+			"defs",
+			q.Defs(),
+			[]*Record{
+				{Symbol: "g_state", Kind: KindDefinition},
+				{Symbol: "g_count", Kind: KindDeclaration},
+				{Symbol: "reset", Kind: KindPrototype},
+				{Symbol: "set", Kind: KindDefinition},
+				{Symbol: "get", Kind: KindDefinition},
+				{Symbol: "local", Kind: KindDefinition},
+				{Symbol: "param", Kind: KindDefinition},
+				{Symbol: "tick", Kind: KindDefinition},
+				{Symbol: "run", Kind: KindDefinition},
+			},
+		},
+		{
+			"refs skip locals and parameters",
+			q.Refs("g_state"),
+			[]*Record{
+				{Function: "set", Symbol: "g_state", Kind: KindWrite},
+				{Function: "get", Symbol: "g_state", Kind: KindRead},
+				{Function: "run", Symbol: "g_state", Kind: KindRead},
+			},
+		},
+		{
+			"writers",
+			q.Writers("g_state"),
+			[]*Record{
+				{Function: "set", Symbol: "g_state", Kind: KindWrite},
+			},
+		},
+		{
+			"readers",
+			q.Readers("g_state"),
+			[]*Record{
+				{Function: "get", Symbol: "g_state", Kind: KindRead},
+				{Function: "run", Symbol: "g_state", Kind: KindRead},
+			},
+		},
+		{
+			"callers",
+			q.Callers("tick"),
+			[]*Record{
+				{Function: "run", Symbol: "tick", Kind: KindCall},
+			},
+		},
+		{
+			"direct callees",
+			q.Callees("run", 1),
+			[]*Record{
+				{Function: "run", Symbol: "tick", Kind: KindCall},
+			},
+		},
+		{
+			"callees to depth 2",
+			q.Callees("run", 2),
+			[]*Record{
+				{Function: "tick", Symbol: "set", Kind: KindCall},
+				{Function: "tick", Symbol: "get", Kind: KindCall},
+				{Function: "tick", Symbol: "reset", Kind: KindCall},
+				{Function: "run", Symbol: "tick", Kind: KindCall},
+			},
+		},
+		{
+			"unknown name",
+			q.Callers("none"),
+			[]*Record{},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		if !reflect.DeepEqual(tt.actual, tt.expect) {
+			t.Errorf("expect %v, actual %v", tt.expect, tt.actual)
+		}
+	}
+}
+
+func TestQueryIncrement(t *testing.T) {
+	q := NewQuery(ParseModule("int g_state;\nvoid step(void) { g_state++; --g_state; g_state += 2; g_state = 1; }\n"))
+
+	testTbl := []struct {
+		comment string
+		actual  []*Record
+		expect  []*Record
+	}{
+		{
+			"increments, decrements and assignments are writes",
+			q.Writers("g_state"),
+			[]*Record{
+				{Function: "step", Symbol: "g_state", Kind: KindWrite},
+				{Function: "step", Symbol: "g_state", Kind: KindWrite},
+				{Function: "step", Symbol: "g_state", Kind: KindWrite},
+				{Function: "step", Symbol: "g_state", Kind: KindWrite},
+			},
+		},
+		{
+			"no reads",
+			q.Readers("g_state"),
+			[]*Record{},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		if !reflect.DeepEqual(tt.actual, tt.expect) {
+			t.Errorf("expect %v, actual %v", tt.expect, tt.actual)
+		}
+	}
+}
+
+func TestQueryBlockScope(t *testing.T) {
+	q := NewQuery(ParseModule("int g;\nvoid f(void) { { int g = 1; g = 5; } g = 7; if (g) { int g; g++; } }\n"))
+
+	testTbl := []struct {
+		comment string
+		actual  []*Record
+		expect  []*Record
+	}{
+		{
+			"block local does not hide the global after the block",
+			q.Writers("g"),
+			[]*Record{
+				{Function: "f", Symbol: "g", Kind: KindWrite},
+			},
+		},
+		{
+			"refs",
+			q.Refs("g"),
+			[]*Record{
+				{Function: "f", Symbol: "g", Kind: KindWrite},
+				{Function: "f", Symbol: "g", Kind: KindRead},
+			},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		if !reflect.DeepEqual(tt.actual, tt.expect) {
+			t.Errorf("expect %v, actual %v", tt.expect, tt.actual)
+		}
+	}
+}
+
+func TestQueryStaticFunctions(t *testing.T) {
+	a, _ := ParseWithOptions("int g;\nstatic void init(void) { int g; g = 1; }\n", ParseOptions{FileName: "a.c", Positions: true})
+	b, _ := ParseWithOptions("extern int g;\nstatic void init(void) { g = 2; }\n", ParseOptions{FileName: "b.c", Positions: true})
+	q := NewQuery(a, b)
+
+	testTbl := []struct {
+		comment string
+		actual  []*Record
+		expect  []*Record
+	}{
+		{
+			"local of the same function name in another file",
+			q.Writers("g"),
+			[]*Record{
+				{File: "b.c", Function: "init", Symbol: "g", Kind: KindWrite, Pos: Position{File: "b.c", Offset: 39, Line: 2, Column: 26}},
+			},
+		},
+		{
+			"reads in neither file",
+			q.Readers("g"),
+			[]*Record{},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		if !reflect.DeepEqual(tt.actual, tt.expect) {
+			t.Errorf("expect %v, actual %v", tt.expect, tt.actual)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package symc
+
+// records モジュール
+// Module を事実ひとつにつき一行の表形式に平坦化する
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// RecordKind 記録の種類
+type RecordKind string
+
+const (
+	KindDefinition  RecordKind = "definition"  // 変数・関数・仮引数の定義
+	KindDeclaration RecordKind = "declaration" // extern 宣言
+	KindPrototype   RecordKind = "prototype"   // プロトタイプ宣言
+	KindRead        RecordKind = "read"        // 変数の参照
+	KindWrite       RecordKind = "write"       // 変数への代入
+	KindCall        RecordKind = "call"        // 関数呼び出し
+)
+
+// Record シンボルに関する事実ひとつ
+type Record struct {
+	File     string // ファイル名. 不明な場合は空
+	Function string // 記録を含む関数名. ファイルスコープの場合は空
+	Symbol   string
+	Kind     RecordKind
+	Pos      Position // ParseOptions.Positions 指定時のみ
+	Local    bool     // 参照と代入がブロックスコープの宣言か仮引数を指す
+}
+
+// RecordColumns CSV と TSV の列名. 列の順序は変えない
+var RecordColumns = []string{"file", "function", "symbol", "kind", "line", "column"}
+
+// Fields
+// RecordColumns の順に並べた値を返す. 位置が不明な場合の行と列は空
+func (r *Record) Fields() []string {
+	line, column := "", ""
+	if r.Pos.IsValid() {
+		line = strconv.Itoa(r.Pos.Line)
+		column = strconv.Itoa(r.Pos.Column)
+	}
+	return []string{r.File, r.Function, r.Symbol, string(r.Kind), line, column}
+}
+
+// Flatten
+// 関数の本体と呼び出しの引数を含め, モジュールの全ての文を出現順に記録にする
+// 記録の File は位置のファイル名のため, ParseOptions.Positions を指定しない場合は空になる
+func Flatten(m *Module) []*Record {
+	return FlattenFile(m, "")
+}
+
+// FlattenFile
+// Flatten と同じく記録にする. 位置にファイル名がない記録の File は file とする
+func FlattenFile(m *Module, file string) []*Record {
+	f := &flattener{file: file, rs: []*Record{}}
+	f.statements(m.Statements, "")
+	return f.rs
+}
+
+// flattener
+type flattener struct {
+	file string // 位置にファイル名がない場合のファイル名
+	rs   []*Record
+}
+
+// statements
+func (f *flattener) statements(ss []Statement, fn string) {
+	for _, s := range ss {
+		switch v := s.(type) {
+		case *VariableDef:
+			f.add(fn, v.Name, KindDefinition, v.Pos)
+		case *Typedef:
+			f.add(fn, v.Name, KindDefinition, v.Pos)
+		case *VariableDecl:
+			f.add(fn, v.Name, KindDeclaration, v.Pos)
+		case *PrototypeDecl:
+			f.add(fn, v.Name, KindPrototype, v.Pos)
+		case *RefVar:
+			f.add(fn, v.Name, KindRead, v.Pos).Local = v.Local
+		case *Assigne:
+			f.add(fn, v.Name, KindWrite, v.Pos).Local = v.Local
+		case *CallFunc:
+			f.add(fn, v.Name, KindCall, v.Pos)
+			f.statements(v.Args, fn)
+		case *FunctionDef:
+			f.add(fn, v.Name, KindDefinition, v.Pos)
+			for _, p := range v.Params {
+				f.add(v.Name, p.Name, KindDefinition, p.Pos)
+			}
+			f.statements(v.Statements, v.Name)
+		}
+	}
+}
+
+// add
+func (f *flattener) add(fn, name string, kind RecordKind, pos Position) *Record {
+	r := &Record{File: pos.File, Function: fn, Symbol: name, Kind: kind, Pos: pos}
+	if r.File == "" {
+		r.File = f.file
+	}
+	f.rs = append(f.rs, r)
+	return r
+}
+
+// WriteCSV
+// 見出し行に続けて記録を CSV で書き出す
+func WriteCSV(w io.Writer, rs []*Record) error {
+	return writeRecords(w, rs, ',')
+}
+
+// WriteTSV
+// 見出し行に続けて記録をタブ区切りで書き出す
+// 区切り文字や引用符, 改行を含む値は CSV と同じく引用符で囲む
+func WriteTSV(w io.Writer, rs []*Record) error {
+	return writeRecords(w, rs, '\t')
+}
+
+// writeRecords
+func writeRecords(w io.Writer, rs []*Record, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	if err := cw.Write(RecordColumns); err != nil {
+		return err
+	}
+	for _, r := range rs {
+		if err := cw.Write(r.Fields()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
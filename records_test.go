@@ -0,0 +1,155 @@
+package symc
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		expect  []*Record
+	}{
+		{
+			"empty",
+			"",
+			[]*Record{},
+		},
+		{
+			"file scope",
+			"int a; extern int b; void f(void);",
+			[]*Record{
+				{Symbol: "a", Kind: KindDefinition},
+				{Symbol: "b", Kind: KindDeclaration},
+				{Symbol: "f", Kind: KindPrototype},
+			},
+		},
+		{
+			"function body and call arguments",
+			"int f(int x) { int y; a = x; g(a, h(y)); }",
+			[]*Record{
+				{Symbol: "f", Kind: KindDefinition},
+				{Function: "f", Symbol: "x", Kind: KindDefinition},
+				{Function: "f", Symbol: "y", Kind: KindDefinition},
+				{Function: "f", Symbol: "a", Kind: KindWrite},
+				{Function: "f", Symbol: "x", Kind: KindRead, Local: true},
+				{Function: "f", Symbol: "g", Kind: KindCall},
+				{Function: "f", Symbol: "a", Kind: KindRead},
+				{Function: "f", Symbol: "h", Kind: KindCall},
+				{Function: "f", Symbol: "y", Kind: KindRead, Local: true},
+			},
+		},
+		{
+			"nested function",
+			"void f(void) { int g(int z) { return z; } g(1); }",
+			[]*Record{
+				{Symbol: "f", Kind: KindDefinition},
+				{Function: "f", Symbol: "g", Kind: KindDefinition},
+				{Function: "g", Symbol: "z", Kind: KindDefinition},
+				{Function: "g", Symbol: "z", Kind: KindRead, Local: true},
+				{Function: "f", Symbol: "g", Kind: KindCall},
+			},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		actual := Flatten(ParseModule(tt.src))
+		if !reflect.DeepEqual(actual, tt.expect) {
+			t.Errorf("got=%v, expect=%v", actual, tt.expect)
+		}
+	}
+}
+
+func TestWriteRecords(t *testing.T) {
+	rs := []*Record{
+		{File: "a.c", Symbol: "a", Kind: KindDefinition, Pos: Position{File: "a.c", Offset: 4, Line: 1, Column: 5}},
+		{File: "dir, with \"quote\".c", Function: "f", Symbol: "b", Kind: KindRead, Pos: Position{File: "dir, with \"quote\".c", Offset: 10, Line: 2, Column: 3}},
+		{File: "tab\t.c", Function: "f", Symbol: "g", Kind: KindCall},
+	}
+	testTbl := []struct {
+		comment string
+		write   func(w *bytes.Buffer) error
+		expect  string
+	}{
+		{
+			"csv",
+			func(w *bytes.Buffer) error { return WriteCSV(w, rs) },
+			"file,function,symbol,kind,line,column\n" +
+				"a.c,,a,definition,1,5\n" +
+				"\"dir, with \"\"quote\"\".c\",f,b,read,2,3\n" +
+				"tab\t.c,f,g,call,,\n",
+		},
+		{
+			"tsv",
+			func(w *bytes.Buffer) error { return WriteTSV(w, rs) },
+			"file\tfunction\tsymbol\tkind\tline\tcolumn\n" +
+				"a.c\t\ta\tdefinition\t1\t5\n" +
+				"\"dir, with \"\"quote\"\".c\"\tf\tb\tread\t2\t3\n" +
+				"\"tab\t.c\"\tf\tg\tcall\t\t\n",
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		var b bytes.Buffer
+		if err := tt.write(&b); err != nil {
+			t.Fatalf("got err=%v", err)
+		}
+		if b.String() != tt.expect {
+			t.Errorf("got=%q, expect=%q", b.String(), tt.expect)
+		}
+	}
+}
+
+func TestRecordsFile(t *testing.T) {
+	src := "int a;\nvoid f(void) { a = 1; }\n"
+	testTbl := []struct {
+		comment string
+		opts    ParseOptions
+		expect  string
+	}{
+		{
+			"positions",
+			ParseOptions{FileName: "hoge.c", Positions: true},
+			"file,function,symbol,kind,line,column\n" +
+				"hoge.c,,a,definition,1,5\n" +
+				"hoge.c,,f,definition,2,6\n" +
+				"hoge.c,f,a,write,2,16\n",
+		},
+		{
+			"file name without positions",
+			ParseOptions{FileName: "hoge.c"},
+			"file,function,symbol,kind,line,column\n" +
+				"hoge.c,,a,definition,,\n" +
+				"hoge.c,,f,definition,,\n" +
+				"hoge.c,f,a,write,,\n",
+		},
+		{
+			"no file name",
+			ParseOptions{},
+			"file,function,symbol,kind,line,column\n" +
+				",,a,definition,,\n" +
+				",,f,definition,,\n" +
+				",f,a,write,,\n",
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		a, err := Analyze(context.Background(), src, tt.opts)
+		if err != nil {
+			t.Fatalf("got err=%v", err)
+		}
+		var b bytes.Buffer
+		if err := WriteCSV(&b, a.Records()); err != nil {
+			t.Fatalf("got err=%v", err)
+		}
+		if b.String() != tt.expect {
+			t.Errorf("got=%q, expect=%q", b.String(), tt.expect)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package symc
+
+// scip モジュール
+// 索引を SCIP (scip.proto) の Index メッセージとして書き出す
+// 依存を増やさないため protobuf のワイヤ形式を直接組み立てる
+
+import (
+	"io"
+	"net/url"
+	"path/filepath"
+)
+
+// protobuf のワイヤ型
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// scip.proto の列挙値
+const (
+	scipTextEncodingUTF8     = 1
+	scipPositionEncodingUTF8 = 1 // UTF8CodeUnitOffsetFromLineStart
+)
+
+// protoBuffer protobuf のメッセージを組み立てる
+type protoBuffer []byte
+
+// varint
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+// key
+func (b *protoBuffer) key(field int, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+// int32
+// ゼロ値は proto3 の既定値のため書き出さない
+func (b *protoBuffer) int32(field int, v int) {
+	if v == 0 {
+		return
+	}
+	b.key(field, wireVarint)
+	b.varint(uint64(int64(v)))
+}
+
+// bytes
+func (b *protoBuffer) bytes(field int, v []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+// string
+// 空文字列は proto3 の既定値のため書き出さない
+func (b *protoBuffer) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	b.bytes(field, []byte(v))
+}
+
+// message
+func (b *protoBuffer) message(field int, m protoBuffer) {
+	b.bytes(field, m)
+}
+
+// packed
+// repeated int32 を packed 形式で書き出す
+func (b *protoBuffer) packed(field int, vs []int) {
+	var p protoBuffer
+	for _, v := range vs {
+		p.varint(uint64(int64(v)))
+	}
+	b.bytes(field, p)
+}
+
+// WriteSCIP
+// 索引を SCIP の Index メッセージとして書き出す
+func WriteSCIP(w io.Writer, idx *Index) error {
+	var b protoBuffer
+
+	// Metadata
+	var tool protoBuffer
+	tool.string(1, "symc")
+	var meta protoBuffer
+	meta.message(2, tool)
+	meta.string(3, fileURI(idx.ProjectRoot, ""))
+	meta.int32(4, scipTextEncodingUTF8)
+	b.message(1, meta)
+
+	for _, d := range idx.Documents {
+		var doc protoBuffer
+		doc.string(1, d.Path)
+		for _, o := range d.Occurrences {
+			var occ protoBuffer
+			// 同じ行の場合は [行, 開始列, 終了列] の 3 要素
+			occ.packed(1, []int{o.Line, o.Column, o.EndColumn})
+			occ.string(2, o.Symbol)
+			occ.int32(3, o.Roles)
+			doc.message(2, occ)
+		}
+		for _, s := range d.Symbols {
+			var info protoBuffer
+			info.string(1, s.Symbol)
+			info.string(3, "```c\n"+s.Signature+"\n```")
+			info.string(6, s.DisplayName)
+			doc.message(3, info)
+		}
+		doc.string(4, "C")
+		doc.int32(6, scipPositionEncodingUTF8)
+		b.message(2, doc)
+	}
+
+	_, err := w.Write(b)
+	return err
+}
+
+// fileURI
+// ProjectRoot を基準としたパスの file URI を返す
+func fileURI(root, path string) string {
+	p := path
+	if !filepath.IsAbs(p) {
+		if root == "" {
+			root = "/"
+		}
+		p = filepath.Join(root, p)
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(p)}
+	return u.String()
+}
@@ -0,0 +1,138 @@
+package symc
+
+import (
+	"bytes"
+	"testing"
+)
+
+// protoField 検証用に読み取ったフィールド
+type protoField struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+// decodeProto
+// メッセージをフィールドの並びに分解する
+func decodeProto(t *testing.T, b []byte) []protoField {
+	fs := []protoField{}
+	varint := func() uint64 {
+		var v uint64
+		for shift := 0; ; shift += 7 {
+			if len(b) == 0 {
+				t.Fatalf("truncated varint")
+			}
+			c := b[0]
+			b = b[1:]
+			v |= uint64(c&0x7f) << shift
+			if c < 0x80 {
+				return v
+			}
+		}
+	}
+	for len(b) > 0 {
+		key := varint()
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case wireVarint:
+			f.varint = varint()
+		case wireBytes:
+			n := int(varint())
+			f.bytes = b[:n]
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// fieldsOf
+func fieldsOf(fs []protoField, num int) []protoField {
+	xs := []protoField{}
+	for _, f := range fs {
+		if f.num == num {
+			xs = append(xs, f)
+		}
+	}
+	return xs
+}
+
+func TestProtoBuffer(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		build   func(b *protoBuffer)
+		expect  []byte
+	}{
+		{"varint", func(b *protoBuffer) { b.int32(1, 150) }, []byte{0x08, 0x96, 0x01}},
+		{"zero is omitted", func(b *protoBuffer) { b.int32(1, 0); b.string(2, "") }, nil},
+		{"string", func(b *protoBuffer) { b.string(2, "testing") }, []byte{0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}},
+		{"packed", func(b *protoBuffer) { b.packed(4, []int{3, 270, 86942}) }, []byte{0x22, 0x06, 0x03, 0x8e, 0x02, 0x9e, 0xa7, 0x05}},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		var b protoBuffer
+		tt.build(&b)
+		if !bytes.Equal(b, tt.expect) {
+			t.Errorf("got=%x, expect=%x", []byte(b), tt.expect)
+		}
+	}
+}
+
+func TestWriteSCIP(t *testing.T) {
+	idx := buildTestIndex(t, nil)
+	var b bytes.Buffer
+	if err := WriteSCIP(&b, idx); err != nil {
+		t.Fatalf("got err=%v", err)
+	}
+	index := decodeProto(t, b.Bytes())
+
+	meta := decodeProto(t, fieldsOf(index, 1)[0].bytes)
+	tool := decodeProto(t, fieldsOf(meta, 2)[0].bytes)
+	if string(fieldsOf(tool, 1)[0].bytes) != "symc" {
+		t.Errorf("got tool=%v", tool)
+	}
+	if root := string(fieldsOf(meta, 3)[0].bytes); root != "file:///src" {
+		t.Errorf("got root=%v", root)
+	}
+
+	docs := fieldsOf(index, 2)
+	if len(docs) != 2 {
+		t.Fatalf("got %d documents", len(docs))
+	}
+	doc := decodeProto(t, docs[1].bytes)
+	if path := string(fieldsOf(doc, 1)[0].bytes); path != "a.c" {
+		t.Errorf("got path=%v", path)
+	}
+	if lang := string(fieldsOf(doc, 4)[0].bytes); lang != "C" {
+		t.Errorf("got language=%v", lang)
+	}
+
+	// count = count + t; の代入先
+	occs := fieldsOf(doc, 2)
+	occ := decodeProto(t, occs[5].bytes)
+	if !bytes.Equal(fieldsOf(occ, 1)[0].bytes, []byte{3, 4, 9}) {
+		t.Errorf("got range=%v", fieldsOf(occ, 1)[0].bytes)
+	}
+	if sym := string(fieldsOf(occ, 2)[0].bytes); sym != "symc . . . count." {
+		t.Errorf("got symbol=%v", sym)
+	}
+	if roles := fieldsOf(occ, 3)[0].varint; roles != RoleWriteAccess {
+		t.Errorf("got roles=%v", roles)
+	}
+
+	// シンボルの情報とホバー
+	syms := fieldsOf(doc, 3)
+	info := decodeProto(t, syms[2].bytes)
+	if sym := string(fieldsOf(info, 1)[0].bytes); sym != "symc . . . add()." {
+		t.Errorf("got symbol=%v", sym)
+	}
+	if hover := string(fieldsOf(info, 3)[0].bytes); hover != "```c\nadd(n)\n```" {
+		t.Errorf("got documentation=%q", hover)
+	}
+	if name := string(fieldsOf(info, 6)[0].bytes); name != "add" {
+		t.Errorf("got display name=%v", name)
+	}
+}
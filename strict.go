@@ -0,0 +1,358 @@
+package symc
+
+// strict モジュール
+// 厳密モードで宣言と式が C の文法に沿っているかを検証する
+
+import "strings"
+
+// 宣言指定子として使われるキーワード以外の予約語
+var (
+	// 型指定子
+	basicTypeWords = map[string]bool{
+		"char": true, "short": true, "int": true, "long": true,
+		"float": true, "double": true, "signed": true, "unsigned": true,
+		"_Bool": true, "_Complex": true, "__int128": true,
+		"__signed": true, "__signed__": true,
+	}
+	// 型修飾子, 記憶域クラス指定子, 関数指定子
+	qualifierWords = map[string]bool{
+		"static": true, "extern": true, "register": true, "auto": true,
+		"inline": true, "__inline": true, "__inline__": true,
+		"restrict": true, "__restrict": true, "__restrict__": true,
+		"__const": true, "__volatile__": true, "__extension__": true,
+		"_Noreturn": true, "_Thread_local": true, "__thread": true, "_Atomic": true,
+	}
+)
+
+// 組み合わせて用いることのできる型指定子 (C11 6.7.2 と GCC の拡張)
+// 型指定子は basicTypeOrder の順に並べ, 空白で区切る
+var basicTypeCombinations = map[string]bool{
+	"void": true, "_Bool": true,
+	"char": true, "signed char": true, "unsigned char": true,
+	"short": true, "signed short": true, "short int": true, "signed short int": true,
+	"unsigned short": true, "unsigned short int": true,
+	"int": true, "signed": true, "signed int": true, "unsigned": true, "unsigned int": true,
+	"long": true, "signed long": true, "long int": true, "signed long int": true,
+	"unsigned long": true, "unsigned long int": true,
+	"long long": true, "signed long long": true, "long long int": true, "signed long long int": true,
+	"unsigned long long": true, "unsigned long long int": true,
+	"float": true, "double": true, "long double": true,
+	"_Complex": true, "float _Complex": true, "double _Complex": true, "long double _Complex": true,
+	"__int128": true, "signed __int128": true, "unsigned __int128": true,
+	"struct": true, "union": true, "enum": true,
+}
+
+// 組み合わせを比べる際の型指定子の順
+var basicTypeOrder = []string{
+	"void", "_Bool", "signed", "unsigned", "char", "short", "long", "int", "__int128",
+	"float", "double", "_Complex", "struct", "union", "enum",
+}
+
+// 宣言指定子の並びの検証結果
+type specifiers struct {
+	names   int            // 型名として使われた識別子の数
+	basics  int            // 型指定子の数
+	types   map[string]int // 型指定子ごとの数. 構造体, 共用体, 列挙型はキーワードで数える
+	pointer bool           // ポインタ宣言子を含むか
+}
+
+// isTypedefName
+// 型名ただひとつからなる宣言指定子か
+func (s specifiers) isTypedefName() bool {
+	return s.names == 1 && s.basics == 0
+}
+
+// addType
+// 型指定子を数える
+func (s *specifiers) addType(w string) {
+	if w == "__signed" || w == "__signed__" {
+		w = "signed"
+	}
+	if s.types == nil {
+		s.types = map[string]int{}
+	}
+	s.types[w]++
+	s.basics++
+}
+
+// conflicts
+// 組み合わせられない型名や型指定子を含むか
+func (s specifiers) conflicts() bool {
+	if s.names > 1 || s.names == 1 && s.basics > 0 {
+		// 型名と型指定子は組み合わせられない
+		return true
+	}
+	if s.basics == 0 {
+		return false
+	}
+	ws := []string{}
+	for _, w := range basicTypeOrder {
+		for i := 0; i < s.types[w]; i++ {
+			ws = append(ws, w)
+		}
+	}
+	return !basicTypeCombinations[strings.Join(ws, " ")]
+}
+
+// checkSpecifiers
+// トークン列が 宣言指定子 ポインタ [識別子] の並びとして正しいかを検証する
+// named の場合は末尾を宣言する識別子とする
+func checkSpecifiers(tks []*Token, named bool) (specifiers, bool) {
+	s := specifiers{}
+	if named {
+		if len(tks) == 0 {
+			return s, false
+		}
+		id := tks[len(tks)-1]
+		if !id.isToken(word) || basicTypeWords[id.literal] || qualifierWords[id.literal] {
+			return s, false
+		}
+		tks = tks[:len(tks)-1]
+	}
+
+	i := 0
+	// 宣言指定子
+SPEC:
+	for ; i < len(tks); i++ {
+		t := tks[i]
+		switch t.tokenType {
+		case keyStruct, keyUnion, keyEnum:
+			// タグ名が必要
+			if i+1 >= len(tks) || !tks[i+1].isToken(word) {
+				return s, false
+			}
+			s.addType(t.literal)
+			i++
+		case keyVoid:
+			s.addType(t.literal)
+		case keyConst, keyVolatile:
+		case word:
+			if basicTypeWords[t.literal] {
+				s.addType(t.literal)
+			} else if !qualifierWords[t.literal] {
+				s.names++
+			}
+		case asterisk, caret:
+			break SPEC
+		default:
+			return s, false
+		}
+	}
+	// ポインタ宣言子
+	for ; i < len(tks); i++ {
+		t := tks[i]
+		switch t.tokenType {
+		case asterisk, caret:
+			s.pointer = true
+		case keyConst, keyVolatile:
+		case word:
+			if !qualifierWords[t.literal] {
+				return s, false
+			}
+		default:
+			return s, false
+		}
+	}
+
+	if s.conflicts() {
+		return s, false
+	}
+	return s, s.names+s.basics > 0
+}
+
+// checkDeclarator
+// トークン列が ポインタ 識別子 の並びとして正しいかを検証する
+func checkDeclarator(tks []*Token) bool {
+	if len(tks) == 0 {
+		return false
+	}
+	id := tks[len(tks)-1]
+	if !id.isToken(word) || basicTypeWords[id.literal] || qualifierWords[id.literal] {
+		return false
+	}
+	for _, t := range tks[:len(tks)-1] {
+		switch t.tokenType {
+		case asterisk, caret, keyConst, keyVolatile:
+		case word:
+			if !qualifierWords[t.literal] {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isAmbiguousAfterCast
+// (識別子) の直後に現れた場合にキャストか括弧付きの式かを決められないトークンか
+func isAmbiguousAfterCast(t *Token) bool {
+	switch t.tokenType {
+	case lparen, plus, minus, asterisk, ampersand:
+		return true
+	}
+	return false
+}
+
+// checkStrict
+// 厳密モードの場合にトークン範囲 [from, to) を宣言指定子として検証する
+func (p *Parser) checkStrict(from, to int, named bool) (specifiers, bool) {
+	if !p.opts.Strict {
+		return specifiers{}, true
+	}
+	if from < 0 || to > len(p.tokens) || from > to {
+		return specifiers{}, false
+	}
+	return checkSpecifiers(p.tokens[from:to], named)
+}
+
+// ambiguous
+// 厳密モードで文法だけでは決められない構文を記録する
+// 記録した宣言は解析に成功しても誤りとして扱う
+func (p *Parser) ambiguous(at int, expected string) {
+	if p.strictErr != nil || at >= len(p.tokens) {
+		return
+	}
+	found := p.tokens[at].literal
+	if p.tokens[at].isToken(eof) {
+		found = ""
+	}
+	p.strictErr = &ParseError{
+		Pos:      p.position(p.spans[at].start),
+		Expected: expected,
+		Found:    found,
+		Rules:    append([]string{}, p.rules...),
+		index:    at,
+	}
+}
+
+// checkParameter
+// 厳密モードの場合にトークン範囲 [from, to) の仮引数宣言を検証する
+// 関数ポインタは対象外とする
+func (p *Parser) checkParameter(from, to int) bool {
+	if !p.opts.Strict {
+		return true
+	}
+	end := from
+	for end < to && p.tokens[end].isTypeToken() {
+		end++
+	}
+	if end < to && p.tokens[end].isToken(lparen) {
+		return true
+	}
+	_, ok := checkSpecifiers(p.tokens[from:end], true)
+	return ok
+}
+
+// validParameter
+// 識別子を省略した仮引数も受け付ける
+func validParameter(tks []*Token) bool {
+	if _, ok := checkSpecifiers(tks, true); ok {
+		return true
+	}
+	_, ok := checkSpecifiers(tks, false)
+	return ok
+}
+
+// strictErrorAt
+// 厳密モードで検証に失敗したトークン範囲 [from, to) のうち, 誤りとして示す位置と期待した構文を返す
+// 宣言をそこで終えられる位置があればその直後, 組み合わせられない型名や型指定子を加えた位置があればその位置を示す
+func (p *Parser) strictErrorAt(from, to int, valid func(tks []*Token) bool, end string) (int, string) {
+	for j := from + 1; j < to; j++ {
+		if valid(p.tokens[from:j]) {
+			return j, end
+		}
+	}
+	for k := from; k < to; k++ {
+		s, _ := checkSpecifiers(p.tokens[from:k+1], false)
+		if s.conflicts() {
+			return k, end
+		}
+	}
+	return from, "declaration specifiers"
+}
+
+// badSpecifierRun
+// 厳密モードでトークン範囲 [from, to) がどの宣言の指定子と識別子にもならない場合, 誤りの位置を記録する
+// 関数定義やプロトタイプ宣言として読めなかった場合に, 宣言の誤りの位置を優先して示すために用いる
+func (p *Parser) badSpecifierRun(from, to int) bool {
+	if !p.opts.Strict || from >= to {
+		return false
+	}
+	if _, ok := checkSpecifiers(p.tokens[from:to], true); ok {
+		return false
+	}
+	at, expected := p.strictErrorAt(from, to, func(tks []*Token) bool {
+		_, ok := checkSpecifiers(tks, true)
+		return ok
+	}, "';'")
+	if expected == "declaration specifiers" {
+		return false
+	}
+	p.pos = at
+	p.updateErrLog(expected)
+	return true
+}
+
+// badParameterRun
+// 厳密モードでトークン範囲 [from, to) がどの仮引数宣言にもならない場合, 誤りの位置を記録する
+func (p *Parser) badParameterRun(from, to int) bool {
+	if !p.opts.Strict || from >= to || validParameter(p.tokens[from:to]) {
+		return false
+	}
+	at, expected := p.strictErrorAt(from, to, validParameter, "',' or ')'")
+	if expected == "declaration specifiers" {
+		return false
+	}
+	p.pos = at
+	p.updateErrLog(expected)
+	return true
+}
+
+// parseExpressionBefore
+// トークンの位置 end をソースの終端として式を解析する
+// トークン列は差分解析でも共有するため, 打ち切った場合も含めて必ず元のトークンに戻す
+func (p *Parser) parseExpressionBefore(end int) []Statement {
+	tk := p.tokens[end]
+	p.tokens[end] = &Token{tokenType: eof}
+	defer func() { p.tokens[end] = tk }()
+	return p.parseExpression()
+}
+
+// parseConstantExpression
+// case ラベルの定数式を解析する
+// 式の解析が ':' を越えないよう, 終端の ':' の位置をソースの終端として扱う
+func (p *Parser) parseConstantExpression() []Statement {
+	defer p.trace("parseConstantExpression")()
+	end := p.index()
+	for depth, cond := 0, 0; ; end++ {
+		t := p.tokens[end]
+		if t.isToken(eof) || t.isToken(semicolon) || t.isToken(lbrace) || t.isToken(rbrace) {
+			p.updateErrLog("':'")
+			return nil
+		}
+		if t.isToken(lparen) || t.isToken(lbracket) {
+			depth++
+		} else if t.isToken(rparen) || t.isToken(rbracket) {
+			depth--
+		} else if t.isToken(question) && depth == 0 {
+			cond++
+		} else if t.isToken(colon) && depth == 0 {
+			if cond == 0 {
+				break
+			}
+			cond--
+		}
+	}
+	if end == p.index() {
+		p.updateErrLog("constant expression")
+		return nil
+	}
+
+	ss := p.parseExpressionBefore(end)
+	if ss == nil || p.index() != end {
+		p.updateErrLog("constant expression")
+		return nil
+	}
+	return ss
+}
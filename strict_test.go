@@ -0,0 +1,222 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStrict(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		err     string // 厳密モードのエラー. 通常モードではいずれもエラーにならない
+	}{
+		{
+			"valid declarations",
+			`unsigned long int x = 1, *y;
+static const char *s = "a";
+T v;
+T * p;
+static inline int f(int a, char *b, void (*fp)(int)) { return (int)a + (y + 1) * 2; }
+`,
+			"",
+		},
+		{
+			"valid basic type combinations",
+			`long unsigned long x;
+signed char c;
+short int s;
+long double d;
+double _Complex z;
+unsigned __int128 u;
+const struct tag *t;
+int f(long long int a, unsigned);
+`,
+			"",
+		},
+		{
+			"repeated int",
+			"int int x;",
+			"1:5: expected ';', found 'int'",
+		},
+		{
+			"char combined with double",
+			"char double x;",
+			"1:6: expected ';', found 'double'",
+		},
+		{
+			"void combined with int",
+			"void int x;",
+			"1:6: expected ';', found 'int'",
+		},
+		{
+			"short combined with long",
+			"short long x;",
+			"1:7: expected ';', found 'long'",
+		},
+		{
+			"three longs",
+			"long long long x;",
+			"1:11: expected ';', found 'long'",
+		},
+		{
+			"signed combined with unsigned in a parameter",
+			"int f(signed unsigned x);",
+			"1:14: expected ',' or ')', found 'unsigned'",
+		},
+		{
+			"struct combined with int in a block",
+			"int f(void) { struct tag int x; }",
+			"1:26: expected ';', found 'int'",
+		},
+		{
+			"two identifiers in a declaration",
+			"int a b;",
+			"1:7: expected ';', found 'b'",
+		},
+		{
+			"two typedef names in a function definition",
+			"foo bar baz(void) { }",
+			"1:19: expected ';', found '{'",
+		},
+		{
+			"typedef name combined with a basic type",
+			"int f(T int x) { }",
+			"1:9: expected ',' or ')', found 'int'",
+		},
+		{
+			"typedef name combined with a basic type in a prototype",
+			"int f(T int x);",
+			"1:9: expected ',' or ')', found 'int'",
+		},
+		{
+			"two identifiers in a later declarator",
+			"int a, b c;",
+			"1:10: expected ';', found 'c'",
+		},
+		{
+			"run of words as a cast",
+			"int f(void) { int x = (a b)y; }",
+			"1:26: expected ')', found 'b'",
+		},
+		{
+			"cast or subtraction",
+			"int f(void) { int x = (T)-1; }",
+			"1:23: expected unambiguous cast or parenthesized expression, found '('",
+		},
+		{
+			"cast or function call",
+			"int f(void) { x = (a)(b); }",
+			"1:19: expected unambiguous cast or parenthesized expression, found '('",
+		},
+		{
+			"declaration or multiplication in block",
+			"int f(void) { T * p; }",
+			"1:15: expected unambiguous declaration, found 'T'",
+		},
+		{
+			"junk after return expression",
+			"int f(void) { return a b; }",
+			"1:24: expected ';', found 'b'",
+		},
+		{
+			"declarator after structure body",
+			"struct s { int a; } v;",
+			"1:21: expected ';', found 'v'",
+		},
+		{
+			"declarator after enumeration body",
+			"enum e { A, B } *f(void);",
+			"1:18: expected ';', found 'f'",
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		if _, err := ParseWithOptions(tt.src, ParseOptions{}); err != nil {
+			t.Errorf("non-strict got err=%v", err)
+		}
+		_, err := ParseWithOptions(tt.src, ParseOptions{Strict: true})
+		if errString(err) != tt.err {
+			t.Errorf("got err=%v, expect err=%v", errString(err), tt.err)
+		}
+	}
+}
+
+func TestStrictStructure(t *testing.T) {
+	src := `struct s v, *w[2];
+struct s *f(void);
+struct s (*fp)(void);
+struct s;
+struct t { int a; };
+enum e { A, B };
+`
+	expect := &Module{
+		[]Statement{
+			&VariableDef{Name: "v"},
+			&VariableDef{Name: "w"},
+			&PrototypeDecl{Name: "f"},
+			&VariableDef{Name: "fp"},
+		},
+	}
+	// 通常モードでは構造体などで始まる宣言を読み飛ばす
+	if m, _ := ParseWithOptions(src, ParseOptions{}); len(m.Statements) != 0 {
+		t.Errorf("non-strict got=%v", m)
+	}
+	actual, err := ParseWithOptions(src, ParseOptions{Strict: true})
+	if err != nil {
+		t.Fatalf("got err=%v", err)
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("got=%v, expect=%v", actual, expect)
+	}
+}
+
+func TestStrictCaseLabel(t *testing.T) {
+	src := `int f(int x) {
+    switch (x) {
+    case A + 1: return a;
+    case 'a': break;
+    case (B ? 1 : 2): return 0;
+    }
+}
+`
+	expect := &Module{
+		[]Statement{
+			&FunctionDef{
+				Name:   "f",
+				Params: []*VariableDef{{Name: "x"}},
+				Statements: []Statement{
+					&RefVar{Name: "x", Local: true},
+					&RefVar{Name: "a"},
+				},
+			},
+		},
+	}
+	// 通常モードでは case ラベルに単一のトークンしか受け付けない
+	if _, err := ParseWithOptions(src, ParseOptions{}); err == nil {
+		t.Errorf("non-strict expected error")
+	}
+	actual, err := ParseWithOptions(src, ParseOptions{Strict: true})
+	if err != nil {
+		t.Fatalf("got err=%v", err)
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("got=%v, expect=%v", actual, expect)
+	}
+}
+
+func TestStrictCaseLabelAborted(t *testing.T) {
+	src := "int f(int x) { switch (x) { case (A + (B * (C - 1))): return 0; } }"
+	// 定数式の解析中を含め, あらゆる手数で打ち切っても ':' は元に戻っている
+	for max := 1; max < 200; max++ {
+		l := NewLexer(src)
+		p := NewParserWithOptions(l, ParseOptions{Strict: true, MaxSteps: max})
+		p.Parse()
+		for i, tk := range p.tokens[:len(p.tokens)-1] {
+			if tk.isToken(eof) {
+				t.Fatalf("MaxSteps=%d: token %d left as eof", max, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package symc
+
+// strlit モジュール
+// 文字列リテラルを収集する
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// StringLit 文字列リテラル
+// 連続するリテラルは連結し、エスケープシーケンスは展開した値を持つ
+type StringLit struct {
+	Value string
+	Func  string // 囲んでいる関数名. 関数外の場合は空
+	Call  string // 引数として渡された関数名. 引数でない場合は空
+	Arg   int    // 引数の位置. 引数でない場合は -1
+}
+
+func (v *StringLit) String() string {
+	return fmt.Sprintf("StringLit : Value=%q, Func=%s, Call=%s, Arg=%d", v.Value, v.Func, v.Call, v.Arg)
+}
+
+// 解析中の関数呼び出しの引数情報
+type callArg struct {
+	name  string
+	index int
+}
+
+// recordString
+// 解析のやり直しで同じトークンを再度解析した場合は上書きする
+func (p *Parser) recordString(idx int, lits []string) {
+	if p.strLits == nil {
+		p.strLits = map[int]*StringLit{}
+	}
+	v := ""
+	for _, l := range lits {
+		v += unquoteC(l)
+	}
+	s := &StringLit{Value: v, Func: p.funcName, Arg: -1}
+	if p.callArg.name != "" {
+		s.Call = p.callArg.name
+		s.Arg = p.callArg.index
+	}
+	p.undo = append(p.undo, undoEntry{kind: undoString, index: idx, str: p.strLits[idx]})
+	p.strLits[idx] = s
+}
+
+// StringLiterals
+// 解析済みの文字列リテラルを出現順に返す
+func (p *Parser) StringLiterals() []*StringLit {
+	idxs := []int{}
+	for i := range p.strLits {
+		idxs = append(idxs, i)
+	}
+	sort.Ints(idxs)
+
+	ss := []*StringLit{}
+	for _, i := range idxs {
+		ss = append(ss, p.strLits[i])
+	}
+	return ss
+}
+
+// unquoteC
+// ダブルクォートを外し C のエスケープシーケンスを展開する
+func unquoteC(lit string) string {
+	if len(lit) >= 1 && lit[0] == '"' {
+		lit = lit[1:]
+	}
+	if len(lit) >= 1 && lit[len(lit)-1] == '"' {
+		lit = lit[:len(lit)-1]
+	}
+
+	bs := []byte{}
+	for i := 0; i < len(lit); i++ {
+		c := lit[i]
+		if c != '\\' || i+1 >= len(lit) {
+			bs = append(bs, c)
+			continue
+		}
+		i++
+		c = lit[i]
+		switch c {
+		case 'n':
+			bs = append(bs, '\n')
+		case 't':
+			bs = append(bs, '\t')
+		case 'r':
+			bs = append(bs, '\r')
+		case 'a':
+			bs = append(bs, '\a')
+		case 'b':
+			bs = append(bs, '\b')
+		case 'f':
+			bs = append(bs, '\f')
+		case 'v':
+			bs = append(bs, '\v')
+		case 'x':
+			// 16進数
+			j := i + 1
+			for j < len(lit) && isHex(lit[j]) {
+				j++
+			}
+			if j == i+1 {
+				bs = append(bs, 'x')
+				break
+			}
+			n, _ := strconv.ParseUint(lit[i+1:j], 16, 64)
+			bs = append(bs, byte(n))
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// 8進数は最大3桁
+			j := i
+			for j < len(lit) && j < i+3 && '0' <= lit[j] && lit[j] <= '7' {
+				j++
+			}
+			n, _ := strconv.ParseUint(lit[i:j], 8, 64)
+			bs = append(bs, byte(n))
+			i = j - 1
+		default:
+			// \\ \" \' \? など
+			bs = append(bs, c)
+		}
+	}
+	return string(bs)
+}
@@ -0,0 +1,86 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStringLiterals(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		expect  []*StringLit
+	}{
+		{
+			"string literal 1",
+			`
+char *path = "/etc/" "app.conf";
+
+void func(int x)
+{
+    LOG_ERR("timeout %d\n", x);
+    msg = "a\tb\x41\101\"";
+    printf("%s", fmt("in\\ner", "x"));
+}
+`,
+			[]*StringLit{
+				{Value: "/etc/app.conf", Func: "", Call: "", Arg: -1},
+				{Value: "timeout %d\n", Func: "func", Call: "LOG_ERR", Arg: 0},
+				{Value: "a\tbAA\"", Func: "func", Call: "", Arg: -1},
+				{Value: "%s", Func: "func", Call: "printf", Arg: 0},
+				{Value: "in\\ner", Func: "func", Call: "fmt", Arg: 0},
+				{Value: "x", Func: "func", Call: "fmt", Arg: 1},
+			},
+		},
+		{
+			"string literal 2",
+			`
+void func(void)
+{
+    errorf("cpp.c" ":" "711", token_pos(hash), "cannot find header file: %s", filename);
+}
+`,
+			[]*StringLit{
+				{Value: "cpp.c:711", Func: "func", Call: "errorf", Arg: 0},
+				{Value: "cannot find header file: %s", Func: "func", Call: "errorf", Arg: 2},
+			},
+		},
+		{
+			"string literal in initializer list",
+			`
+char *tbl[] = {"a", "b" "c"};
+char *pairs[][1] = {{"d"}, {"e"}};
+
+void func(void)
+{
+    const char *names[] = {"x", y};
+}
+`,
+			[]*StringLit{
+				{Value: "a", Func: "", Call: "", Arg: -1},
+				{Value: "bc", Func: "", Call: "", Arg: -1},
+				{Value: "d", Func: "", Call: "", Arg: -1},
+				{Value: "e", Func: "", Call: "", Arg: -1},
+				{Value: "x", Func: "func", Call: "", Arg: -1},
+			},
+		},
+		{
+			"string literal in unparsable declaration",
+			`
+void f(void){ LOG("hello"); @@@; }
+void g(void){ LOG("world"); }
+`,
+			[]*StringLit{
+				{Value: "world", Func: "g", Call: "LOG", Arg: 0},
+			},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		got := ParseStringLiterals(tt.src)
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("\ngot=   %v\nexpect=%v\n", got, tt.expect)
+		}
+	}
+}
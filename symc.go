@@ -1,7 +1,118 @@
 package symc
 
+import (
+	"context"
+)
+
 func ParseModule(src string) *Module {
+	m, _ := ParseWithOptions(src, ParseOptions{})
+	return m
+}
+
+// ParseMetrics
+// ソースを解析し関数定義ごとのメトリクスを返す. オプションを指定する場合は Analyze を用いる
+func ParseMetrics(src string) []*Metrics {
+	a, _ := Analyze(context.Background(), src, ParseOptions{})
+	return a.Metrics()
+}
+
+// ParseStringLiterals
+// ソースを解析し文字列リテラルを出現順に返す. オプションを指定する場合は Analyze を用いる
+func ParseStringLiterals(src string) []*StringLit {
+	a, _ := Analyze(context.Background(), src, ParseOptions{})
+	return a.StringLiterals()
+}
+
+// ParseModuleDiagnostics
+// 解析できない宣言を読み飛ばしながらソースを解析し、読み飛ばした範囲と共に返す
+func ParseModuleDiagnostics(src string) (*Module, []*Diagnostic) {
+	a, _ := Analyze(context.Background(), src, ParseOptions{})
+	return a.Module, a.Diagnostics()
+}
+
+// Parse
+// ソースを解析する. 解析できない宣言があった場合は読み飛ばした結果と共に ErrorList を返す
+func Parse(src string) (*Module, error) {
+	return ParseWithOptions(src, ParseOptions{})
+}
+
+// ParseWithOptions
+// オプションを指定してソースを解析する. 解析できない宣言があった場合は読み飛ばした結果と共に ErrorList を返す
+func ParseWithOptions(src string, opts ParseOptions) (*Module, error) {
+	return ParseContext(context.Background(), src, opts)
+}
+
+// ParseContext
+// コンテキストの終了や資源制限の超過で打ち切り可能な解析を行う
+// 打ち切った場合はそれまでに解析できた結果と, context のエラーもしくは *LimitError を返す
+func ParseContext(ctx context.Context, src string, opts ParseOptions) (*Module, error) {
+	a, err := Analyze(ctx, src, opts)
+	return a.Module, err
+}
+
+// ParseCoverage
+// ソースを解析し, 解析したトークンと読み飛ばしたトークンの集計を返す. オプションを指定する場合は Analyze を用いる
+func ParseCoverage(src string) *Coverage {
+	a, _ := Analyze(context.Background(), src, ParseOptions{})
+	return a.Coverage()
+}
+
+// Analysis 解析の結果と, 解析中に集めた情報
+type Analysis struct {
+	Module *Module
+	Err    error // Analyze が返したエラー
+	p      *Parser
+}
+
+// Analyze
+// ParseContext と同じく解析し, モジュールと共にメトリクスや網羅状況を取り出せる結果を返す
+// エラーは Err にも格納する
+func Analyze(ctx context.Context, src string, opts ParseOptions) (*Analysis, error) {
 	l := NewLexer(src)
-	p := NewParser(l)
-	return p.Parse()
+	l.ctx = ctx
+	p := NewParserWithOptions(l, opts)
+	a := &Analysis{p: p}
+	a.Module, a.Err = p.ParseContext(ctx)
+	if a.Err == nil {
+		if errs := p.Errors(); len(errs) > 0 {
+			a.Err = ErrorList(errs)
+		}
+	}
+	return a, a.Err
+}
+
+// Source
+// 解析したソースを返す
+func (a *Analysis) Source() string {
+	return a.p.lexer.input
+}
+
+// Metrics
+// 関数定義ごとのメトリクスを出現順に返す
+func (a *Analysis) Metrics() []*Metrics {
+	return a.p.Metrics()
+}
+
+// StringLiterals
+// 文字列リテラルを出現順に返す
+func (a *Analysis) StringLiterals() []*StringLit {
+	return a.p.StringLiterals()
+}
+
+// Coverage
+// 解析したトークンと読み飛ばしたトークンの集計を返す
+func (a *Analysis) Coverage() *Coverage {
+	return a.p.Coverage()
+}
+
+// Records
+// モジュールを記録に平坦化する. 位置にファイル名がない記録の File は ParseOptions.FileName とする
+func (a *Analysis) Records() []*Record {
+	return FlattenFile(a.Module, a.p.opts.FileName)
+}
+
+// Diagnostics
+// 読み飛ばした範囲を出現順に返す
+func (a *Analysis) Diagnostics() []*Diagnostic {
+	return a.p.Diagnostics()
 }
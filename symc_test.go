@@ -1,6 +1,7 @@
 package symc
 
 import (
+	"context"
 	"reflect"
 	"testing"
 )
@@ -324,7 +325,7 @@ int dec2asc(char *str, int dec) {
     DEFINITION len_buf
     DEFINITION buf
     ASSIGNE buf
-    len
+    ASSIGNE len
     dec
     dec
     ASSIGNE dec
@@ -344,3 +345,69 @@ int dec2asc(char *str, int dec) {
 		}
 	}
 }
+
+func TestAnalyze(t *testing.T) {
+	src := `int f(void) { T * p; LOG("a"); }
+int g(void) { return 0; }
+`
+	testTbl := []struct {
+		comment     string
+		opts        ParseOptions
+		metrics     []string
+		strs        []string
+		skipped     int
+		diagnostics []string
+	}{
+		{
+			"default options",
+			ParseOptions{},
+			[]string{"f", "g"},
+			[]string{"a"},
+			0,
+			[]string{},
+		},
+		{
+			"strict mode",
+			ParseOptions{FileName: "hoge.c", Strict: true},
+			[]string{"g"},
+			[]string{},
+			16,
+			[]string{"hoge.c:1:1-hoge.c:1:33: skipped unparsable declaration starting at 'int'"},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		a, err := Analyze(context.Background(), src, tt.opts)
+		if (err != nil) != tt.opts.Strict || !reflect.DeepEqual(err, a.Err) {
+			t.Errorf("got err=%v", err)
+		}
+		if a.Source() != src {
+			t.Errorf("got source=%q", a.Source())
+		}
+		metrics := []string{}
+		for _, m := range a.Metrics() {
+			metrics = append(metrics, m.Name)
+		}
+		strs := []string{}
+		for _, s := range a.StringLiterals() {
+			strs = append(strs, s.Value)
+		}
+		diagnostics := []string{}
+		for _, d := range a.Diagnostics() {
+			diagnostics = append(diagnostics, d.String())
+		}
+		if !reflect.DeepEqual(metrics, tt.metrics) {
+			t.Errorf("got metrics=%v, expect=%v", metrics, tt.metrics)
+		}
+		if !reflect.DeepEqual(strs, tt.strs) {
+			t.Errorf("got strings=%v, expect=%v", strs, tt.strs)
+		}
+		if a.Coverage().Skipped != tt.skipped {
+			t.Errorf("got skipped=%v, expect=%v", a.Coverage().Skipped, tt.skipped)
+		}
+		if !reflect.DeepEqual(diagnostics, tt.diagnostics) {
+			t.Errorf("got diagnostics=%v, expect=%v", diagnostics, tt.diagnostics)
+		}
+	}
+}
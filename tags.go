@@ -0,0 +1,187 @@
+package symc
+
+// tags モジュール
+// 定義位置を ctags と etags の形式のタグファイルとして書き出す
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// タグの種類. ctags の C 言語の種類名と一文字の略号に合わせる
+const (
+	TagFunction  = "function"
+	TagVariable  = "variable"
+	TagLocal     = "local"
+	TagParameter = "parameter"
+	TagExternVar = "externvar"
+	TagPrototype = "prototype"
+	TagTypedef   = "typedef"
+)
+
+var tagLetters = map[string]string{
+	TagFunction:  "f",
+	TagVariable:  "v",
+	TagLocal:     "l",
+	TagParameter: "z",
+	TagExternVar: "x",
+	TagPrototype: "p",
+	TagTypedef:   "t",
+}
+
+// Tag 定義位置ひとつ
+type Tag struct {
+	Name       string
+	File       string
+	Line       int
+	Column     int
+	Kind       string // Tag* のいずれか
+	Scope      string // 定義を含む関数名. ファイルスコープの場合は空
+	Text       string // 定義のある行のテキスト. 不明な場合は空
+	LineOffset int    // 定義のある行の先頭のファイル内のバイト位置. 不明な場合は -1
+}
+
+// Tags
+// モジュールの定義と宣言をタグにする. 位置情報のないシンボルは含めない
+func Tags(ms ...*Module) []*Tag {
+	ts := []*Tag{}
+	for _, m := range ms {
+		ts = collectTags(m.Statements, "", ts)
+	}
+	return ts
+}
+
+// collectTags
+func collectTags(ss []Statement, fn string, ts []*Tag) []*Tag {
+	add := func(name, kind string, pos Position) {
+		if pos.IsValid() {
+			ts = append(ts, &Tag{Name: name, File: pos.File, Line: pos.Line, Column: pos.Column, Kind: kind, Scope: fn, LineOffset: -1})
+		}
+	}
+	for _, s := range ss {
+		switch v := s.(type) {
+		case *VariableDef:
+			if fn == "" {
+				add(v.Name, TagVariable, v.Pos)
+			} else {
+				add(v.Name, TagLocal, v.Pos)
+			}
+		case *VariableDecl:
+			add(v.Name, TagExternVar, v.Pos)
+		case *PrototypeDecl:
+			add(v.Name, TagPrototype, v.Pos)
+		case *Typedef:
+			add(v.Name, TagTypedef, v.Pos)
+		case *FunctionDef:
+			add(v.Name, TagFunction, v.Pos)
+			for _, p := range v.Params {
+				if p.Pos.IsValid() {
+					ts = append(ts, &Tag{Name: p.Name, File: p.Pos.File, Line: p.Pos.Line, Column: p.Pos.Column, Kind: TagParameter, Scope: v.Name, LineOffset: -1})
+				}
+			}
+			ts = collectTags(v.Statements, v.Name, ts)
+		}
+	}
+	return ts
+}
+
+// FillTagText
+// read で読み込めたファイルについて, タグの行のテキストと行の先頭位置を設定する
+func FillTagText(ts []*Tag, read func(file string) (string, error)) {
+	lines := map[string][]string{}
+	for _, t := range ts {
+		ls, ok := lines[t.File]
+		if !ok {
+			if src, err := read(t.File); err == nil {
+				ls = strings.SplitAfter(src, "\n")
+			}
+			lines[t.File] = ls
+		}
+		if t.Line < 1 || t.Line > len(ls) {
+			continue
+		}
+		off := 0
+		for _, l := range ls[:t.Line-1] {
+			off += len(l)
+		}
+		t.Text = strings.TrimRight(ls[t.Line-1], "\r\n")
+		t.LineOffset = off
+	}
+}
+
+// WriteCtags
+// Exuberant/Universal ctags の拡張形式でタグを書き出す
+// タグは名前, ファイル, 行の順に並べ替える
+func WriteCtags(w io.Writer, ts []*Tag) error {
+	sorted := append([]*Tag{}, ts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/\n")
+	fmt.Fprintf(bw, "!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n")
+	fmt.Fprintf(bw, "!_TAG_PROGRAM_NAME\tsymc\t//\n")
+	for _, t := range sorted {
+		// 行のテキストが分かる場合は検索パターン, 分からない場合は行番号で位置を示す
+		addr := fmt.Sprint(t.Line)
+		if t.Text != "" {
+			addr = "/^" + escapeTagPattern(t.Text) + "$/"
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s;\"\tkind:%s\tline:%d", t.Name, t.File, addr, tagLetters[t.Kind], t.Line)
+		if t.Scope != "" {
+			fmt.Fprintf(bw, "\tfunction:%s", t.Scope)
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// escapeTagPattern
+// 検索パターン中で特別な意味を持つ文字をエスケープする
+func escapeTagPattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `/`, `\/`)
+	return r.Replace(s)
+}
+
+// WriteEtags
+// Emacs の etags 形式でタグを書き出す. ファイルごとの節は初めに現れた順に並べる
+func WriteEtags(w io.Writer, ts []*Tag) error {
+	files := []string{}
+	sections := map[string]*strings.Builder{}
+	for _, t := range ts {
+		b, ok := sections[t.File]
+		if !ok {
+			b = &strings.Builder{}
+			sections[t.File] = b
+			files = append(files, t.File)
+		}
+		// 行の先頭から名前までを検索に用いる
+		text := t.Name
+		if end := t.Column - 1 + len(t.Name); t.Column > 0 && end <= len(t.Text) && t.Text[t.Column-1:end] == t.Name {
+			text = t.Text[:end]
+		}
+		off := ""
+		if t.LineOffset >= 0 {
+			off = fmt.Sprint(t.LineOffset)
+		}
+		fmt.Fprintf(b, "%s\x7f%s\x01%d,%s\n", text, t.Name, t.Line, off)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, f := range files {
+		body := sections[f].String()
+		fmt.Fprintf(bw, "\x0c\n%s,%d\n%s", f, len(body), body)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,119 @@
+package symc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+const tagsSrcA = `int a;
+int f(int x) {
+    int y;
+    return x;
+}
+`
+
+const tagsSrcB = `# 1 "b.h"
+extern int e;
+void p(char *s); char *u = "a/b";
+# 1 "b.c"
+static int i;
+`
+
+func parseTagModules(t *testing.T) []*Module {
+	a, err := ParseWithOptions(tagsSrcA, ParseOptions{FileName: "a.c", Positions: true})
+	if err != nil {
+		t.Fatalf("got err=%v", err)
+	}
+	b, err := ParseWithOptions(tagsSrcB, ParseOptions{FileName: "b.i", Positions: true})
+	if err != nil {
+		t.Fatalf("got err=%v", err)
+	}
+	return []*Module{a, b}
+}
+
+func TestCtags(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		fill    bool
+		expect  string
+	}{
+		{
+			"line numbers",
+			false,
+			"!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/\n" +
+				"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n" +
+				"!_TAG_PROGRAM_NAME\tsymc\t//\n" +
+				"a\ta.c\t1;\"\tkind:v\tline:1\n" +
+				"e\tb.h\t1;\"\tkind:x\tline:1\n" +
+				"f\ta.c\t2;\"\tkind:f\tline:2\n" +
+				"i\tb.c\t1;\"\tkind:v\tline:1\n" +
+				"p\tb.h\t2;\"\tkind:p\tline:2\n" +
+				"u\tb.h\t2;\"\tkind:v\tline:2\n" +
+				"x\ta.c\t2;\"\tkind:z\tline:2\tfunction:f\n" +
+				"y\ta.c\t3;\"\tkind:l\tline:3\tfunction:f\n",
+		},
+		{
+			"search patterns",
+			true,
+			"!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/\n" +
+				"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n" +
+				"!_TAG_PROGRAM_NAME\tsymc\t//\n" +
+				"a\ta.c\t/^int a;$/;\"\tkind:v\tline:1\n" +
+				"e\tb.h\t/^extern int e;$/;\"\tkind:x\tline:1\n" +
+				"f\ta.c\t/^int f(int x) {$/;\"\tkind:f\tline:2\n" +
+				"i\tb.c\t1;\"\tkind:v\tline:1\n" +
+				"p\tb.h\t/^void p(char *s); char *u = \"a\\/b\";$/;\"\tkind:p\tline:2\n" +
+				"u\tb.h\t/^void p(char *s); char *u = \"a\\/b\";$/;\"\tkind:v\tline:2\n" +
+				"x\ta.c\t/^int f(int x) {$/;\"\tkind:z\tline:2\tfunction:f\n" +
+				"y\ta.c\t/^    int y;$/;\"\tkind:l\tline:3\tfunction:f\n",
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		ts := Tags(parseTagModules(t)...)
+		if tt.fill {
+			FillTagText(ts, readTagSource)
+		}
+		var b bytes.Buffer
+		if err := WriteCtags(&b, ts); err != nil {
+			t.Fatalf("got err=%v", err)
+		}
+		if b.String() != tt.expect {
+			t.Errorf("got=%q, expect=%q", b.String(), tt.expect)
+		}
+	}
+}
+
+func TestEtags(t *testing.T) {
+	ts := Tags(parseTagModules(t)...)
+	FillTagText(ts, readTagSource)
+	var b bytes.Buffer
+	if err := WriteEtags(&b, ts); err != nil {
+		t.Fatalf("got err=%v", err)
+	}
+	a := "int a\x7fa\x011,0\n" +
+		"int f\x7ff\x012,7\n" +
+		"int f(int x\x7fx\x012,7\n" +
+		"    int y\x7fy\x013,22\n"
+	h := "extern int e\x7fe\x011,0\n" +
+		"void p\x7fp\x012,14\n" +
+		"void p(char *s); char *u\x7fu\x012,14\n"
+	c := "i\x7fi\x011,\n"
+	expect := fmt.Sprintf("\x0c\na.c,%d\n%s\x0c\nb.h,%d\n%s\x0c\nb.c,%d\n%s", len(a), a, len(h), h, len(c), c)
+	if b.String() != expect {
+		t.Errorf("got=%q, expect=%q", b.String(), expect)
+	}
+}
+
+// b.c は読み込めないものとする
+func readTagSource(file string) (string, error) {
+	switch file {
+	case "a.c":
+		return tagsSrcA, nil
+	case "b.h":
+		return "extern int e;\nvoid p(char *s); char *u = \"a/b\";\n", nil
+	}
+	return "", fmt.Errorf("%s: not found", file)
+}
@@ -0,0 +1,246 @@
+package symc
+
+// typedef モジュール
+// スコープごとに宣言された識別子が型名か否かを記憶し, 宣言と式の曖昧さを解消する
+
+// scope 識別子から型名か否かへの対応
+type scope map[string]bool
+
+// pushScope
+// ブロックスコープに入る
+func (p *Parser) pushScope() {
+	p.fileScope()
+	p.scopes = append(p.scopes, scope{})
+	p.enumerators = append(p.enumerators, scope{})
+}
+
+// popScope
+// ブロックスコープを抜ける
+func (p *Parser) popScope() {
+	if len(p.scopes) > 1 {
+		p.scopes = p.scopes[:len(p.scopes)-1]
+		p.enumerators = p.enumerators[:len(p.scopes)]
+	}
+}
+
+// fileScope
+// ファイルスコープを返す. なければ作成する
+func (p *Parser) fileScope() scope {
+	if len(p.scopes) == 0 {
+		p.scopes = append(p.scopes, scope{})
+	}
+	for len(p.enumerators) < len(p.scopes) {
+		p.enumerators = append(p.enumerators, scope{})
+	}
+	return p.scopes[0]
+}
+
+// declare
+// 現在のスコープに識別子を宣言する. isType は typedef 名の場合に true
+func (p *Parser) declare(name string, isType bool) {
+	p.fileScope()
+	p.scopes[len(p.scopes)-1][name] = isType
+	if len(p.scopes) == 1 && p.topNames != nil {
+		// 差分解析のためにトップレベルの宣言ごとに記憶する
+		p.topNames[name] = isType
+	}
+}
+
+// lookupName
+// 内側のスコープから識別子を探す
+// 見つかった場合は型名か否かと true を, 見つからない場合は false を返す
+func (p *Parser) lookupName(name string) (isType bool, known bool) {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if v, ok := p.scopes[i][name]; ok {
+			return v, true
+		}
+	}
+	return false, false
+}
+
+// isGlobalVariable
+// ファイルスコープの変数として参照される識別子か
+// ブロックスコープの宣言や仮引数, 列挙定数は除く. 宣言が見つからない識別子はファイルスコープのものとみなす
+func (p *Parser) isGlobalVariable(name string) bool {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if _, ok := p.scopes[i][name]; ok {
+			return i == 0 && !p.enumerators[i][name]
+		}
+	}
+	return true
+}
+
+// isLocalName
+// ブロックスコープで宣言した名前か仮引数か
+func (p *Parser) isLocalName(name string) bool {
+	for i := len(p.scopes) - 1; i > 0; i-- {
+		if _, ok := p.scopes[i][name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isOrdinaryName
+// 型名でない識別子(変数や関数)として宣言済みか
+func (p *Parser) isOrdinaryName(name string) bool {
+	isType, known := p.lookupName(name)
+	return known && !isType
+}
+
+// isTypedefName
+// typedef 名として宣言済みか
+func (p *Parser) isTypedefName(name string) bool {
+	isType, known := p.lookupName(name)
+	return known && isType
+}
+
+// hasOrdinaryName
+// トークン範囲 [from, to) の型名の候補に変数や関数として宣言済みの識別子が含まれるか
+func (p *Parser) hasOrdinaryName(from, to int) bool {
+	for i := from; i < to && i < len(p.tokens); i++ {
+		t := p.tokens[i]
+		if t.isToken(word) && !basicTypeWords[t.literal] && !qualifierWords[t.literal] && !p.isTag(i) && p.isOrdinaryName(t.literal) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTag
+// i 番目のトークンが struct, union, enum のタグか. タグは変数や typedef 名とは別の名前空間
+func (p *Parser) isTag(i int) bool {
+	if i <= 0 || i >= len(p.tokens) {
+		return false
+	}
+	prev := p.tokens[i-1]
+	return prev.isToken(keyStruct) || prev.isToken(keyUnion) || prev.isToken(keyEnum)
+}
+
+// hasUnknownName
+// トークン範囲 [from, to) の型名の候補に宣言が見つからない識別子が含まれるか
+func (p *Parser) hasUnknownName(from, to int) bool {
+	for i := from; i < to && i < len(p.tokens); i++ {
+		t := p.tokens[i]
+		if !t.isToken(word) || basicTypeWords[t.literal] || qualifierWords[t.literal] || p.isTag(i) {
+			continue
+		}
+		if _, known := p.lookupName(t.literal); !known {
+			return true
+		}
+	}
+	return false
+}
+
+// declareStatements
+// 解析した宣言の識別子を現在のスコープに宣言する
+func (p *Parser) declareStatements(ss []Statement) {
+	for _, s := range ss {
+		switch v := s.(type) {
+		case *VariableDef:
+			p.declare(v.Name, false)
+		case *VariableDecl:
+			p.declare(v.Name, false)
+		case *PrototypeDecl:
+			p.declare(v.Name, false)
+		case *FunctionDef:
+			p.declare(v.Name, false)
+		}
+	}
+}
+
+// declareTypedefs
+// トークン範囲 [from, to) の typedef 宣言で宣言された型名を現在のスコープに宣言する
+func (p *Parser) declareTypedefs(from, to int) {
+	tks := p.tokens[from:to]
+	if len(tks) == 0 || !tks[0].isToken(keyTypedef) {
+		return
+	}
+	tks = tks[1:]
+	// 構造体などの本体は読み飛ばす
+	seg := []*Token{}
+	depth := 0
+	for _, t := range tks {
+		switch {
+		case t.isToken(lbrace):
+			depth++
+			continue
+		case t.isToken(rbrace):
+			depth--
+			continue
+		case depth > 0:
+			continue
+		case t.isToken(comma) || t.isToken(semicolon):
+			if name := declaratorName(seg); name != "" {
+				p.declare(name, true)
+			}
+			seg = []*Token{}
+			continue
+		}
+		seg = append(seg, t)
+	}
+}
+
+// declaratorName
+// 宣言子のトークン列から宣言する識別子を返す
+// (*名前)(引数) の形式の場合は括弧の内側から探す
+func declaratorName(tks []*Token) string {
+	name := ""
+	for i := 0; i < len(tks); i++ {
+		t := tks[i]
+		switch t.tokenType {
+		case word:
+			if !basicTypeWords[t.literal] && !qualifierWords[t.literal] {
+				name = t.literal
+			}
+		case keyAttribute, keyAsm:
+			// 属性の括弧を読み飛ばす
+			if i+1 < len(tks) && tks[i+1].isToken(lparen) {
+				i = matchParen(tks, i+1)
+			}
+		case lparen:
+			if i+1 < len(tks) && (tks[i+1].isToken(asterisk) || tks[i+1].isToken(caret)) {
+				end := matchParen(tks, i)
+				return declaratorName(tks[i+1 : end])
+			}
+			// 引数の並び
+			return name
+		case lbracket:
+			return name
+		}
+	}
+	return name
+}
+
+// matchParen
+// tks[i] の '(' に対応する ')' の位置を返す. ない場合は末尾
+func matchParen(tks []*Token, i int) int {
+	depth := 0
+	for ; i < len(tks); i++ {
+		if tks[i].isToken(lparen) {
+			depth++
+		} else if tks[i].isToken(rparen) {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tks)
+}
+
+// isUnnamedParameter
+// 型名のみで識別子を持たない仮引数か. (T) の T は仮引数名ではなく型名
+func (p *Parser) isUnnamedParameter(from int, v *VariableDef) bool {
+	if !basicTypeWords[v.Name] && !p.isTypedefName(v.Name) {
+		return false
+	}
+	for i := from; i < len(p.tokens) && p.tokens[i].literal != v.Name; i++ {
+		t := p.tokens[i]
+		if t.isToken(keyVoid) || t.isToken(keyStruct) || t.isToken(keyUnion) || (t.isToken(word) && !qualifierWords[t.literal]) {
+			// 型名の後の識別子は型名を隠す仮引数名
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package symc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypedefName(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		expect  *Module
+	}{
+		{
+			"multiplication of declared variables",
+			"int f(void) { int a, b; a * b; }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{}, Statements: []Statement{
+				&VariableDef{Name: "a"}, &VariableDef{Name: "b"}, &RefVar{Name: "a", Local: true}, &RefVar{Name: "b", Local: true},
+			}}}},
+		},
+		{
+			"struct tag named like a function",
+			"int stat(const char *, struct stat *); int f(void) { struct stat st; struct stat *p = (struct stat *)0; stat(0, p); }",
+			&Module{[]Statement{&PrototypeDecl{Name: "stat"}, &FunctionDef{Name: "f", Params: []*VariableDef{}, Statements: []Statement{
+				&VariableDef{Name: "st"}, &VariableDef{Name: "p"}, &CallFunc{Name: "stat", Args: []Statement{&RefVar{Name: "p", Local: true}}},
+			}}}},
+		},
+		{
+			"multiplication with parameter",
+			"int a; int f(int x) { x * a; }",
+			&Module{[]Statement{&VariableDef{Name: "a"}, &FunctionDef{Name: "f", Params: []*VariableDef{{Name: "x"}}, Statements: []Statement{
+				&RefVar{Name: "x", Local: true}, &RefVar{Name: "a"},
+			}}}},
+		},
+		{
+			"pointer declaration with file scope typedef",
+			"typedef unsigned long size; int f(void) { size * p; }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{}, Statements: []Statement{
+				&VariableDef{Name: "p"},
+			}}}},
+		},
+		{
+			"pointer declaration with block scope typedef",
+			"int f(void) { typedef struct { int m; } T, *PT; T * p; PT q; }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{}, Statements: []Statement{
+				&VariableDef{Name: "p"}, &VariableDef{Name: "q"},
+			}}}},
+		},
+		{
+			"function pointer typedef",
+			"typedef int (*handler)(int); int f(void) { handler * h; }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{}, Statements: []Statement{
+				&VariableDef{Name: "h"},
+			}}}},
+		},
+		{
+			"variable shadows typedef in block",
+			"typedef int T; int b; int f(void) { int T; T * b; }",
+			&Module{[]Statement{&VariableDef{Name: "b"}, &FunctionDef{Name: "f", Params: []*VariableDef{}, Statements: []Statement{
+				&VariableDef{Name: "T"}, &RefVar{Name: "T", Local: true}, &RefVar{Name: "b"},
+			}}}},
+		},
+		{
+			"block scope ends",
+			"int f(void) { { int a; } a * b; }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{}, Statements: []Statement{
+				&VariableDef{Name: "a"}, &VariableDef{Name: "b"},
+			}}}},
+		},
+		{
+			"parenthesized variable is not a cast",
+			"int f(int a, int b) { return (a) - b; }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{{Name: "a"}, {Name: "b"}}, Statements: []Statement{
+				&RefVar{Name: "a", Local: true}, &RefVar{Name: "b", Local: true},
+			}}}},
+		},
+		{
+			"cast with typedef",
+			"typedef int T; int f(int x) { return (T)(x); }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{{Name: "x"}}, Statements: []Statement{
+				&RefVar{Name: "x", Local: true},
+			}}}},
+		},
+		{
+			"cast statement with typedef",
+			"typedef int T; int f(int z) { (T)(z); return 0; }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{{Name: "z"}}, Statements: []Statement{
+				&RefVar{Name: "z", Local: true},
+			}}}},
+		},
+		{
+			"call through parenthesized variable",
+			"int x, z; int f(void) { (x)(z); }",
+			&Module{[]Statement{&VariableDef{Name: "x"}, &VariableDef{Name: "z"}, &FunctionDef{Name: "f", Params: []*VariableDef{}, Statements: []Statement{
+				&CallFunc{Name: "x", Args: []Statement{&RefVar{Name: "z"}}},
+			}}}},
+		},
+		{
+			"function pointer declaration in block",
+			"typedef int T; int f(void) { T (*q)(int); }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{}, Statements: []Statement{
+				&VariableDef{Name: "q"},
+			}}}},
+		},
+		{
+			"unnamed typedef parameter is not an identifier list",
+			"typedef int T; int f(T) { return 0; }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{}, Statements: []Statement{}}}},
+		},
+		{
+			"parameter named like a typedef",
+			"typedef int T; int f(int T) { return T; }",
+			&Module{[]Statement{&FunctionDef{Name: "f", Params: []*VariableDef{{Name: "T"}}, Statements: []Statement{
+				&RefVar{Name: "T", Local: true},
+			}}}},
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		actual, err := ParseWithOptions(tt.src, ParseOptions{})
+		if err != nil {
+			t.Fatalf("got err=%v", err)
+		}
+		if !reflect.DeepEqual(actual, tt.expect) {
+			t.Errorf("got=%v, expect=%v", actual, tt.expect)
+		}
+	}
+}
+
+func TestTypedefNameStrict(t *testing.T) {
+	testTbl := []struct {
+		comment string
+		src     string
+		err     string
+	}{
+		{
+			"declared typedef is not ambiguous",
+			"typedef int T; int f(int x) { T * p; return (T)-x + (T)(x); }",
+			"",
+		},
+		{
+			"declared variable is not ambiguous",
+			"int f(int a, int b) { a * b; return (a)-b; }",
+			"",
+		},
+		{
+			"struct tag named like a function is not ambiguous",
+			"int stat(const char *, struct stat *); int f(void) { struct stat * p; return stat(0, (struct stat *)(p)); }",
+			"",
+		},
+		{
+			"undeclared name is still ambiguous",
+			"int f(int b) { T * b; }",
+			"1:16: expected unambiguous declaration, found 'T'",
+		},
+	}
+
+	for _, tt := range testTbl {
+		t.Logf("%s", tt.comment)
+		_, err := ParseWithOptions(tt.src, ParseOptions{Strict: true})
+		if errString(err) != tt.err {
+			t.Errorf("got err=%v, expect err=%v", errString(err), tt.err)
+		}
+	}
+}